@@ -0,0 +1,210 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Limits of media attached to a tweet, GIFs are uploaded as images and
+// videos without media_category, so larger files are rejected by Twitter
+const (
+	AttachImagesMax = 4
+	ImageSizeMax    = 5 << 20
+	VideoSizeMax    = 15 << 20
+	// Size of a segment of chunked video uploading
+	VideoChunkSize = 1 << 20
+)
+
+// videoStatusInterval is the unit of check_after_secs of a processed video
+var videoStatusInterval = time.Second
+
+// attachableTypes are media types Twitter accepts, detected from file contents
+var attachableTypes = map[string]int64{
+	"image/jpeg": ImageSizeMax,
+	"image/png":  ImageSizeMax,
+	"image/gif":  ImageSizeMax,
+	"video/mp4":  VideoSizeMax,
+}
+
+type attachment struct {
+	path     string
+	mimeType string
+	size     int64
+}
+
+func (a attachment) isVideo() bool {
+	return strings.HasPrefix(a.mimeType, "video/")
+}
+
+func (a attachment) name() string {
+	return filepath.Base(a.path)
+}
+
+// newAttachment validates the type and the size of the file at path,
+// ex) "~/Pictures/cat.png"
+func newAttachment(path string) (attachment, error) {
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return attachment{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return attachment{}, err
+	} else if info.IsDir() {
+		return attachment{}, errors.New(path + " is a directory")
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return attachment{}, err
+	}
+	mimeType := http.DetectContentType(head[:n])
+	max, ok := attachableTypes[mimeType]
+	if !ok {
+		return attachment{}, fmt.Errorf("%s is not an image or a video (%s)", filepath.Base(path), mimeType)
+	}
+	if info.Size() > max {
+		return attachment{}, fmt.Errorf("%s is too large (%s, up to %s)",
+			filepath.Base(path), formatBytes(int(info.Size())), formatBytes(int(max)))
+	}
+	return attachment{path: path, mimeType: mimeType, size: info.Size()}, nil
+}
+
+// attachments are media queued for the next tweet, up to four images or a video
+type attachments []attachment
+
+func (as attachments) add(a attachment) (attachments, error) {
+	for _, b := range as {
+		if b.path == a.path {
+			return as, errors.New(a.name() + " is already attached")
+		}
+	}
+	if len(as) > 0 && (a.isVideo() || as[0].isVideo()) {
+		return as, errors.New("a video cannot be attached with other media")
+	}
+	if len(as) >= AttachImagesMax {
+		return as, fmt.Errorf("up to %d images can be attached", AttachImagesMax)
+	}
+	return append(as, a), nil
+}
+
+// String is shown in the header of the input area, ex) "+cat.png +dog.jpg"
+func (as attachments) String() string {
+	names := make([]string, len(as))
+	for i, a := range as {
+		names[i] = "+" + a.name()
+	}
+	return strings.Join(names, " ")
+}
+
+// upload sends all media, and returns media_ids for PostTweet
+func (as attachments) upload(client TwitterClient) (string, error) {
+	ids := make([]string, 0, len(as))
+	for i, a := range as {
+		var id string
+		var err error
+		if a.isVideo() {
+			id, err = uploadVideo(client, a)
+		} else {
+			changeBufferState(fmt.Sprintf("Uploading media %d/%d (%s)...", i+1, len(as), a.name()))
+			id, err = uploadImage(client, a)
+		}
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, ","), nil
+}
+
+func uploadImage(client TwitterClient, a attachment) (string, error) {
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return "", err
+	}
+	media, err := client.UploadMedia(base64.StdEncoding.EncodeToString(data))
+	if err != nil {
+		return "", err
+	}
+	return media.MediaIDString, nil
+}
+
+// uploadVideo uploads a video in chunks, and shows the progress
+func uploadVideo(client TwitterClient, a attachment) (string, error) {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	media, err := client.UploadVideoInit(int(a.size), a.mimeType)
+	if err != nil {
+		return "", err
+	}
+	chunk := make([]byte, VideoChunkSize)
+	var sent int64
+	for index := 0; sent < a.size; index++ {
+		changeBufferState(fmt.Sprintf("Uploading video %s %d%%...", a.name(), sent*100/a.size))
+		n, err := io.ReadFull(file, chunk)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+		err = client.UploadVideoAppend(media.MediaIDString, index, base64.StdEncoding.EncodeToString(chunk[:n]))
+		if err != nil {
+			return "", err
+		}
+		sent += int64(n)
+	}
+	video, err := client.UploadVideoFinalize(media.MediaIDString)
+	if err != nil {
+		return "", err
+	}
+	// The video can't be tweeted until Twitter finishes processing it
+	for info := video.ProcessingInfo; info != nil && info.State != "succeeded"; info = video.ProcessingInfo {
+		if info.State == "failed" {
+			if info.Error != nil {
+				return "", fmt.Errorf("processing %s failed: %s", a.name(), info.Error.Message)
+			}
+			return "", fmt.Errorf("processing %s failed", a.name())
+		}
+		changeBufferState(fmt.Sprintf("Processing video %s %d%%...", a.name(), info.ProgressPercent))
+		wait := info.CheckAfterSecs
+		if wait < 1 {
+			wait = 1
+		}
+		time.Sleep(time.Duration(wait) * videoStatusInterval)
+		video, err = client.UploadVideoStatus(media.MediaIDString)
+		if err != nil {
+			return "", err
+		}
+	}
+	return video.MediaIDString, nil
+}
@@ -0,0 +1,215 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writePNG(t *testing.T, path string) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeMP4 writes the header of an MP4 file and padding
+func writeMP4(t *testing.T, path string, size int) {
+	data := make([]byte, size)
+	copy(data, "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	dir := t.TempDir()
+	var as attachments
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png", "e.png"} {
+		writePNG(t, filepath.Join(dir, name))
+	}
+	writeMP4(t, filepath.Join(dir, "v.mp4"), 100)
+	ioutil.WriteFile(filepath.Join(dir, "note.txt"), []byte("hello"), 0644)
+
+	if _, err := newAttachment(filepath.Join(dir, "note.txt")); err == nil {
+		t.Fatalf("Text file must not be attached")
+	}
+	if _, err := newAttachment(dir); err == nil {
+		t.Fatalf("Directory must not be attached")
+	}
+	// Twitter doesn't accept WebP
+	ioutil.WriteFile(filepath.Join(dir, "a.webp"), []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), 0644)
+	if _, err := newAttachment(filepath.Join(dir, "a.webp")); err == nil {
+		t.Fatalf("WebP image must not be attached")
+	}
+	video, err := newAttachment(filepath.Join(dir, "v.mp4"))
+	if err != nil || !video.isVideo() {
+		t.Fatalf("MP4 must be a video: %v", err)
+	}
+	// Larger files are rejected by Twitter without media_category
+	writeMP4(t, filepath.Join(dir, "large.mp4"), VideoSizeMax+1)
+	gif := append([]byte("GIF89a"), make([]byte, ImageSizeMax)...)
+	ioutil.WriteFile(filepath.Join(dir, "large.gif"), gif, 0644)
+	for _, name := range []string{"large.mp4", "large.gif"} {
+		if _, err := newAttachment(filepath.Join(dir, name)); err == nil {
+			t.Fatalf("%s must be too large", name)
+		}
+	}
+
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+		a, err := newAttachment(filepath.Join(dir, name))
+		if err != nil || a.mimeType != "image/png" {
+			t.Fatalf("%s must be a PNG image: %v", name, err)
+		}
+		if as, err = as.add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if as.String() != "+a.png +b.png +c.png +d.png" {
+		t.Fatalf("Unexpected header: %q", as.String())
+	}
+	e, _ := newAttachment(filepath.Join(dir, "e.png"))
+	if _, err := as.add(e); err == nil {
+		t.Fatalf("Only 4 images can be attached")
+	}
+	if _, err := as[:1].add(as[0]); err == nil {
+		t.Fatalf("Same file must not be attached twice")
+	}
+	if _, err := as[:1].add(video); err == nil {
+		t.Fatalf("Video must not be attached with images")
+	}
+	if _, err := (attachments{video}).add(as[0]); err == nil {
+		t.Fatalf("Images must not be attached with a video")
+	}
+}
+
+func TestUploadAttachments(t *testing.T) {
+	initialize()
+	initializeState()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "a.png"))
+	writeMP4(t, filepath.Join(dir, "v.mp4"), VideoChunkSize*2+10)
+	client := newFakeClient()
+	view := newView(client, nil)
+
+	view.attach(filepath.Join(dir, "a.png"))
+	view.attach(filepath.Join(dir, "missing.png"))
+	if len(view.buffer.attachments) != 1 {
+		t.Fatalf("Only existing files must be attached")
+	}
+	// submit is what Enter does in the confirm mode of the tweet editor
+	compose := func(text string) {
+		view.buffer.inputing = true
+		view.buffer.counting = true
+		view.buffer.post = view.sendNewTweet
+		view.buffer.setContent(text)
+	}
+	compose("with an image")
+	view.submit()
+	if len(view.buffer.attachments) != 0 {
+		t.Fatalf("Attachments must be detached when the tweet is submitted")
+	}
+	waitState(t, "Tweet!")
+	if ids := client.postedValues[0].Get("media_ids"); ids == "" {
+		t.Fatalf("media_ids must be posted")
+	}
+
+	view.attach(filepath.Join(dir, "v.mp4"))
+	client.err = errors.New("network is down")
+	compose("with a video")
+	view.submit()
+	receiveUpdate(t, view.updateCh)
+	if len(view.buffer.attachments) != 1 || len(client.posted) != 1 {
+		t.Fatalf("Attachments must be given back when uploading fails")
+	}
+	client.err = nil
+	uploaded := client.uploaded
+	compose("with a video")
+	view.submit()
+	waitState(t, "Tweet!")
+	calls := strings.Join(client.calls, " ")
+	if !strings.HasSuffix(calls, "UploadVideoInit UploadVideoAppend UploadVideoAppend UploadVideoAppend UploadVideoFinalize PostTweet") {
+		t.Fatalf("Video must be uploaded in chunks: %s", calls)
+	}
+	if client.uploaded-uploaded != VideoChunkSize*2+10 {
+		t.Fatalf("All bytes must be uploaded: %d", client.uploaded-uploaded)
+	}
+	if ids := client.postedValues[1].Get("media_ids"); ids != "40000" {
+		t.Fatalf("Unexpected media_ids: %q", ids)
+	}
+}
+
+func TestUploadProcessedVideo(t *testing.T) {
+	initialize()
+	initializeState()
+	defer func(interval time.Duration) { videoStatusInterval = interval }(videoStatusInterval)
+	videoStatusInterval = time.Millisecond
+	dir := t.TempDir()
+	writeMP4(t, filepath.Join(dir, "v.mp4"), 100)
+	video, _ := newAttachment(filepath.Join(dir, "v.mp4"))
+	client := newFakeClient()
+
+	client.videoStates = []string{"pending", "in_progress", "succeeded"}
+	id, err := uploadVideo(client, video)
+	if err != nil || id != "40000" {
+		t.Fatalf("Unexpected result: %q, %v", id, err)
+	}
+	calls := strings.Join(client.calls, " ")
+	if !strings.HasSuffix(calls, "UploadVideoFinalize UploadVideoStatus UploadVideoStatus") {
+		t.Fatalf("Status must be checked until processing succeeds: %s", calls)
+	}
+
+	client.videoStates = []string{"in_progress", "failed"}
+	if _, err := uploadVideo(client, video); err == nil {
+		t.Fatalf("Failed video must not be tweeted")
+	}
+}
+
+func TestCancelAttachments(t *testing.T) {
+	initialize()
+	initializeState()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "a.png"))
+	view := newView(newFakeClient(), nil)
+
+	// Canceling a command keeps media attached by :attach
+	view.attach(filepath.Join(dir, "a.png"))
+	view.buffer.inputing = true
+	view.buffer.commanding = true
+	view.cancelInput()
+	if len(view.buffer.attachments) != 1 {
+		t.Fatalf("Attachments must be kept when a command is canceled")
+	}
+	view.buffer.inputing = true
+	view.buffer.counting = true
+	view.cancelInput()
+	if len(view.buffer.attachments) != 0 {
+		t.Fatalf("Attachments must be dropped with the canceled tweet")
+	}
+}
@@ -28,13 +28,21 @@ import (
 const (
 	inputMode       = "*Tweet Edit Mode*"
 	commandMode     = "*Command Mode*"
+	searchMode      = "*Search Mode*"
+	commandPrompt   = ":"
+	searchPrompt    = "/"
+	attachPrompt    = "attach: "
 	confirmText     = "ok?[Enter/C-g]"
 	inputAreaMargin = 1
+	// Lines of the input area, it is scrolled when the text is longer
+	inputAreaLines = 4
+	// Lines of the quoted tweet shown above the input area
+	quotePreviewLines = 4
 )
 
 type buffer struct {
 	content     []byte
-	state       string
+	state       notification
 	cursorX     int
 	mode        string
 	process     func(string)
@@ -42,8 +50,35 @@ type buffer struct {
 	confirm     bool
 	confirmLock lock
 	commanding  bool
+	prompt      string
+	// inputTitle and confirmPrompt replace inputMode and confirmText if not empty,
+	// ex) while composing a direct message
+	inputTitle    string
+	confirmPrompt string
+	// actionConfirm is true while confirming an action which has no text to edit,
+	// ex) blocking a user
+	actionConfirm bool
+	// quote is the tweet being quoted, shown above the input area
+	quote *Tweet
+	// attachments are media posted with the next tweet
+	attachments attachments
+	// post is process of tweet editors, attachments at the time of submitting are passed
+	post func(text string, media attachments)
+	// resume returns to the tweet editor from the attach prompt
+	resume func()
+	// counting is true while writing a tweet, the remaining length is shown
+	counting bool
+	// replyAll is true while replying to everyone in a tweet
+	replyAll bool
+	// thread is true while writing a thread, separator lines split the text into tweets
+	thread bool
+	// inputScroll is the first wrapped line shown in the input area
+	inputScroll int
 
 	linePosInfo int
+	unreadInfo  int
+	pendingKeys string
+	rateInfo    string
 	clipboard   []byte
 	footer      string
 }
@@ -63,6 +98,9 @@ func (bf *buffer) draw() {
 
 func (bf *buffer) drawTweetInputArea() {
 	width, height := getTermSize()
+	if bf.quote != nil {
+		bf.drawQuotedTweet()
+	}
 	// Draw upper line
 	fillLine(0, height-5, ColorGray2)
 
@@ -74,18 +112,42 @@ func (bf *buffer) drawTweetInputArea() {
 	x -= runewidth.StringWidth(info) + 1
 	drawText(info, x, height-5, ColorWhite, ColorGray2)
 
-	clength := utf8.RuneCountInString(string(bf.content))
-	if bf.inputing && !bf.commanding && clength >= 20 {
-		info = fmt.Sprintf("length:(%d)", clength)
+	if bf.counting {
+		text := string(bf.content)
+		if bf.thread {
+			parts := splitThread(text)
+			index := threadPartAt(text, bf.cursorX)
+			text = parts[index]
+			info = fmt.Sprintf("part:(%d/%d)", index+1, len(parts))
+			x -= runewidth.StringWidth(info) + 1
+			drawText(info, x, height-5, ColorWhite, ColorGray2)
+		}
+		remaining := remainingLength(text)
+		color := ColorWhite
+		if remaining < 0 {
+			color = ColorRed
+		}
+		info = fmt.Sprintf("remain:(%d)", remaining)
 		x -= runewidth.StringWidth(info) + 1
-		drawText(info, x, height-5, ColorWhite, ColorGray2)
+		drawText(info, x, height-5, color, ColorGray2)
 	}
 
 	x = 2
-	drawText(inputMode, x, height-5, ColorYellow, ColorGray2)
-	x += runewidth.StringWidth(inputMode) + 1
+	title := inputMode
+	if bf.inputTitle != "" {
+		title = bf.inputTitle
+	}
+	drawText(title, x, height-5, ColorYellow, ColorGray2)
+	x += runewidth.StringWidth(title) + 1
+	if bf.counting && len(bf.attachments) > 0 {
+		media := bf.attachments.String()
+		drawText(media, x, height-5, ColorGreen, ColorGray2)
+		x += runewidth.StringWidth(media) + 1
+	}
 	if bf.confirm {
-		drawText(confirmText, x, height-5, ColorRed, ColorGray2)
+		drawText(bf.confirmText(), x, height-5, ColorRed, ColorGray2)
+	} else if bf.pendingKeys != "" {
+		drawText(bf.pendingKeys, x, height-5, ColorPink, ColorGray2)
 	}
 
 	// Draw Input Area
@@ -93,24 +155,44 @@ func (bf *buffer) drawTweetInputArea() {
 	text := string(bf.content)
 	lines := strings.Split(runewidth.Wrap(text, width-inputAreaMargin), "\n")
 
-	for i := 0; i < 4; i++ {
-		if i >= len(lines) {
+	for i := 0; i < inputAreaLines; i++ {
+		l := bf.inputScroll + i
+		if l >= len(lines) {
 			fillLine(0, height-4+i, ColorBackground)
 			termbox.SetCell(width-1, height-4+i, ' ', ColorBackground, ColorBlack)
 			continue
 		}
-		drawText(lines[i], 0, height-4+i, ColorWhite, ColorBackground)
-		x = runewidth.StringWidth(lines[i])
+		color := ColorWhite
+		if bf.thread && strings.TrimSpace(lines[l]) == threadSeparator {
+			color = ColorGray1
+		}
+		drawText(lines[l], 0, height-4+i, color, ColorBackground)
+		x = runewidth.StringWidth(lines[l])
 		fillLine(x, height-4+i, ColorBackground)
 		termbox.SetCell(width-1, height-4+i, ' ', ColorBackground, ColorBlack)
 	}
 
 }
 
+func (bf *buffer) drawQuotedTweet() {
+	width, height := getTermSize()
+	lines := quoteLines(bf.quote, width-2)
+	if len(lines) > quotePreviewLines {
+		lines = lines[:quotePreviewLines]
+	}
+	top := height - 5 - len(lines)
+	for i := range lines {
+		fillLine(0, top+i, ColorBackground)
+	}
+	drawQuote(bf.quote, lines, 1, top, ColorBackground)
+}
+
 func (bf *buffer) drawCommandInputField() {
 	t := bf.mode
 	if bf.inputing {
-		if bf.commanding {
+		if bf.commanding && bf.prompt == searchPrompt {
+			t = searchMode
+		} else if bf.commanding {
 			t = commandMode
 		} else {
 			t = inputMode
@@ -129,6 +211,17 @@ func (bf *buffer) drawCommandInputField() {
 	x -= runewidth.StringWidth(info) + 1
 	drawText(info, x, height-2, ColorWhite, ColorGray2)
 
+	if bf.unreadInfo > 0 {
+		info = fmt.Sprintf("%d new", bf.unreadInfo)
+		x -= runewidth.StringWidth(info) + 1
+		drawText(info, x, height-2, ColorYellow, ColorGray2)
+	}
+
+	if bf.rateInfo != "" {
+		x -= runewidth.StringWidth(bf.rateInfo) + 1
+		drawText(bf.rateInfo, x, height-2, ColorWhite, ColorGray2)
+	}
+
 	clength := utf8.RuneCountInString(string(bf.content))
 	if bf.inputing && !bf.commanding && clength >= 20 {
 		info = fmt.Sprintf("length:(%d)", clength)
@@ -141,43 +234,70 @@ func (bf *buffer) drawCommandInputField() {
 	x += runewidth.StringWidth(t)
 	termbox.SetCell(x, height-2, ' ', ColorBackground, ColorGray2)
 	x++
+	if bf.pendingKeys != "" {
+		drawText(bf.pendingKeys, x, height-2, ColorPink, ColorGray2)
+	}
 
 	// Draw lower line
 	x = 0
 	if bf.inputing {
 		con := string(bf.content)
 		if bf.commanding {
-			con = ":" + con
+			con = bf.prompt + con
 		}
 		drawText(con, 0, height-1, ColorWhite, ColorBackground)
 		x = runewidth.StringWidth(con)
 		if bf.confirm {
 			x++
-			t := confirmText
+			t := bf.confirmText()
 			drawText(t, x, height-1, ColorRed, ColorBackground)
 			x += runewidth.StringWidth(t)
 		}
 	} else {
-		// When state contains "Err" message, draw it by Red
-		sc := ColorWhite
-		if strings.Contains(bf.state, "Err") {
-			sc = ColorRed
-		}
-		drawText(bf.state, x, height-1, sc, ColorBackground)
-		x += runewidth.StringWidth(bf.state)
+		state := bf.state.String()
+		drawText(state, x, height-1, bf.state.Severity.color(), ColorBackground)
+		x += runewidth.StringWidth(state)
 	}
 	fillLine(x, height-1, ColorBackground)
 
 }
 
+func (bf *buffer) confirmText() string {
+	if bf.confirmPrompt != "" {
+		return bf.confirmPrompt
+	}
+	return confirmText
+}
+
+// overLimit returns how many characters must be removed to tweet,
+// and the index of the part which is too long in a thread
+func (bf *buffer) overLimit() (part, over int) {
+	if !bf.counting {
+		return 0, 0
+	}
+	parts := []string{string(bf.content)}
+	if bf.thread {
+		parts = splitThread(parts[0])
+	}
+	for i, p := range parts {
+		if remaining := remainingLength(p); remaining < 0 {
+			return i, -remaining
+		}
+	}
+	return 0, 0
+}
+
 func (bf *buffer) runeUnderCursor() (rune, int) {
 	return utf8.DecodeRune(bf.content[bf.cursorX:])
 }
 
 func (bf *buffer) insertRune(r rune) {
-	// Normally the limit of tweet length is 140,but considering
-	// some exceptions.(URL, etc...) set 180 to limit
-	if len(bf.content) > 180 && utf8.RuneCountInString(string(bf.content)) > 180 {
+	// Text over TweetLengthMax can be written to edit it down later
+	text := string(bf.content)
+	if bf.thread {
+		text = splitThread(text)[threadPartAt(text, bf.cursorX)]
+	}
+	if tweetLength(text) >= TweetLengthMax*2 {
 		return
 	}
 	var u [utf8.UTFMax]byte
@@ -190,7 +310,8 @@ func (bf *buffer) insertLF() {
 	w, _ := getTermSize()
 	text := string(bf.content)
 	lines := strings.Split(runewidth.Wrap(text, w), "\n")
-	if len(lines) < 4 {
+	// A thread can be longer than the input area, it is scrolled
+	if len(lines) < inputAreaLines || bf.thread {
 		bf.insertRune('\n')
 	}
 }
@@ -429,28 +550,40 @@ func (bf *buffer) updateCursorPosition() {
 		text := string(bf.content[:bf.cursorX])
 		lines := strings.Split(runewidth.Wrap(text, w-inputAreaMargin), "\n")
 		x := runewidth.StringWidth(lines[len(lines)-1])
+		line := len(lines) - 1
 		if x == w {
-			termbox.SetCursor(0, h-4+len(lines))
-		} else {
-			termbox.SetCursor(x, h-5+len(lines))
+			x = 0
+			line++
 		}
+		bf.scrollInputArea(line)
+		termbox.SetCursor(x, h-4+line-bf.inputScroll)
+	}
+}
+
+// scrollInputArea scrolls the input area to show the line
+func (bf *buffer) scrollInputArea(line int) {
+	if line < bf.inputScroll {
+		bf.inputScroll = line
+	} else if line >= bf.inputScroll+inputAreaLines {
+		bf.inputScroll = line - inputAreaLines + 1
 	}
 }
 
 func (bf *buffer) setContent(s string) {
 	b := []byte(s)
-	bf.content = make([]byte, len(b), 180)
+	bf.content = make([]byte, len(b), len(b)+TweetLengthMax)
 	copy(bf.content, b)
 	bf.cursorX = len(b)
+	bf.inputScroll = 0
 }
 
-func (bf *buffer) setState(s string) {
-	bf.state = s
+func (bf *buffer) setState(n notification) {
+	bf.state = n
 }
 
 func (bf *buffer) clear() {
 	bf.setContent("")
-	bf.setState("")
+	bf.setState(notification{})
 }
 
 func (bf *buffer) setModeStr(m viewmode) {
@@ -468,6 +601,12 @@ func (bf *buffer) setModeStr(m viewmode) {
 		s = "*List View*"
 	case favorite:
 		s = "*Favorite View*"
+	case search:
+		s = "*Search View*"
+	case directmessage:
+		s = "*Direct Message View*"
+	case pager:
+		s = "*Pager View*"
 	}
 	bf.mode = s
 }
@@ -18,43 +18,159 @@ limitations under the License.
 package main
 
 import (
+	clist "container/list"
 	"github.com/ChimeraCoder/anaconda"
 	"strings"
 	"sync"
+	"unsafe"
 )
 
 // TweetMap caches loaded Tweet
+// When it holds more than capacity, least recently used tweets are evicted
+// except pinned ones, which are still referenced by views
 type TweetMap struct {
-	content map[int64]*anaconda.Tweet
-	mutex   *sync.RWMutex
+	content  map[int64]*clist.Element
+	order    *clist.List
+	capacity int
+	size     int
+	pinned   func() map[int64]bool
+	mutex    *sync.RWMutex
+}
+
+type tweetMapEntry struct {
+	tweet *Tweet
+	size  int
 }
 
 func newTweetMap() *TweetMap {
 	return &TweetMap{
-		content: make(map[int64]*anaconda.Tweet, 300),
-		mutex:   new(sync.RWMutex),
+		content:  make(map[int64]*clist.Element, 300),
+		order:    clist.New(),
+		capacity: TweetMapCapacity,
+		mutex:    new(sync.RWMutex),
 	}
 }
 
-func (tm *TweetMap) registerTweet(tweet *anaconda.Tweet) {
+// setPinned sets the function which returns IDs of tweets never to be evicted
+func (tm *TweetMap) setPinned(f func() map[int64]bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	tm.pinned = f
+}
+
+func (tm *TweetMap) registerTweet(tweet *Tweet) {
 	tm.mutex.Lock()
 	defer tm.mutex.Unlock()
-	tm.content[tweet.Id] = tweet
+	tm.add(tweet)
+	tm.evict()
 }
 
-func (tm *TweetMap) registerTweets(tweets []anaconda.Tweet) {
+func (tm *TweetMap) registerTweets(tweets []Tweet) {
 	tm.mutex.Lock()
 	defer tm.mutex.Unlock()
 	for i := range tweets {
-		tm.content[tweets[i].Id] = &tweets[i]
+		tm.add(&tweets[i])
+	}
+	tm.evict()
+}
+
+func (tm *TweetMap) add(tweet *Tweet) {
+	entry := &tweetMapEntry{tweet: tweet, size: approxTweetSize(tweet)}
+	if e, ok := tm.content[tweet.Id]; ok {
+		tm.size -= e.Value.(*tweetMapEntry).size
+		e.Value = entry
+		tm.order.MoveToFront(e)
+	} else {
+		tm.content[tweet.Id] = tm.order.PushFront(entry)
+	}
+	tm.size += entry.size
+}
+
+func (tm *TweetMap) evict() {
+	if len(tm.content) <= tm.capacity {
+		return
+	}
+	var pinned map[int64]bool
+	if tm.pinned != nil {
+		pinned = tm.pinned()
+	}
+	// Evict a little more than needed not to evict at each registration
+	target := tm.capacity - tm.capacity/10
+	e := tm.order.Back()
+	for e != nil && len(tm.content) > target {
+		prev := e.Prev()
+		entry := e.Value.(*tweetMapEntry)
+		if !pinned[entry.tweet.Id] {
+			tm.order.Remove(e)
+			delete(tm.content, entry.tweet.Id)
+			tm.size -= entry.size
+		}
+		e = prev
+	}
+}
+
+// remove drops tweets which match returns true for, ex) a deleted tweet and its retweets
+func (tm *TweetMap) remove(match func(*Tweet) bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	for id, e := range tm.content {
+		entry := e.Value.(*tweetMapEntry)
+		if match(entry.tweet) {
+			tm.order.Remove(e)
+			delete(tm.content, id)
+			tm.size -= entry.size
+		}
 	}
 }
 
-func (tm *TweetMap) get(id int64) (*anaconda.Tweet, bool) {
+func (tm *TweetMap) get(id int64) (*Tweet, bool) {
+	// Write lock is needed, because get updates the order of use
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	e, ok := tm.content[id]
+	if !ok {
+		return nil, false
+	}
+	tm.order.MoveToFront(e)
+	return e.Value.(*tweetMapEntry).tweet, true
+}
+
+// stats returns number of cached tweets and approximate memory use in bytes
+func (tm *TweetMap) stats() (int, int) {
 	tm.mutex.RLock()
 	defer tm.mutex.RUnlock()
-	val, ok := tm.content[id]
-	return val, ok
+	return len(tm.content), tm.size
+}
+
+// approxTweetSize estimates memory held by a tweet, it doesn't need to be exact
+func approxTweetSize(t *Tweet) int {
+	size := int(unsafe.Sizeof(*t))
+	size += len(t.Text) + len(t.FullText) + len(t.Source) + len(t.CreatedAt) + len(t.IdStr) + len(t.Lang)
+	size += len(t.User.ScreenName) + len(t.User.Name) + len(t.User.Description) +
+		len(t.User.Location) + len(t.User.URL) + len(t.User.ProfileImageURL) +
+		len(t.User.ProfileImageUrlHttps) + len(t.User.ProfileBackgroundImageURL) +
+		len(t.User.ProfileBannerURL) + len(t.User.CreatedAt)
+	for _, u := range t.Entities.Urls {
+		size += len(u.Url) + len(u.Display_url) + len(u.Expanded_url)
+	}
+	for _, m := range t.Entities.Media {
+		size += int(unsafe.Sizeof(m)) + len(m.Media_url) + len(m.Media_url_https) +
+			len(m.Url) + len(m.Display_url) + len(m.Expanded_url)
+	}
+	for _, m := range t.ExtendedEntities.Media {
+		size += int(unsafe.Sizeof(m)) + len(m.Media_url) + len(m.Media_url_https) +
+			len(m.Url) + len(m.Display_url) + len(m.Expanded_url)
+	}
+	for _, h := range t.Entities.Hashtags {
+		size += len(h.Text)
+	}
+	for _, m := range t.Entities.User_mentions {
+		size += len(m.Name) + len(m.Screen_name) + len(m.Id_str)
+	}
+	if t.RetweetedStatus != nil {
+		size += approxTweetSize(t.RetweetedStatus)
+	}
+	return size
 }
 
 // ProfileMap caches loaded user profile
@@ -82,3 +198,81 @@ func (pm *ProfileMap) get(screenName string) (*anaconda.User, bool) {
 	val, ok := pm.content[screenName]
 	return val, ok
 }
+
+func (pm *ProfileMap) profiles() []*anaconda.User {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+	result := make([]*anaconda.User, 0, len(pm.content))
+	for _, u := range pm.content {
+		result = append(result, u)
+	}
+	return result
+}
+
+// timelineCache keeps timelines not shown now, keyed by a screen name,
+// a list ID or a query. The least recently shown one is dropped when too many are cached
+type timelineCache struct {
+	timelines map[string][]tweetstatus
+	order     []string
+}
+
+func newTimelineCache() *timelineCache {
+	return &timelineCache{timelines: make(map[string][]tweetstatus)}
+}
+
+func (tc *timelineCache) store(key string, tweets []tweetstatus) {
+	if key == "" {
+		return
+	}
+	if len(tweets) > UserTimelineCacheTweets {
+		t := make([]tweetstatus, UserTimelineCacheTweets, UserTimelineCacheTweets+1)
+		copy(t, tweets)
+		tweets = append(t, tweetstatus{ReloadMark: true})
+	}
+	tc.drop(key)
+	tc.timelines[key] = tweets
+	tc.order = append(tc.order, key)
+	for len(tc.order) > UserTimelineCacheMax {
+		delete(tc.timelines, tc.order[0])
+		tc.order = tc.order[1:]
+	}
+}
+
+// take returns the timeline of key and drops it from the cache
+func (tc *timelineCache) take(key string) ([]tweetstatus, bool) {
+	tweets, ok := tc.timelines[key]
+	if ok {
+		tc.drop(key)
+	}
+	return tweets, ok
+}
+
+func (tc *timelineCache) drop(key string) {
+	delete(tc.timelines, key)
+	for i, k := range tc.order {
+		if k == key {
+			tc.order = append(tc.order[:i], tc.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeTweets removes tweets which f returns true for from every cached timeline
+func (tc *timelineCache) removeTweets(f func(tweetstatus) bool) {
+	for key, tweets := range tc.timelines {
+		t := make([]tweetstatus, 0, len(tweets))
+		for _, ts := range tweets {
+			if ts.Content == nil || !f(ts) {
+				t = append(t, ts)
+			}
+		}
+		if len(t) == 0 {
+			t = append(t, tweetstatus{ReloadMark: true})
+		}
+		tc.timelines[key] = t
+	}
+}
+
+func (tc *timelineCache) len() int {
+	return len(tc.timelines)
+}
@@ -0,0 +1,71 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestTweetMapEviction(t *testing.T) {
+	tm := newTweetMap()
+	tm.capacity = 10
+	tm.setPinned(func() map[int64]bool {
+		return map[int64]bool{1: true, 2: true}
+	})
+	tweets := make([]Tweet, 0, 20)
+	for id := int64(1); id <= 20; id++ {
+		tweets = append(tweets, newFakeTweet(id, "alice", "hello"))
+	}
+	tm.registerTweets(tweets[:10])
+	// Tweet 5 is used recently, so it must survive the next eviction
+	if _, ok := tm.get(5); !ok {
+		t.Fatalf("Tweet 5 must be cached")
+	}
+	tm.registerTweet(&tweets[10])
+
+	count, size := tm.stats()
+	if count > tm.capacity {
+		t.Fatalf("Expected at most %d tweets, but %d", tm.capacity, count)
+	}
+	if size <= 0 {
+		t.Fatalf("Memory use must be accounted")
+	}
+	for _, id := range []int64{1, 2, 5, 11} {
+		if _, ok := tm.get(id); !ok {
+			t.Fatalf("Tweet %d must not be evicted", id)
+		}
+	}
+	if _, ok := tm.get(3); ok {
+		t.Fatalf("Least recently used tweet must be evicted")
+	}
+}
+
+func TestUsertimelineviewCacheBound(t *testing.T) {
+	initialize()
+	initializeState()
+	uv := newUsertimelineview(newFakeClient())
+	for i := 0; i < UserTimelineCacheMax+3; i++ {
+		uv.setUserScreenName(string(rune('a'+i%26)) + string(rune('a'+i/26)))
+	}
+	if uv.cache.len() > UserTimelineCacheMax || len(uv.cache.order) != uv.cache.len() {
+		t.Fatalf("Expected at most %d cached users, but %d", UserTimelineCacheMax, uv.cache.len())
+	}
+	if _, ok := uv.cache.timelines["aa"]; ok {
+		t.Fatalf("The least recently shown user must be dropped")
+	}
+}
@@ -39,8 +39,8 @@ func main() {
 }
 
 var (
-	api          *anaconda.TwitterApi
-	stateCh      chan string
+	stateCh      chan notification
+	messages     *messageLog
 	stateClearCh chan int
 	tweetmap     *TweetMap
 	profilemap   *ProfileMap
@@ -50,6 +50,10 @@ var (
 )
 
 type cli struct {
+	api        *anaconda.TwitterApi
+	homeDir    string
+	outputMode termbox.OutputMode
+
 	argShowVersion bool
 	argAuthFlag    bool
 	argUser        string
@@ -71,8 +75,18 @@ func (cl *cli) run() {
 
 	user = cl.setting()
 
-	view := newView()
-	stateCh = make(chan string)
+	store := newTweetStore(filepath.Join(cl.homeDir, ProfileDir,
+		strconv.FormatInt(user.ID, 10)))
+	keymap, err := loadKeymap(filepath.Join(cl.homeDir, ProfileDir, KeymapFile))
+	if err != nil {
+		fmt.Println("Failed to load " + KeymapFile)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	view := newView(newAnacondaClient(cl.api), store)
+	view.keymap = keymap
+	stateCh = make(chan notification)
+	messages = newMessageLog(MessageLogMax)
 	stateClearCh = make(chan int, 2)
 	tweetmap = newTweetMap()
 	profilemap = newProfileMap()
@@ -88,7 +102,7 @@ func (cl *cli) run() {
 		}
 
 	}()
-	termbox.SetOutputMode(termbox.Output256)
+	termbox.SetOutputMode(cl.outputMode)
 	termbox.SetInputMode(termbox.InputAlt)
 
 	if os.Getenv("TERM") == "xterm" {
@@ -134,6 +148,25 @@ const (
 	ConfigFile = "config.json"
 )
 
+// loadSettings reads SettingsFile and applies its theme
+func (cl *cli) loadSettings(path string) {
+	s, err := loadSettings(path)
+	if err != nil {
+		fmt.Println("Failed to load " + SettingsFile)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	theme, _ := s.theme()
+	mode, err := applyTheme(theme)
+	if err != nil {
+		fmt.Println("Failed to apply theme " + s.Theme)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	settings = s
+	cl.outputMode = mode
+}
+
 func (cl *cli) setting() UserConfig {
 	me, err := osuser.Current()
 	if err != nil {
@@ -141,6 +174,8 @@ func (cl *cli) setting() UserConfig {
 		os.Exit(1)
 	}
 	home := me.HomeDir
+	cl.homeDir = home
+	cl.loadSettings(filepath.Join(home, ProfileDir, SettingsFile))
 	fullpath := filepath.Join(home, ProfileDir, ConfigFile)
 
 	var configSlice []UserConfig
@@ -164,8 +199,8 @@ func (cl *cli) setting() UserConfig {
 		var config UserConfig
 		at, ats := cl.authorize()
 		config.AccessToken, config.AccessTokenSecret = at, ats
-		api = anaconda.NewTwitterApi(at, ats)
-		u, err := api.GetSelf(nil)
+		cl.api = anaconda.NewTwitterApi(at, ats)
+		u, err := cl.api.GetSelf(nil)
 		if err != nil {
 			fmt.Println("Failed to get user profile")
 			os.Exit(1)
@@ -256,9 +291,9 @@ func (cl *cli) setting() UserConfig {
 			}
 		}
 	}
-	api = anaconda.NewTwitterApi(config.AccessToken, config.AccessTokenSecret)
-	api.HttpClient.Timeout = time.Second * 5
-	u, err := api.GetSelf(nil)
+	cl.api = anaconda.NewTwitterApi(config.AccessToken, config.AccessTokenSecret)
+	cl.api.HttpClient.Timeout = time.Second * 5
+	u, err := cl.api.GetSelf(nil)
 	if err == nil {
 		config.ScreenName = u.ScreenName
 		config.UserName = u.Name
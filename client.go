@@ -0,0 +1,382 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/garyburd/go-oauth/oauth"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// TwitterClient is the set of Twitter API calls Ringot uses.
+// Views receive it on construction, so they can run against a fake backend.
+type TwitterClient interface {
+	GetSelf(v url.Values) (anaconda.User, error)
+	GetHomeTimeline(v url.Values) ([]Tweet, error)
+	GetMentionsTimeline(v url.Values) ([]Tweet, error)
+	GetUserTimeline(v url.Values) ([]Tweet, error)
+	GetFavorites(v url.Values) ([]Tweet, error)
+	GetListTweets(listID int64, includeRTs bool, v url.Values) ([]Tweet, error)
+	GetList(v url.Values) (anaconda.List, error)
+	GetTweet(id int64, v url.Values) (Tweet, error)
+	GetUsersShow(screenName string, v url.Values) (anaconda.User, error)
+	GetSearch(query string, v url.Values) (SearchResponse, error)
+	GetDirectMessages(v url.Values) ([]anaconda.DirectMessage, error)
+	GetDirectMessagesSent(v url.Values) ([]anaconda.DirectMessage, error)
+	GetMutedUsersIds(v url.Values) (anaconda.Cursor, error)
+
+	PostTweet(status string, v url.Values) (Tweet, error)
+	PostDMToScreenName(text, screenName string) (anaconda.DirectMessage, error)
+	Retweet(id int64, trimUser bool) (Tweet, error)
+	UnRetweet(id int64, trimUser bool) (Tweet, error)
+	DeleteTweet(id int64, trimUser bool) (Tweet, error)
+	UploadMedia(base64String string) (anaconda.Media, error)
+	UploadVideoInit(totalBytes int, mimeType string) (anaconda.ChunkedMedia, error)
+	UploadVideoAppend(mediaIDString string, segmentIndex int, base64String string) error
+	UploadVideoFinalize(mediaIDString string) (VideoMedia, error)
+	UploadVideoStatus(mediaIDString string) (VideoMedia, error)
+	Favorite(id int64) (Tweet, error)
+	Unfavorite(id int64) (Tweet, error)
+	FollowUser(screenName string) (anaconda.User, error)
+	UnfollowUser(screenName string) (anaconda.User, error)
+	MuteUser(screenName string) (anaconda.User, error)
+	UnmuteUser(screenName string) (anaconda.User, error)
+	BlockUser(screenName string) (anaconda.User, error)
+	UnblockUser(screenName string) (anaconda.User, error)
+	ReportSpam(screenName string) (anaconda.User, error)
+
+	// RateLimit returns the last known rate limit of an endpoint family
+	RateLimit(family string) (rateLimit, bool)
+}
+
+// VideoMedia is an uploaded video, which may still be processed by Twitter
+// after FINALIZE until ProcessingInfo.State becomes "succeeded" or "failed"
+type VideoMedia struct {
+	anaconda.VideoMedia
+	ProcessingInfo *ProcessingInfo `json:"processing_info"`
+}
+
+// ProcessingInfo is the state of a video being processed
+type ProcessingInfo struct {
+	State           string `json:"state"`
+	CheckAfterSecs  int    `json:"check_after_secs"`
+	ProgressPercent int    `json:"progress_percent"`
+	Error           *struct {
+		Code    int    `json:"code"`
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anacondaClient is the TwitterClient backed by the real Twitter API
+// Requests which would exceed the rate limit are refused without calling API
+type anacondaClient struct {
+	api     *anaconda.TwitterApi
+	limiter *rateLimiter
+	// oauth signs requests which are not made by anaconda, see request
+	oauth     *oauth.Client
+	baseURL   string
+	uploadURL string
+}
+
+var _ TwitterClient = (*anacondaClient)(nil)
+
+func newAnacondaClient(api *anaconda.TwitterApi) *anacondaClient {
+	limiter := newRateLimiter()
+	base := api.HttpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	api.HttpClient = &http.Client{
+		Timeout:   api.HttpClient.Timeout,
+		Transport: &rateLimitTransport{base: base, limiter: limiter},
+	}
+	// Don't let anaconda wait for the next window silently
+	api.ReturnRateLimitError(true)
+	return &anacondaClient{
+		api:       api,
+		limiter:   limiter,
+		oauth:     &oauth.Client{Credentials: oauth.Credentials{Token: ConsumerKey, Secret: ConsumerSecret}},
+		baseURL:   anaconda.BaseUrl,
+		uploadURL: anaconda.UploadBaseUrl,
+	}
+}
+
+// request calls an endpoint with the credentials and the HTTP client of anaconda,
+// for endpoints anaconda doesn't have and for responses decoded to Tweet
+func (c *anacondaClient) request(method, endpoint string, v url.Values, data interface{}) error {
+	return c.requestURL(method, c.baseURL+endpoint, v, data)
+}
+
+// requestURL is request for a URL outside of the REST API, like media uploading
+func (c *anacondaClient) requestURL(method, rawurl string, v url.Values, data interface{}) error {
+	if v == nil {
+		v = url.Values{}
+	}
+	var resp *http.Response
+	var err error
+	if method == http.MethodPost {
+		resp, err = c.oauth.Post(c.api.HttpClient, c.api.Credentials, rawurl, v)
+	} else {
+		resp, err = c.oauth.Get(c.api.HttpClient, c.api.Credentials, rawurl, v)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := anaconda.NewApiError(resp)
+		json.Unmarshal([]byte(apiErr.Body), &apiErr.Decoded)
+		return apiErr
+	}
+	return json.NewDecoder(resp.Body).Decode(data)
+}
+
+// postTweetAction posts to an endpoint of a tweet like retweet, which returns the tweet
+func (c *anacondaClient) postTweetAction(endpoint string, trimUser bool) (Tweet, error) {
+	v := url.Values{}
+	if trimUser {
+		v.Set("trim_user", "t")
+	}
+	var t Tweet
+	err := c.request(http.MethodPost, endpoint, v, &t)
+	return t, err
+}
+
+// cloneValues copies v to add parameters without changing v of the caller
+func cloneValues(v url.Values) url.Values {
+	result := url.Values{}
+	for key, values := range v {
+		result[key] = append([]string(nil), values...)
+	}
+	return result
+}
+
+func (c *anacondaClient) RateLimit(family string) (rateLimit, bool) {
+	return c.limiter.get(family)
+}
+
+func (c *anacondaClient) GetSelf(v url.Values) (anaconda.User, error) {
+	return c.api.GetSelf(v)
+}
+
+func (c *anacondaClient) GetHomeTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.limiter.check(familyHomeTimeline); err != nil {
+		return nil, err
+	}
+	var result []Tweet
+	err := c.request(http.MethodGet, "/statuses/home_timeline.json", v, &result)
+	return result, c.limiter.wrapError(familyHomeTimeline, err)
+}
+
+func (c *anacondaClient) GetMentionsTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.limiter.check(familyMentionsTimeline); err != nil {
+		return nil, err
+	}
+	var result []Tweet
+	err := c.request(http.MethodGet, "/statuses/mentions_timeline.json", v, &result)
+	return result, c.limiter.wrapError(familyMentionsTimeline, err)
+}
+
+func (c *anacondaClient) GetUserTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.limiter.check(familyUserTimeline); err != nil {
+		return nil, err
+	}
+	var result []Tweet
+	err := c.request(http.MethodGet, "/statuses/user_timeline.json", v, &result)
+	return result, c.limiter.wrapError(familyUserTimeline, err)
+}
+
+func (c *anacondaClient) GetFavorites(v url.Values) ([]Tweet, error) {
+	if err := c.limiter.check(familyFavorites); err != nil {
+		return nil, err
+	}
+	var result []Tweet
+	err := c.request(http.MethodGet, "/favorites/list.json", v, &result)
+	return result, c.limiter.wrapError(familyFavorites, err)
+}
+
+func (c *anacondaClient) GetListTweets(listID int64, includeRTs bool, v url.Values) ([]Tweet, error) {
+	if err := c.limiter.check(familyListStatuses); err != nil {
+		return nil, err
+	}
+	v = cloneValues(v)
+	v.Set("list_id", strconv.FormatInt(listID, 10))
+	v.Set("include_rts", strconv.FormatBool(includeRTs))
+	var result []Tweet
+	err := c.request(http.MethodGet, "/lists/statuses.json", v, &result)
+	return result, c.limiter.wrapError(familyListStatuses, err)
+}
+
+func (c *anacondaClient) GetList(v url.Values) (anaconda.List, error) {
+	if err := c.limiter.check(familyListShow); err != nil {
+		return anaconda.List{}, err
+	}
+	result, err := c.api.GetList(v)
+	return result, c.limiter.wrapError(familyListShow, err)
+}
+
+func (c *anacondaClient) GetTweet(id int64, v url.Values) (Tweet, error) {
+	if err := c.limiter.check(familyShowTweet); err != nil {
+		return Tweet{}, err
+	}
+	v = cloneValues(v)
+	v.Set("id", strconv.FormatInt(id, 10))
+	var result Tweet
+	err := c.request(http.MethodGet, "/statuses/show.json", v, &result)
+	return result, c.limiter.wrapError(familyShowTweet, err)
+}
+
+func (c *anacondaClient) GetUsersShow(screenName string, v url.Values) (anaconda.User, error) {
+	if err := c.limiter.check(familyUsersShow); err != nil {
+		return anaconda.User{}, err
+	}
+	result, err := c.api.GetUsersShow(screenName, v)
+	return result, c.limiter.wrapError(familyUsersShow, err)
+}
+
+func (c *anacondaClient) GetSearch(query string, v url.Values) (SearchResponse, error) {
+	if err := c.limiter.check(familySearch); err != nil {
+		return SearchResponse{}, err
+	}
+	v = cloneValues(v)
+	v.Set("q", query)
+	var result SearchResponse
+	err := c.request(http.MethodGet, "/search/tweets.json", v, &result)
+	return result, c.limiter.wrapError(familySearch, err)
+}
+
+func (c *anacondaClient) GetDirectMessages(v url.Values) ([]anaconda.DirectMessage, error) {
+	if err := c.limiter.check(familyDirectMessages); err != nil {
+		return nil, err
+	}
+	result, err := c.api.GetDirectMessages(v)
+	return result, c.limiter.wrapError(familyDirectMessages, err)
+}
+
+func (c *anacondaClient) GetDirectMessagesSent(v url.Values) ([]anaconda.DirectMessage, error) {
+	if err := c.limiter.check(familyDirectMessagesSent); err != nil {
+		return nil, err
+	}
+	result, err := c.api.GetDirectMessagesSent(v)
+	return result, c.limiter.wrapError(familyDirectMessagesSent, err)
+}
+
+func (c *anacondaClient) GetMutedUsersIds(v url.Values) (anaconda.Cursor, error) {
+	if err := c.limiter.check(familyMutedUsersIds); err != nil {
+		return anaconda.Cursor{}, err
+	}
+	result, err := c.api.GetMutedUsersIds(v)
+	return result, c.limiter.wrapError(familyMutedUsersIds, err)
+}
+
+func (c *anacondaClient) PostTweet(status string, v url.Values) (Tweet, error) {
+	v = cloneValues(v)
+	v.Set("status", status)
+	var t Tweet
+	err := c.request(http.MethodPost, "/statuses/update.json", v, &t)
+	return t, err
+}
+
+func (c *anacondaClient) PostDMToScreenName(text, screenName string) (anaconda.DirectMessage, error) {
+	return c.api.PostDMToScreenName(text, screenName)
+}
+
+func (c *anacondaClient) Retweet(id int64, trimUser bool) (Tweet, error) {
+	return c.postTweetAction(fmt.Sprintf("/statuses/retweet/%d.json", id), trimUser)
+}
+
+func (c *anacondaClient) UnRetweet(id int64, trimUser bool) (Tweet, error) {
+	return c.postTweetAction(fmt.Sprintf("/statuses/unretweet/%d.json", id), trimUser)
+}
+
+func (c *anacondaClient) DeleteTweet(id int64, trimUser bool) (Tweet, error) {
+	return c.postTweetAction(fmt.Sprintf("/statuses/destroy/%d.json", id), trimUser)
+}
+
+func (c *anacondaClient) UploadMedia(base64String string) (anaconda.Media, error) {
+	return c.api.UploadMedia(base64String)
+}
+
+func (c *anacondaClient) UploadVideoInit(totalBytes int, mimeType string) (anaconda.ChunkedMedia, error) {
+	return c.api.UploadVideoInit(totalBytes, mimeType)
+}
+
+func (c *anacondaClient) UploadVideoAppend(mediaIDString string, segmentIndex int, base64String string) error {
+	return c.api.UploadVideoAppend(mediaIDString, segmentIndex, base64String)
+}
+
+func (c *anacondaClient) UploadVideoFinalize(mediaIDString string) (VideoMedia, error) {
+	var video VideoMedia
+	v := url.Values{"command": {"FINALIZE"}, "media_id": {mediaIDString}}
+	err := c.requestURL(http.MethodPost, c.uploadURL+"/media/upload.json", v, &video)
+	return video, err
+}
+
+func (c *anacondaClient) UploadVideoStatus(mediaIDString string) (VideoMedia, error) {
+	var video VideoMedia
+	v := url.Values{"command": {"STATUS"}, "media_id": {mediaIDString}}
+	err := c.requestURL(http.MethodGet, c.uploadURL+"/media/upload.json", v, &video)
+	return video, err
+}
+
+func (c *anacondaClient) Favorite(id int64) (Tweet, error) {
+	var t Tweet
+	err := c.request(http.MethodPost, "/favorites/create.json", url.Values{"id": {strconv.FormatInt(id, 10)}}, &t)
+	return t, err
+}
+
+func (c *anacondaClient) Unfavorite(id int64) (Tweet, error) {
+	var t Tweet
+	err := c.request(http.MethodPost, "/favorites/destroy.json", url.Values{"id": {strconv.FormatInt(id, 10)}}, &t)
+	return t, err
+}
+
+func (c *anacondaClient) FollowUser(screenName string) (anaconda.User, error) {
+	return c.api.FollowUser(screenName)
+}
+
+func (c *anacondaClient) UnfollowUser(screenName string) (anaconda.User, error) {
+	return c.api.UnfollowUser(screenName)
+}
+
+func (c *anacondaClient) MuteUser(screenName string) (anaconda.User, error) {
+	return c.api.MuteUser(screenName, nil)
+}
+
+func (c *anacondaClient) UnmuteUser(screenName string) (anaconda.User, error) {
+	return c.api.UnmuteUser(screenName, nil)
+}
+
+func (c *anacondaClient) BlockUser(screenName string) (anaconda.User, error) {
+	return c.api.BlockUser(screenName, nil)
+}
+
+func (c *anacondaClient) UnblockUser(screenName string) (anaconda.User, error) {
+	return c.api.UnblockUser(screenName, nil)
+}
+
+func (c *anacondaClient) ReportSpam(screenName string) (anaconda.User, error) {
+	var u anaconda.User
+	err := c.request(http.MethodPost, "/users/report_spam.json", url.Values{"screen_name": {screenName}}, &u)
+	return u, err
+}
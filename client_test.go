@@ -0,0 +1,156 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestAnacondaClient returns anacondaClient calling handler instead of Twitter API
+func newTestAnacondaClient(t *testing.T, handler http.HandlerFunc) *anacondaClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c := newAnacondaClient(anaconda.NewTwitterApi("token", "secret"))
+	c.baseURL = server.URL
+	c.uploadURL = server.URL
+	return c
+}
+
+func TestReportSpam(t *testing.T) {
+	c := newTestAnacondaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/users/report_spam.json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "OAuth ") {
+			t.Errorf("Request must be signed")
+		}
+		if sn := r.FormValue("screen_name"); sn != "alice" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":[{"code":34,"message":"Sorry, that page does not exist."}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":5,"screen_name":"alice"}`)
+	})
+
+	u, err := c.ReportSpam("alice")
+	if err != nil || u.Id != 5 || u.ScreenName != "alice" {
+		t.Fatalf("Unexpected result: %+v, %v", u, err)
+	}
+	_, err = c.ReportSpam("nobody")
+	if n := newErrorNotification("Report", err); n.Code != 34 {
+		t.Fatalf("Error of API must be decoded: %+v", n)
+	}
+}
+
+func TestUnRetweet(t *testing.T) {
+	c := newTestAnacondaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/statuses/unretweet/7.json" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id":7,"retweeted":false}`)
+	})
+	if tw, err := c.UnRetweet(7, false); err != nil || tw.Id != 7 {
+		t.Fatalf("Unexpected result: %+v, %v", tw, err)
+	}
+}
+
+func TestDecodeQuotedTweets(t *testing.T) {
+	c := newTestAnacondaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/statuses/home_timeline.json" || r.FormValue("count") != "2" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		fmt.Fprint(w, `[
+			{"id": 3, "text": "RT @bob: look", "user": {"screen_name": "alice"},
+			 "retweeted_status": {"id": 2, "text": "look", "user": {"screen_name": "bob"},
+			  "quoted_status_id": 1, "quoted_status": {"id": 1, "text": "quoted", "user": {"screen_name": "carol"}}}}
+		]`)
+	})
+	tweets, err := c.GetHomeTimeline(url.Values{"count": {"2"}})
+	if err != nil || len(tweets) != 1 {
+		t.Fatalf("Unexpected result: %v, %v", tweets, err)
+	}
+	rt := tweets[0].RetweetedStatus
+	if rt == nil || rt.QuotedStatusID != 1 || rt.QuotedStatus == nil || rt.QuotedStatus.User.ScreenName != "carol" {
+		t.Fatalf("Quoted tweet of a retweet must be decoded: %+v", rt)
+	}
+}
+
+func TestDecodeExtendedTweets(t *testing.T) {
+	c := newTestAnacondaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"statuses": [
+			{"id": 2, "full_text": "long text #go $TWTR", "entities": {"hashtags": [{"indices": [10, 13], "text": "go"}],
+			 "symbols": [{"indices": [14, 19], "text": "TWTR"}]}},
+			{"id": 1, "text": "truncated…", "extended_tweet": {"full_text": "compatible long text",
+			 "display_text_range": [0, 20]}}
+		]}`)
+	})
+	result, err := c.GetSearch("long", nil)
+	if err != nil || len(result.Statuses) != 2 {
+		t.Fatalf("Unexpected result: %v, %v", result, err)
+	}
+	extended, compat := result.Statuses[0], result.Statuses[1]
+	if extended.FullText != "long text #go $TWTR" || len(extended.Entities.Hashtags) != 1 {
+		t.Fatalf("Full text must be decoded: %+v", extended)
+	}
+	if s := extended.Entities.Symbols; len(s) != 1 || s[0].Text != "TWTR" || s[0].Indices[0] != 14 {
+		t.Fatalf("Cashtags must be decoded: %+v", s)
+	}
+	if compat.ExtendedTweet == nil || compat.ExtendedTweet.FullText != "compatible long text" {
+		t.Fatalf("Extended tweet must be decoded: %+v", compat)
+	}
+}
+
+func TestUploadVideoStatus(t *testing.T) {
+	c := newTestAnacondaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/media/upload.json" || r.FormValue("media_id") != "40000" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+		}
+		switch r.FormValue("command") {
+		case "FINALIZE":
+			if r.Method != http.MethodPost {
+				t.Errorf("FINALIZE must be posted")
+			}
+			fmt.Fprint(w, `{"media_id":40000,"media_id_string":"40000",
+				"processing_info":{"state":"pending","check_after_secs":5}}`)
+		case "STATUS":
+			if r.Method != http.MethodGet {
+				t.Errorf("STATUS must be got")
+			}
+			fmt.Fprint(w, `{"media_id":40000,"media_id_string":"40000",
+				"processing_info":{"state":"failed","progress_percent":30,
+				"error":{"code":1,"name":"InvalidMedia","message":"Unsupported video format"}}}`)
+		}
+	})
+
+	video, err := c.UploadVideoFinalize("40000")
+	if err != nil || video.MediaIDString != "40000" || video.ProcessingInfo == nil ||
+		video.ProcessingInfo.State != "pending" || video.ProcessingInfo.CheckAfterSecs != 5 {
+		t.Fatalf("Unexpected result of FINALIZE: %+v, %v", video, err)
+	}
+	video, err = c.UploadVideoStatus("40000")
+	if err != nil || video.ProcessingInfo == nil || video.ProcessingInfo.State != "failed" ||
+		video.ProcessingInfo.Error == nil || video.ProcessingInfo.Error.Message != "Unsupported video format" {
+		t.Fatalf("Unexpected result of STATUS: %+v, %v", video, err)
+	}
+}
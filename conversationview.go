@@ -19,7 +19,6 @@ package main
 
 import (
 	"fmt"
-	"github.com/ChimeraCoder/anaconda"
 )
 
 const (
@@ -28,14 +27,16 @@ const (
 
 type conversationview struct {
 	*tweetview
+	client TwitterClient
 
-	loadPreviousTweetCh chan *anaconda.Tweet
+	loadPreviousTweetCh chan *Tweet
 }
 
-func newConversationview() *conversationview {
+func newConversationview(client TwitterClient) *conversationview {
 	return &conversationview{
 		tweetview:           newTweetview(),
-		loadPreviousTweetCh: make(chan *anaconda.Tweet),
+		client:              client,
+		loadPreviousTweetCh: make(chan *Tweet),
 	}
 }
 
@@ -82,9 +83,9 @@ func (cv *conversationview) loadTweet() {
 			cv.loadPreviousTweetCh <- t
 			tweet = t
 		} else {
-			t, err := api.GetTweet(id, nil)
+			t, err := cv.client.GetTweet(id, tweetValues())
 			if err != nil {
-				changeBufferState(fmt.Sprintf("Err:Load Tweet(ID:%d)", id))
+				notifyError(fmt.Sprintf("Load Tweet(ID:%d)", id), err)
 				break
 			}
 			cv.loadPreviousTweetCh <- &t
@@ -0,0 +1,322 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/mattn/go-runewidth"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dmConversation is direct messages between the user and partner, the oldest comes first
+type dmConversation struct {
+	partner  anaconda.User
+	messages []anaconda.DirectMessage
+}
+
+func (c *dmConversation) latest() anaconda.DirectMessage {
+	return c.messages[len(c.messages)-1]
+}
+
+func (c *dmConversation) add(dm anaconda.DirectMessage) bool {
+	for _, m := range c.messages {
+		if m.Id == dm.Id {
+			return false
+		}
+	}
+	c.messages = append(c.messages, dm)
+	sort.Slice(c.messages, func(i, j int) bool { return c.messages[i].Id < c.messages[j].Id })
+	return true
+}
+
+// dmLoad is a result of loading, received and sent messages are fetched separately
+type dmLoad struct {
+	received []anaconda.DirectMessage
+	sent     []anaconda.DirectMessage
+}
+
+// dmview shows conversations of direct messages, and messages of the opened one
+type dmview struct {
+	// conversations are sorted by their latest messages, the newest comes first
+	conversations  []*dmConversation
+	cursorPosition int
+	listScroll     int
+	// opened is the conversation being read, nil while showing the list
+	opened *dmConversation
+	// scroll is the number of lines hidden below the opened conversation
+	scroll int
+
+	newestReceivedID int64
+	newestSentID     int64
+	// loaded is false until the first successful load, which must not be noticed
+	// as new messages, failed loads leave it false so that the next one is the first
+	loaded bool
+
+	client  TwitterClient
+	loading lock
+	loadCh  chan dmLoad
+}
+
+func newDMview(client TwitterClient) *dmview {
+	return &dmview{
+		conversations: make([]*dmConversation, 0),
+		client:        client,
+		loadCh:        make(chan dmLoad),
+	}
+}
+
+// loadMessages fetches messages newer than the given IDs
+func (dv *dmview) loadMessages(receivedSinceID, sentSinceID int64) error {
+	if dv.loading.isLocking() {
+		return nil
+	}
+	dv.loading.lock()
+	defer dv.loading.unlock()
+	changeBufferState("Loading Direct Messages...")
+	values := func(sinceID int64) url.Values {
+		val := url.Values{}
+		val.Add("count", strconv.Itoa(CountDirectMessage))
+		if sinceID > 0 {
+			val.Add("since_id", strconv.FormatInt(sinceID, 10))
+		}
+		return val
+	}
+	received, err := dv.client.GetDirectMessages(values(receivedSinceID))
+	if err != nil {
+		notifyError("Loading Direct Messages", err)
+		return err
+	}
+	sent, err := dv.client.GetDirectMessagesSent(values(sentSinceID))
+	if err != nil {
+		notifyError("Loading Direct Messages", err)
+		return err
+	}
+	changeBufferState(fmt.Sprintf("Load!(%d messages)", len(received)+len(sent)))
+	dv.loadCh <- dmLoad{received: received, sent: sent}
+	return nil
+}
+
+func (dv *dmview) conversation(partner anaconda.User) *dmConversation {
+	for _, c := range dv.conversations {
+		if c.partner.Id == partner.Id {
+			return c
+		}
+	}
+	c := &dmConversation{partner: partner}
+	dv.conversations = append(dv.conversations, c)
+	return c
+}
+
+// addMessages merges loaded messages into conversations, and returns
+// screen names of senders of new received messages
+func (dv *dmview) addMessages(l dmLoad) []string {
+	var selected *dmConversation
+	if dv.cursorPosition < len(dv.conversations) {
+		selected = dv.conversations[dv.cursorPosition]
+	}
+	senders := make([]string, 0)
+	for _, dm := range l.received {
+		if dm.Id > dv.newestReceivedID {
+			dv.newestReceivedID = dm.Id
+		}
+		if dv.conversation(dm.Sender).add(dm) && dv.loaded {
+			found := false
+			for _, sn := range senders {
+				found = found || sn == dm.Sender.ScreenName
+			}
+			if !found {
+				senders = append(senders, dm.Sender.ScreenName)
+			}
+		}
+	}
+	for _, dm := range l.sent {
+		if dm.Id > dv.newestSentID {
+			dv.newestSentID = dm.Id
+		}
+		dv.conversation(dm.Recipient).add(dm)
+	}
+	dv.loaded = true
+	sort.SliceStable(dv.conversations, func(i, j int) bool {
+		return dv.conversations[i].latest().Id > dv.conversations[j].latest().Id
+	})
+	for i, c := range dv.conversations {
+		if c == selected {
+			dv.cursorPosition = i
+		}
+	}
+	return senders
+}
+
+func (dv *dmview) cursorUp() {
+	if dv.opened != nil {
+		dv.scrollBy(1)
+	} else if dv.cursorPosition > 0 {
+		dv.cursorPosition--
+	}
+}
+
+func (dv *dmview) cursorDown() {
+	if dv.opened != nil {
+		dv.scrollBy(-1)
+	} else if dv.cursorPosition < len(dv.conversations)-1 {
+		dv.cursorPosition++
+	}
+}
+
+// selected returns the opened conversation, or the one under the cursor
+func (dv *dmview) selected() *dmConversation {
+	if dv.opened != nil {
+		return dv.opened
+	}
+	if dv.cursorPosition < len(dv.conversations) {
+		return dv.conversations[dv.cursorPosition]
+	}
+	return nil
+}
+
+func (dv *dmview) open() {
+	if dv.opened == nil && dv.cursorPosition < len(dv.conversations) {
+		dv.opened = dv.conversations[dv.cursorPosition]
+		dv.scroll = 0
+	}
+}
+
+// close returns false when no conversation is opened
+func (dv *dmview) close() bool {
+	if dv.opened == nil {
+		return false
+	}
+	dv.opened = nil
+	return true
+}
+
+func (dv *dmview) pageHeight() int {
+	_, height := getTermSize()
+	return height - 3
+}
+
+func (dv *dmview) scrollBy(n int) {
+	dv.scroll += n
+	if max := len(dv.messageLines()) - dv.pageHeight(); dv.scroll > max {
+		dv.scroll = max
+	}
+	if dv.scroll < 0 {
+		dv.scroll = 0
+	}
+}
+
+func (dv *dmview) resetScroll() {
+	dv.scrollBy(0)
+}
+
+func formatDMTime(dm anaconda.DirectMessage) string {
+	t, err := time.Parse(time.RubyDate, dm.CreatedAt)
+	if err != nil {
+		return ""
+	}
+	return settings.TimeFormat.format(t, time.Now())
+}
+
+// messageLines formats the opened conversation for drawing
+func (dv *dmview) messageLines() []pagerLine {
+	if dv.opened == nil {
+		return nil
+	}
+	width, _ := getTermSize()
+	lines := make([]pagerLine, 0, len(dv.opened.messages)*3)
+	for _, dm := range dv.opened.messages {
+		color := ColorYellow
+		if dm.SenderId == user.ID {
+			color = ColorGreen
+		}
+		lines = append(lines, pagerLine{Text: "@" + dm.Sender.ScreenName + "  " + formatDMTime(dm), Color: color})
+		for _, s := range strings.Split(runewidth.Wrap(dm.Text, width-2), "\n") {
+			lines = append(lines, pagerLine{Text: "  " + s, Color: ColorWhite})
+		}
+		lines = append(lines, pagerLine{})
+	}
+	return lines
+}
+
+func (dv *dmview) draw() {
+	width, height := getTermSize()
+	fillLine(0, 0, ColorGray2)
+	if dv.opened != nil {
+		p := dv.opened.partner
+		drawText(fmt.Sprintf("Direct Messages with @%s [%s]", p.ScreenName, p.Name), 1, 0, ColorWhite, ColorGray2)
+		lines := dv.messageLines()
+		page := dv.pageHeight()
+		start := len(lines) - page - dv.scroll
+		if start < 0 {
+			start = 0
+		}
+		for y := 0; y < page && start+y < len(lines); y++ {
+			drawText(lines[start+y].Text, 0, y+1, lines[start+y].Color, ColorBackground)
+		}
+		return
+	}
+
+	drawText("Direct Messages", 1, 0, ColorWhite, ColorGray2)
+	if len(dv.conversations) == 0 {
+		drawText("No direct messages", 0, 1, ColorLowlight, ColorBackground)
+		return
+	}
+	// Each conversation takes two lines
+	rows := (height - 3) / 2
+	if rows < 1 {
+		rows = 1
+	}
+	if dv.cursorPosition < dv.listScroll {
+		dv.listScroll = dv.cursorPosition
+	} else if dv.cursorPosition >= dv.listScroll+rows {
+		dv.listScroll = dv.cursorPosition - rows + 1
+	}
+	for i := 0; i < rows && dv.listScroll+i < len(dv.conversations); i++ {
+		index := dv.listScroll + i
+		c := dv.conversations[index]
+		y := 1 + i*2
+		cursorColor := ColorBackground
+		if index == dv.cursorPosition {
+			cursorColor = ColorGray3
+		}
+		label := generateLabelColorByUserID(c.partner.Id)
+		drawText(" ", 0, y, ColorBackground, label)
+		drawText(" ", 0, y+1, ColorBackground, label)
+		drawText(" ", 1, y, ColorBackground, cursorColor)
+		drawText(" ", 1, y+1, ColorBackground, cursorColor)
+
+		latest := c.latest()
+		drawText("@"+c.partner.ScreenName, 3, y, label, ColorBackground)
+		x := 4 + runewidth.StringWidth(c.partner.ScreenName)
+		drawText(c.partner.Name, x+1, y, ColorWhite, ColorBackground)
+		t := formatDMTime(latest)
+		drawText(t, width-runewidth.StringWidth(t)-1, y, ColorGray1, ColorBackground)
+
+		text := strings.Replace(latest.Text, "\n", " ", -1)
+		if latest.SenderId == user.ID {
+			text = "You: " + text
+		}
+		text = runewidth.Truncate(text, width-4, "…")
+		drawText(text, 3, y+1, ColorWhite, ColorBackground)
+	}
+}
@@ -0,0 +1,135 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"github.com/ChimeraCoder/anaconda"
+	"testing"
+	"time"
+)
+
+func receiveDMs(t *testing.T, ch chan dmLoad) dmLoad {
+	select {
+	case l := <-ch:
+		return l
+	case <-time.After(time.Second):
+		t.Fatalf("direct messages were not delivered")
+	}
+	return dmLoad{}
+}
+
+func TestDMviewConversations(t *testing.T) {
+	initialize()
+	initializeState()
+	user = UserConfig{ID: 1, ScreenName: "me"}
+	me := anaconda.User{Id: 1, ScreenName: "me"}
+	alice := anaconda.User{Id: 2, ScreenName: "alice"}
+	bob := anaconda.User{Id: 3, ScreenName: "bob"}
+	client := newFakeClient()
+	client.received = []anaconda.DirectMessage{
+		newFakeDM(10, alice, me, "hi"),
+		newFakeDM(12, bob, me, "yo"),
+	}
+	client.sent = []anaconda.DirectMessage{newFakeDM(11, me, alice, "hello")}
+	dv := newDMview(client)
+
+	go dv.loadMessages(0, 0)
+	if senders := dv.addMessages(receiveDMs(t, dv.loadCh)); len(senders) != 0 {
+		t.Fatalf("The first load must not be noticed: %v", senders)
+	}
+	if len(dv.conversations) != 2 || dv.conversations[0].partner.ScreenName != "bob" {
+		t.Fatalf("Conversations must be grouped by partner, the newest first")
+	}
+	c := dv.conversations[1]
+	if len(c.messages) != 2 || c.messages[0].Id != 10 || c.messages[1].Id != 11 {
+		t.Fatalf("Messages of a conversation must be in order: %v", c.messages)
+	}
+
+	// The cursor stays on alice while her conversation moves to the top
+	dv.cursorDown()
+	client.received = append(client.received,
+		newFakeDM(13, alice, me, "are you there?"), newFakeDM(14, alice, me, "hey"))
+	go dv.loadMessages(dv.newestReceivedID, dv.newestSentID)
+	senders := dv.addMessages(receiveDMs(t, dv.loadCh))
+	if len(senders) != 1 || senders[0] != "alice" {
+		t.Fatalf("New messages must be noticed once per sender: %v", senders)
+	}
+	if dv.selected().partner.ScreenName != "alice" || dv.cursorPosition != 0 {
+		t.Fatalf("Cursor must follow the selected conversation")
+	}
+
+	// Messages already known are not duplicated
+	dv.addMessages(dmLoad{received: client.received})
+	if len(dv.conversations[0].messages) != 4 {
+		t.Fatalf("Expected 4 messages with alice, but %d", len(dv.conversations[0].messages))
+	}
+
+	dv.open()
+	if dv.selected() != dv.conversations[0] || !dv.close() || dv.close() {
+		t.Fatalf("Unexpected opening and closing of a conversation")
+	}
+}
+
+func TestDMviewFirstLoadFails(t *testing.T) {
+	initialize()
+	initializeState()
+	me := anaconda.User{Id: 1, ScreenName: "me"}
+	alice := anaconda.User{Id: 2, ScreenName: "alice"}
+	client := newFakeClient()
+	client.received = []anaconda.DirectMessage{newFakeDM(10, alice, me, "hi")}
+	dv := newDMview(client)
+
+	client.err = errors.New("network is down")
+	if err := dv.loadMessages(0, 0); err == nil || dv.loaded {
+		t.Fatalf("Failed load must not be the first load: %v", err)
+	}
+	client.err = nil
+	go dv.loadMessages(dv.newestReceivedID, dv.newestSentID)
+	if senders := dv.addMessages(receiveDMs(t, dv.loadCh)); len(senders) != 0 || !dv.loaded {
+		t.Fatalf("The first successful load must not be noticed: %v", senders)
+	}
+
+	client.received = append(client.received, newFakeDM(11, alice, me, "hey"))
+	go dv.loadMessages(dv.newestReceivedID, dv.newestSentID)
+	if senders := dv.addMessages(receiveDMs(t, dv.loadCh)); len(senders) != 1 {
+		t.Fatalf("New messages after the first successful load must be noticed: %v", senders)
+	}
+}
+
+func TestSendDirectMessage(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	view := newView(client, nil)
+
+	go view.sendDirectMessage("alice", "hello")
+	l := receiveDMs(t, view.dmview.loadCh)
+	if len(l.sent) != 1 || l.sent[0].Recipient.ScreenName != "alice" || l.sent[0].Text != "hello" {
+		t.Fatalf("Sent message must be added to the view: %+v", l)
+	}
+
+	view.buffer.confirmPrompt = "send to @alice?[Enter/C-g]"
+	if view.buffer.confirmText() != "send to @alice?[Enter/C-g]" {
+		t.Fatalf("DM composer must have its own confirm prompt")
+	}
+	view.exitConfirmMode()
+	if view.buffer.confirmText() != confirmText || view.buffer.inputTitle != "" {
+		t.Fatalf("Composer must be reset after sending")
+	}
+}
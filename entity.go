@@ -0,0 +1,240 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Classes of entities, each is drawn in its color
+const (
+	entityMention = iota + 1
+	entityHashtag
+	entityCashtag
+	entityURL
+	entityMedia
+)
+
+// entityPrefixes are the first characters of entities, used to check indices
+var entityPrefixes = map[int]string{
+	entityMention: "@＠",
+	entityHashtag: "#＃",
+	entityCashtag: "$",
+}
+
+func entityColor(class int) termbox.Attribute {
+	switch class {
+	case entityMention:
+		return ColorMention
+	case entityHashtag:
+		return ColorHashtag
+	case entityCashtag:
+		return ColorCashtag
+	case entityURL:
+		return ColorURL
+	case entityMedia:
+		return ColorMedia
+	}
+	return ColorWhite
+}
+
+// entitySpan is a range of runes of an entity in the text
+type entitySpan struct {
+	start, end int
+	class      int
+}
+
+// textEdit replaces from in the text with to, and moves indices of targets,
+// which are entities of from, to the replaced text
+type textEdit struct {
+	from    string
+	to      string
+	targets []*[]int
+
+	start, end int
+}
+
+// entityIndices returns indices of all entities of t, they are rune offsets of t.Text
+func entityIndices(t *Tweet) []*[]int {
+	indices := make([]*[]int, 0)
+	for _, e := range []*Entities{&t.Entities, &t.ExtendedEntities} {
+		for i := range e.Hashtags {
+			indices = append(indices, &e.Hashtags[i].Indices)
+		}
+		for i := range e.Urls {
+			indices = append(indices, &e.Urls[i].Indices)
+		}
+		for i := range e.User_mentions {
+			indices = append(indices, &e.User_mentions[i].Indices)
+		}
+		for i := range e.Media {
+			indices = append(indices, &e.Media[i].Indices)
+		}
+		for i := range e.Symbols {
+			indices = append(indices, &e.Symbols[i].Indices)
+		}
+	}
+	return indices
+}
+
+// entitySpans returns entities of t sorted by their offsets, entities whose
+// indices don't match t.Text are skipped
+func entitySpans(t *Tweet) []entitySpan {
+	runes := []rune(t.Text)
+	spans := make([]entitySpan, 0)
+	add := func(indices []int, class int) {
+		if len(indices) != 2 || indices[0] < 0 || indices[0] >= indices[1] || indices[1] > len(runes) {
+			return
+		}
+		if prefixes, ok := entityPrefixes[class]; ok && !strings.ContainsRune(prefixes, runes[indices[0]]) {
+			return
+		}
+		spans = append(spans, entitySpan{start: indices[0], end: indices[1], class: class})
+	}
+	for _, e := range []*Entities{&t.Entities, &t.ExtendedEntities} {
+		for _, m := range e.User_mentions {
+			add(m.Indices, entityMention)
+		}
+		for _, h := range e.Hashtags {
+			add(h.Indices, entityHashtag)
+		}
+		for _, s := range e.Symbols {
+			add(s.Indices, entityCashtag)
+		}
+		for _, u := range e.Urls {
+			add(u.Indices, entityURL)
+		}
+		for _, m := range e.Media {
+			add(m.Indices, entityMedia)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	// Drop duplicates, ex) media in both Entities and ExtendedEntities
+	result := spans[:0]
+	for _, s := range spans {
+		if len(result) == 0 || result[len(result)-1].end <= s.start {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// lineOffsets returns rune offsets of text where lines made by runewidth.Wrap start,
+// Wrap keeps all runes of text and only inserts line breaks
+func lineOffsets(text string, lines []string) []int {
+	runes := []rune(text)
+	offsets := make([]int, len(lines))
+	offset := 0
+	for i, l := range lines {
+		offsets[i] = offset
+		offset += utf8.RuneCountInString(l)
+		if offset < len(runes) && runes[offset] == '\n' {
+			offset++
+		}
+	}
+	return offsets
+}
+
+// drawTextWithEntities draws a line of text starting at the rune offset,
+// runes in spans are drawn in colors of their entities
+func drawTextWithEntities(str string, offset int, spans []entitySpan, x, y int, fg, bg termbox.Attribute) {
+	pos := 0
+	for _, c := range str {
+		color := fg
+		for len(spans) > 0 && spans[0].end <= offset {
+			spans = spans[1:]
+		}
+		if len(spans) > 0 && spans[0].start <= offset {
+			color = entityColor(spans[0].class)
+		}
+		termbox.SetCell(x+pos, y, c, color, bg)
+		pos += runewidth.RuneWidth(c)
+		offset++
+	}
+}
+
+// locate finds the range of from with indices of targets, or searches it
+// when indices are missing or do not match the text
+func (e *textEdit) locate(runes []rune) bool {
+	for _, p := range e.targets {
+		if idx := *p; len(idx) == 2 && 0 <= idx[0] && idx[0] <= idx[1] && idx[1] <= len(runes) &&
+			string(runes[idx[0]:idx[1]]) == e.from {
+			e.start, e.end = idx[0], idx[1]
+			return true
+		}
+	}
+	text := string(runes)
+	i := strings.Index(text, e.from)
+	if e.from == "" || i < 0 {
+		return false
+	}
+	e.start = len([]rune(text[:i]))
+	e.end = e.start + len([]rune(e.from))
+	return true
+}
+
+// shiftIndices moves indices at or after offset by delta
+func shiftIndices(indices []*[]int, offset, delta int) {
+	for _, p := range indices {
+		idx := *p
+		if len(idx) != 2 || idx[0] < offset {
+			continue
+		}
+		idx[0] += delta
+		idx[1] += delta
+		if idx[0] < 0 {
+			idx[0] = 0
+		}
+	}
+}
+
+// rewriteText applies edits to text, and keeps indices pointing the same entities
+func rewriteText(text string, edits []textEdit, indices []*[]int) string {
+	runes := []rune(text)
+	located := make([]textEdit, 0, len(edits))
+	for _, e := range edits {
+		if e.locate(runes) {
+			located = append(located, e)
+		}
+	}
+	// Edit from the end so that offsets of the rest are not changed
+	sort.Slice(located, func(i, j int) bool { return located[i].start > located[j].start })
+	end := len(runes)
+	for _, e := range located {
+		if e.end > end {
+			// Overlapped with the edit after this
+			continue
+		}
+		to := []rune(e.to)
+		runes = append(runes[:e.start], append(to, runes[e.end:]...)...)
+		shiftIndices(indices, e.end, len(to)-(e.end-e.start))
+		for _, p := range e.targets {
+			*p = []int{e.start, e.start + len(to)}
+		}
+		end = e.start
+	}
+	// Trim spaces left by removed text
+	trimmed := strings.TrimLeftFunc(string(runes), unicode.IsSpace)
+	shiftIndices(indices, 0, len([]rune(trimmed))-len(runes))
+	return strings.TrimRightFunc(trimmed, unicode.IsSpace)
+}
@@ -0,0 +1,98 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/ChimeraCoder/anaconda"
+	"reflect"
+	"testing"
+)
+
+func TestEntitySpans(t *testing.T) {
+	tweet := newFakeTweet(1, "alice", "$GOOG by @bob https://t.co/a #go mail@example.com")
+	e := &tweet.Entities
+	e.Symbols = append(e.Symbols, struct {
+		Indices []int
+		Text    string
+	}{Indices: []int{0, 5}, Text: "GOOG"})
+	e.User_mentions = append(e.User_mentions, struct {
+		Name        string
+		Indices     []int
+		Screen_name string
+		Id          int64
+		Id_str      string
+	}{Indices: []int{9, 13}, Screen_name: "bob"})
+	e.Urls = append(e.Urls, struct {
+		Indices      []int
+		Url          string
+		Display_url  string
+		Expanded_url string
+	}{Indices: []int{14, 28}, Url: "https://t.co/a", Display_url: "golang.org"})
+	e.Hashtags = append(e.Hashtags, struct {
+		Indices []int
+		Text    string
+	}{Indices: []int{29, 32}, Text: "go"}, struct {
+		Indices []int
+		Text    string
+	}{Indices: []int{1, 4}, Text: "stale"})
+	wrapTweet(&tweet)
+
+	expected := []struct {
+		text  string
+		class int
+	}{
+		{"$GOOG", entityCashtag}, {"@bob", entityMention}, {"golang.org", entityURL}, {"#go", entityHashtag},
+	}
+	spans := entitySpans(&tweet)
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d entities, but %+v", len(expected), spans)
+	}
+	runes := []rune(tweet.Text)
+	for i, s := range spans {
+		if text := string(runes[s.start:s.end]); text != expected[i].text || s.class != expected[i].class {
+			t.Fatalf("Expected %v, but %q (%d)", expected[i], text, s.class)
+		}
+	}
+}
+
+func TestLineOffsets(t *testing.T) {
+	text := "abcdefgh\nij\n\nklmn"
+	lines := []string{"abcde", "fgh", "ij", "", "klm", "n"}
+	offsets := lineOffsets(text, lines)
+	if !reflect.DeepEqual(offsets, []int{0, 5, 9, 12, 13, 16}) {
+		t.Fatalf("Unexpected offsets: %v", offsets)
+	}
+	runes := []rune(text)
+	for i, l := range lines {
+		if string(runes[offsets[i]:offsets[i]+len(l)]) != l {
+			t.Fatalf("Line %d does not start at %d", i, offsets[i])
+		}
+	}
+}
+
+func TestMediaSpanAfterRewriting(t *testing.T) {
+	tweet := newFakeTweet(2, "alice", "a&amp;b https://t.co/m")
+	media := anaconda.EntityMedia{Indices: []int{4, 18}, Url: "https://t.co/m", Display_url: "pic.twitter.com/x"}
+	tweet.Entities.Media = []anaconda.EntityMedia{media}
+	tweet.ExtendedEntities.Media = []anaconda.EntityMedia{media, media}
+	wrapTweet(&tweet)
+	spans := entitySpans(&tweet)
+	if len(spans) != 1 || string([]rune(tweet.Text)[spans[0].start:spans[0].end]) != "pic.twitter.com/x" {
+		t.Fatalf("Media must be a span of the display URL: %q %+v", tweet.Text, spans)
+	}
+}
@@ -0,0 +1,480 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/ChimeraCoder/anaconda"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClient is an in-memory TwitterClient for tests
+type fakeClient struct {
+	mutex      sync.Mutex
+	home       []Tweet
+	mentions   []Tweet
+	users      map[string][]Tweet
+	favorites  map[string][]Tweet
+	lists      map[int64][]Tweet
+	searchable []Tweet
+	received   []anaconda.DirectMessage
+	sent       []anaconda.DirectMessage
+	profiles   map[string]anaconda.User
+	posted     []Tweet
+	// postedValues are parameters of posted tweets
+	postedValues []url.Values
+	// uploaded is the number of bytes of uploaded media
+	uploaded int
+	muted    map[string]anaconda.User
+	calls    []string
+
+	// err is returned from every call when it is not nil
+	err error
+	// postLimit makes PostTweet fail after posting this number of tweets if not 0
+	postLimit int
+	// videoStates are states of processing an uploaded video returned from
+	// FINALIZE and following STATUS in order, the video is ready if it's empty
+	videoStates []string
+}
+
+var _ TwitterClient = (*fakeClient)(nil)
+
+var errFakeNotFound = errors.New("fake: not found")
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		users:     make(map[string][]Tweet),
+		favorites: make(map[string][]Tweet),
+		lists:     make(map[int64][]Tweet),
+		profiles:  make(map[string]anaconda.User),
+		muted:     make(map[string]anaconda.User),
+	}
+}
+
+func newFakeTweet(id int64, screenName, text string) Tweet {
+	return Tweet{Tweet: anaconda.Tweet{
+		Id:        id,
+		IdStr:     strconv.FormatInt(id, 10),
+		Text:      text,
+		CreatedAt: "Mon Jan 02 15:04:05 +0000 2006",
+		User: anaconda.User{
+			Id:         int64(len(screenName)),
+			ScreenName: screenName,
+			Name:       screenName,
+		},
+	}}
+}
+
+// filterTimeline applies since_id, max_id and count like the Twitter API does
+func filterTimeline(tweets []Tweet, v url.Values) []Tweet {
+	sorted := make([]Tweet, len(tweets))
+	copy(sorted, tweets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id > sorted[j].Id })
+
+	var sinceID, maxID int64
+	count := 20
+	if v != nil {
+		sinceID, _ = strconv.ParseInt(v.Get("since_id"), 10, 64)
+		maxID, _ = strconv.ParseInt(v.Get("max_id"), 10, 64)
+		if c, err := strconv.Atoi(v.Get("count")); err == nil {
+			count = c
+		}
+	}
+	result := make([]Tweet, 0, len(sorted))
+	for _, t := range sorted {
+		if sinceID > 0 && t.Id <= sinceID {
+			continue
+		}
+		if maxID > 0 && t.Id > maxID {
+			continue
+		}
+		result = append(result, t)
+		if len(result) >= count {
+			break
+		}
+	}
+	return result
+}
+
+func (c *fakeClient) record(call string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, call)
+	return c.err
+}
+
+// waitCall waits until call is requested by a goroutine
+func (c *fakeClient) waitCall(t *testing.T, call string) {
+	timeout := time.After(time.Second)
+	for {
+		c.mutex.Lock()
+		for _, cl := range c.calls {
+			if cl == call {
+				c.mutex.Unlock()
+				return
+			}
+		}
+		c.mutex.Unlock()
+		select {
+		case <-timeout:
+			t.Fatalf("%s was not requested", call)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (c *fakeClient) RateLimit(family string) (rateLimit, bool) {
+	return rateLimit{}, false
+}
+
+func (c *fakeClient) GetSelf(v url.Values) (anaconda.User, error) {
+	if err := c.record("GetSelf"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{ScreenName: user.ScreenName, Name: user.UserName, Id: user.ID}, nil
+}
+
+func (c *fakeClient) GetHomeTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.record("GetHomeTimeline"); err != nil {
+		return nil, err
+	}
+	return filterTimeline(c.home, v), nil
+}
+
+func (c *fakeClient) GetMentionsTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.record("GetMentionsTimeline"); err != nil {
+		return nil, err
+	}
+	return filterTimeline(c.mentions, v), nil
+}
+
+func (c *fakeClient) GetUserTimeline(v url.Values) ([]Tweet, error) {
+	if err := c.record("GetUserTimeline"); err != nil {
+		return nil, err
+	}
+	return filterTimeline(c.users[strings.ToLower(v.Get("screen_name"))], v), nil
+}
+
+func (c *fakeClient) GetFavorites(v url.Values) ([]Tweet, error) {
+	if err := c.record("GetFavorites"); err != nil {
+		return nil, err
+	}
+	return filterTimeline(c.favorites[strings.ToLower(v.Get("screen_name"))], v), nil
+}
+
+func (c *fakeClient) GetListTweets(listID int64, includeRTs bool, v url.Values) ([]Tweet, error) {
+	if err := c.record("GetListTweets"); err != nil {
+		return nil, err
+	}
+	return filterTimeline(c.lists[listID], v), nil
+}
+
+func (c *fakeClient) GetList(v url.Values) (anaconda.List, error) {
+	if err := c.record("GetList"); err != nil {
+		return anaconda.List{}, err
+	}
+	list := anaconda.List{Slug: v.Get("slug"), FullName: v.Get("slug")}
+	list.Id = int64(len(c.lists))
+	list.User.ScreenName = v.Get("owner_screen_name")
+	return list, nil
+}
+
+func (c *fakeClient) GetTweet(id int64, v url.Values) (Tweet, error) {
+	if err := c.record("GetTweet"); err != nil {
+		return Tweet{}, err
+	}
+	all := append(append([]Tweet{}, c.home...), c.mentions...)
+	for _, tweets := range c.users {
+		all = append(all, tweets...)
+	}
+	for _, t := range all {
+		if t.Id == id {
+			return t, nil
+		}
+	}
+	return Tweet{}, errFakeNotFound
+}
+
+func (c *fakeClient) GetUsersShow(screenName string, v url.Values) (anaconda.User, error) {
+	if err := c.record("GetUsersShow"); err != nil {
+		return anaconda.User{}, err
+	}
+	if u, ok := c.profiles[strings.ToLower(screenName)]; ok {
+		return u, nil
+	}
+	return anaconda.User{}, errFakeNotFound
+}
+
+// GetSearch finds tweets of searchable containing query, case-insensitively
+func (c *fakeClient) GetSearch(query string, v url.Values) (SearchResponse, error) {
+	if err := c.record("GetSearch"); err != nil {
+		return SearchResponse{}, err
+	}
+	matched := make([]Tweet, 0, len(c.searchable))
+	for _, t := range c.searchable {
+		if strings.Contains(strings.ToLower(t.Text), strings.ToLower(query)) {
+			matched = append(matched, t)
+		}
+	}
+	return SearchResponse{Statuses: filterTimeline(matched, v)}, nil
+}
+
+// filterMessages applies since_id like filterTimeline
+func filterMessages(messages []anaconda.DirectMessage, v url.Values) []anaconda.DirectMessage {
+	sinceID, _ := strconv.ParseInt(v.Get("since_id"), 10, 64)
+	result := make([]anaconda.DirectMessage, 0, len(messages))
+	for _, dm := range messages {
+		if dm.Id > sinceID {
+			result = append(result, dm)
+		}
+	}
+	return result
+}
+
+func (c *fakeClient) GetDirectMessages(v url.Values) ([]anaconda.DirectMessage, error) {
+	if err := c.record("GetDirectMessages"); err != nil {
+		return nil, err
+	}
+	return filterMessages(c.received, v), nil
+}
+
+func (c *fakeClient) GetDirectMessagesSent(v url.Values) ([]anaconda.DirectMessage, error) {
+	if err := c.record("GetDirectMessagesSent"); err != nil {
+		return nil, err
+	}
+	return filterMessages(c.sent, v), nil
+}
+
+func (c *fakeClient) PostDMToScreenName(text, screenName string) (anaconda.DirectMessage, error) {
+	if err := c.record("PostDMToScreenName"); err != nil {
+		return anaconda.DirectMessage{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	me := anaconda.User{Id: user.ID, ScreenName: user.ScreenName}
+	dm := newFakeDM(int64(20000+len(c.sent)), me, anaconda.User{ScreenName: screenName}, text)
+	c.sent = append(c.sent, dm)
+	return dm, nil
+}
+
+func newFakeDM(id int64, sender, recipient anaconda.User, text string) anaconda.DirectMessage {
+	return anaconda.DirectMessage{
+		Id:          id,
+		Text:        text,
+		CreatedAt:   "Mon Jan 02 15:04:05 +0000 2006",
+		Sender:      sender,
+		SenderId:    sender.Id,
+		Recipient:   recipient,
+		RecipientId: recipient.Id,
+	}
+}
+
+// GetMutedUsersIds returns two IDs per page to follow cursors
+func (c *fakeClient) GetMutedUsersIds(v url.Values) (anaconda.Cursor, error) {
+	if err := c.record("GetMutedUsersIds"); err != nil {
+		return anaconda.Cursor{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ids := make([]int64, 0, len(c.muted))
+	for _, u := range c.muted {
+		ids = append(ids, u.Id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	cursor, _ := strconv.ParseInt(v.Get("cursor"), 10, 64)
+	if cursor < 0 {
+		cursor = 0
+	}
+	result := anaconda.Cursor{Ids: ids[cursor:]}
+	if len(result.Ids) > 2 {
+		result.Ids = result.Ids[:2]
+		result.Next_cursor = cursor + 2
+	}
+	return result, nil
+}
+
+func (c *fakeClient) PostTweet(status string, v url.Values) (Tweet, error) {
+	if err := c.record("PostTweet"); err != nil {
+		return Tweet{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.postLimit > 0 && len(c.posted) >= c.postLimit {
+		return Tweet{}, errors.New("fake: over the posting limit")
+	}
+	t := newFakeTweet(int64(10000+len(c.posted)), user.ScreenName, status)
+	if v != nil {
+		t.InReplyToStatusID, _ = strconv.ParseInt(v.Get("in_reply_to_status_id"), 10, 64)
+	}
+	c.posted = append(c.posted, t)
+	c.postedValues = append(c.postedValues, v)
+	return t, nil
+}
+
+func (c *fakeClient) Retweet(id int64, trimUser bool) (Tweet, error) {
+	if err := c.record("Retweet"); err != nil {
+		return Tweet{}, err
+	}
+	return Tweet{Tweet: anaconda.Tweet{Id: id, Retweeted: true}}, nil
+}
+
+func (c *fakeClient) UnRetweet(id int64, trimUser bool) (Tweet, error) {
+	if err := c.record("UnRetweet"); err != nil {
+		return Tweet{}, err
+	}
+	return Tweet{Tweet: anaconda.Tweet{Id: id}}, nil
+}
+
+func (c *fakeClient) DeleteTweet(id int64, trimUser bool) (Tweet, error) {
+	if err := c.record("DeleteTweet"); err != nil {
+		return Tweet{}, err
+	}
+	return Tweet{Tweet: anaconda.Tweet{Id: id}}, nil
+}
+
+func (c *fakeClient) UploadMedia(base64String string) (anaconda.Media, error) {
+	if err := c.record("UploadMedia"); err != nil {
+		return anaconda.Media{}, err
+	}
+	return c.uploadMedia(base64String), nil
+}
+
+func (c *fakeClient) uploadMedia(base64String string) anaconda.Media {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	data, _ := base64.StdEncoding.DecodeString(base64String)
+	c.uploaded += len(data)
+	id := int64(30000 + len(c.calls))
+	return anaconda.Media{MediaID: id, MediaIDString: strconv.FormatInt(id, 10)}
+}
+
+func (c *fakeClient) UploadVideoInit(totalBytes int, mimeType string) (anaconda.ChunkedMedia, error) {
+	if err := c.record("UploadVideoInit"); err != nil {
+		return anaconda.ChunkedMedia{}, err
+	}
+	return anaconda.ChunkedMedia{MediaID: 40000, MediaIDString: "40000"}, nil
+}
+
+func (c *fakeClient) UploadVideoAppend(mediaIDString string, segmentIndex int, base64String string) error {
+	if err := c.record("UploadVideoAppend"); err != nil {
+		return err
+	}
+	c.uploadMedia(base64String)
+	return nil
+}
+
+func (c *fakeClient) UploadVideoFinalize(mediaIDString string) (VideoMedia, error) {
+	if err := c.record("UploadVideoFinalize"); err != nil {
+		return VideoMedia{}, err
+	}
+	return c.videoMedia(mediaIDString), nil
+}
+
+func (c *fakeClient) UploadVideoStatus(mediaIDString string) (VideoMedia, error) {
+	if err := c.record("UploadVideoStatus"); err != nil {
+		return VideoMedia{}, err
+	}
+	return c.videoMedia(mediaIDString), nil
+}
+
+func (c *fakeClient) videoMedia(mediaIDString string) VideoMedia {
+	id, _ := strconv.ParseInt(mediaIDString, 10, 64)
+	video := VideoMedia{VideoMedia: anaconda.VideoMedia{MediaID: id, MediaIDString: mediaIDString}}
+	if len(c.videoStates) > 0 {
+		video.ProcessingInfo = &ProcessingInfo{State: c.videoStates[0]}
+		c.videoStates = c.videoStates[1:]
+	}
+	return video
+}
+
+func (c *fakeClient) Favorite(id int64) (Tweet, error) {
+	if err := c.record("Favorite"); err != nil {
+		return Tweet{}, err
+	}
+	return Tweet{Tweet: anaconda.Tweet{Id: id, Favorited: true}}, nil
+}
+
+func (c *fakeClient) Unfavorite(id int64) (Tweet, error) {
+	if err := c.record("Unfavorite"); err != nil {
+		return Tweet{}, err
+	}
+	return Tweet{Tweet: anaconda.Tweet{Id: id}}, nil
+}
+
+func (c *fakeClient) FollowUser(screenName string) (anaconda.User, error) {
+	if err := c.record("FollowUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{ScreenName: screenName, Following: true}, nil
+}
+
+func (c *fakeClient) UnfollowUser(screenName string) (anaconda.User, error) {
+	if err := c.record("UnfollowUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{ScreenName: screenName}, nil
+}
+
+func (c *fakeClient) MuteUser(screenName string) (anaconda.User, error) {
+	if err := c.record("MuteUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	u := anaconda.User{Id: int64(len(screenName)), ScreenName: screenName}
+	c.muted[strings.ToLower(screenName)] = u
+	return u, nil
+}
+
+func (c *fakeClient) UnmuteUser(screenName string) (anaconda.User, error) {
+	if err := c.record("UnmuteUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.muted, strings.ToLower(screenName))
+	return anaconda.User{Id: int64(len(screenName)), ScreenName: screenName}, nil
+}
+
+func (c *fakeClient) BlockUser(screenName string) (anaconda.User, error) {
+	if err := c.record("BlockUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{Id: int64(len(screenName)), ScreenName: screenName}, nil
+}
+
+func (c *fakeClient) UnblockUser(screenName string) (anaconda.User, error) {
+	if err := c.record("UnblockUser"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{Id: int64(len(screenName)), ScreenName: screenName}, nil
+}
+
+func (c *fakeClient) ReportSpam(screenName string) (anaconda.User, error) {
+	if err := c.record("ReportSpam"); err != nil {
+		return anaconda.User{}, err
+	}
+	return anaconda.User{Id: int64(len(screenName)), ScreenName: screenName}, nil
+}
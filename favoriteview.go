@@ -19,7 +19,6 @@ package main
 
 import (
 	"fmt"
-	"net/url"
 	"strconv"
 )
 
@@ -27,9 +26,9 @@ type favoriteview struct {
 	*usertimelineview
 }
 
-func newFavoriteview() *favoriteview {
+func newFavoriteview(client TwitterClient) *favoriteview {
 	return &favoriteview{
-		usertimelineview: newUsertimelineview(),
+		usertimelineview: newUsertimelineview(client),
 	}
 }
 
@@ -42,24 +41,24 @@ func (fv *favoriteview) loadTweet(sinceID int64) {
 	changeBufferState("Loading...")
 
 	if _, ok := profilemap.get(fv.screenName); !ok {
-		u, err := api.GetUsersShow(fv.screenName, nil)
+		u, err := fv.client.GetUsersShow(fv.screenName, nil)
 		if err == nil {
 			profilemap.registerProfile(&u)
 		} else {
-			changeBufferState("Err:User profile Loading")
+			notifyError("User profile Loading", err)
 			return
 		}
 	}
 
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(20))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Favorite.New))
 	val.Add("screen_name", fv.screenName)
 	if sinceID > 0 {
 		val.Add("since_id", strconv.FormatInt(sinceID, 10))
 	}
-	timeline, err := api.GetFavorites(val)
+	timeline, err := fv.client.GetFavorites(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -73,15 +72,15 @@ func (fv *favoriteview) loadIntervalTweet(maxID int64) {
 	fv.loading.lock()
 	defer fv.loading.unlock()
 	changeBufferState("Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(CountTweet))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Favorite.Older))
 	val.Add("screen_name", fv.screenName)
 	if maxID > 0 {
 		val.Add("max_id", strconv.FormatInt(maxID, 10))
 	}
-	timeline, err := api.GetFavorites(val)
+	timeline, err := fv.client.GetFavorites(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -101,15 +100,12 @@ func (fv *favoriteview) addNewTweet(tss []tweetstatus) {
 	fv.tweetview.addNewTweet(tss)
 }
 
-func (fv *favoriteview) addIntervalTweet(tss []tweetstatus) {
+func (fv *favoriteview) addIntervalTweet(tweets []Tweet) {
 	if fv.userProfile == nil {
 		u, ok := profilemap.get(fv.screenName)
 		if ok {
 			fv.userProfile = u
 		}
 	}
-	if len(tss) == 0 {
-		return
-	}
-	fv.tweetview.addIntervalTweet(tss)
+	fv.tweetview.addIntervalTweet(tweets)
 }
@@ -0,0 +1,291 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of filter rules, used as the first argument of :mute
+const (
+	filterUser     = "user"
+	filterKeyword  = "keyword"
+	filterRegex    = "regex"
+	filterHashtag  = "hashtag"
+	filterSource   = "source"
+	filterRetweets = "retweets"
+	filterMedia    = "media"
+)
+
+var filterKinds = []string{filterUser, filterKeyword, filterRegex,
+	filterHashtag, filterSource, filterRetweets, filterMedia}
+
+// What is done to matched tweets
+const (
+	filterModeHide     = "hide"
+	filterModeCollapse = "collapse"
+)
+
+// filters are applied to tweets by wrapTweets
+var filters = newFilterSet()
+
+type filterRule struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// newFilterRule validates value and normalizes it for matching,
+// ex) "@Alice" of user is "alice"
+func newFilterRule(kind, value string) (*filterRule, error) {
+	value = strings.TrimSpace(value)
+	switch kind {
+	case filterMedia:
+		if value != "" {
+			return nil, errors.New("media takes no value")
+		}
+		return &filterRule{Kind: kind}, nil
+	case filterRegex:
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return &filterRule{Kind: kind, Value: value, re: re}, nil
+	case filterUser, filterRetweets:
+		value = strings.TrimPrefix(value, "@")
+		if !isScreenNameUsableStr(value) {
+			return nil, errors.New("invalid screen name: " + value)
+		}
+	case filterHashtag:
+		value = strings.TrimPrefix(value, "#")
+	case filterKeyword, filterSource:
+	default:
+		return nil, errors.New("unknown kind: " + kind)
+	}
+	if value == "" {
+		return nil, errors.New(kind + " needs value")
+	}
+	return &filterRule{Kind: kind, Value: strings.ToLower(value)}, nil
+}
+
+func (r *filterRule) String() string {
+	switch r.Kind {
+	case filterMedia:
+		return r.Kind
+	case filterUser, filterRetweets:
+		return r.Kind + " @" + r.Value
+	case filterHashtag:
+		return r.Kind + " #" + r.Value
+	}
+	return r.Kind + " " + r.Value
+}
+
+// match reports whether t is filtered, t may be a retweet
+func (r *filterRule) match(t *Tweet) bool {
+	original := t
+	if t.RetweetedStatus != nil {
+		original = t.RetweetedStatus
+	}
+	switch r.Kind {
+	case filterUser:
+		return strings.EqualFold(t.User.ScreenName, r.Value) ||
+			strings.EqualFold(original.User.ScreenName, r.Value)
+	case filterKeyword:
+		return strings.Contains(strings.ToLower(original.Text), r.Value)
+	case filterRegex:
+		return r.re.MatchString(original.Text)
+	case filterHashtag:
+		for _, h := range original.Entities.Hashtags {
+			if strings.EqualFold(h.Text, r.Value) {
+				return true
+			}
+		}
+	case filterSource:
+		// Source is a link like <a href="...">Twitter Web Client</a>
+		return strings.Contains(strings.ToLower(original.Source), r.Value)
+	case filterRetweets:
+		return t.RetweetedStatus != nil && strings.EqualFold(t.User.ScreenName, r.Value)
+	case filterMedia:
+		return len(original.ExtendedEntities.Media) > 0 || len(original.Entities.Media) > 0
+	}
+	return false
+}
+
+// filterSet is rules saved in filters.json, and users muted on the server
+type filterSet struct {
+	Mode  string        `json:"mode"`
+	Rules []*filterRule `json:"rules"`
+
+	mutedIDs map[int64]bool
+	mutex    sync.RWMutex
+}
+
+func newFilterSet() *filterSet {
+	return &filterSet{
+		Mode:     filterModeHide,
+		Rules:    make([]*filterRule, 0),
+		mutedIDs: make(map[int64]bool),
+	}
+}
+
+// compile restores unexported fields of rules decoded from JSON
+func (fs *filterSet) compile() error {
+	if fs.Mode != filterModeHide && fs.Mode != filterModeCollapse {
+		return fmt.Errorf("unknown mode %q", fs.Mode)
+	}
+	rules := make([]*filterRule, 0, len(fs.Rules))
+	for _, r := range fs.Rules {
+		rule, err := newFilterRule(r.Kind, r.Value)
+		if err != nil {
+			return fmt.Errorf("rule %q: %v", r.String(), err)
+		}
+		rules = append(rules, rule)
+	}
+	fs.Rules = rules
+	if fs.mutedIDs == nil {
+		fs.mutedIDs = make(map[int64]bool)
+	}
+	return nil
+}
+
+func (fs *filterSet) mode() string {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return fs.Mode
+}
+
+func (fs *filterSet) setMode(mode string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.Mode = mode
+}
+
+// add returns false when the same rule exists
+func (fs *filterSet) add(rule *filterRule) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for _, r := range fs.Rules {
+		if r.Kind == rule.Kind && r.Value == rule.Value {
+			return false
+		}
+	}
+	fs.Rules = append(fs.Rules, rule)
+	return true
+}
+
+// remove returns false when there is no such rule
+func (fs *filterSet) remove(rule *filterRule) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for i, r := range fs.Rules {
+		if r.Kind == rule.Kind && r.Value == rule.Value {
+			fs.Rules = append(fs.Rules[:i], fs.Rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// setMuted replaces the server mute list
+func (fs *filterSet) setMuted(ids []int64) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.mutedIDs = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		fs.mutedIDs[id] = true
+	}
+}
+
+func (fs *filterSet) setUserMuted(id int64, muted bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if muted {
+		fs.mutedIDs[id] = true
+	} else {
+		delete(fs.mutedIDs, id)
+	}
+}
+
+// match returns the reason why t is filtered, or "" when it is not
+// Tweets and retweets of the user are never filtered
+func (fs *filterSet) match(t *Tweet) string {
+	if t.User.Id == user.ID {
+		return ""
+	}
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	if fs.mutedIDs[t.User.Id] {
+		return "muted @" + t.User.ScreenName
+	}
+	if t.RetweetedStatus != nil && fs.mutedIDs[t.RetweetedStatus.User.Id] {
+		return "muted @" + t.RetweetedStatus.User.ScreenName
+	}
+	for _, r := range fs.Rules {
+		if r.match(t) {
+			return r.String()
+		}
+	}
+	return ""
+}
+
+func (fs *filterSet) lines() []pagerLine {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	lines := make([]pagerLine, 0, len(fs.Rules)+3)
+	lines = append(lines, pagerLine{Text: "[mode] " + fs.Mode, Color: ColorYellow})
+	lines = append(lines, pagerLine{Text: "[rules]", Color: ColorYellow})
+	rules := make([]string, 0, len(fs.Rules))
+	for _, r := range fs.Rules {
+		rules = append(rules, r.String())
+	}
+	sort.Strings(rules)
+	for _, r := range rules {
+		lines = append(lines, pagerLine{Text: "  " + r, Color: ColorWhite})
+	}
+	text := fmt.Sprintf("[server] %d muted users", len(fs.mutedIDs))
+	lines = append(lines, pagerLine{Text: text, Color: ColorYellow})
+	return lines
+}
+
+// applyFilter filters tweets again after rules or the mode are changed,
+// the cursor moves to the tweet above when its tweet gets hidden
+func (tv *tweetview) applyFilter() {
+	for i := range tv.tweets {
+		if tv.tweets[i].Content != nil {
+			tv.tweets[i].Filtered = filters.match(tv.tweets[i].Content)
+		}
+	}
+	if tv.cursorPosition >= len(tv.tweets) || !tv.tweets[tv.cursorPosition].hidden() {
+		return
+	}
+	if i := tv.visibleFrom(tv.cursorPosition, -1); i >= 0 {
+		tv.cursorPosition = i
+	} else if i := tv.visibleFrom(tv.cursorPosition, 1); i >= 0 {
+		tv.cursorPosition = i
+	}
+	if above := tv.visibleCount(tv.cursorPosition); tv.unread > above {
+		tv.unread = above
+	}
+	tv.resetScroll()
+}
@@ -0,0 +1,351 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/ChimeraCoder/anaconda"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func mustFilterRule(t *testing.T, kind, value string) *filterRule {
+	rule, err := newFilterRule(kind, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+// resetFilters clears filters in place, goroutines of former tests may use it
+func resetFilters() {
+	filters.mutex.Lock()
+	defer filters.mutex.Unlock()
+	filters.Mode = filterModeHide
+	filters.Rules = make([]*filterRule, 0)
+	filters.mutedIDs = make(map[int64]bool)
+}
+
+func TestFilterRuleMatch(t *testing.T) {
+	plain := newFakeTweet(1, "alice", "Hello #Golang world")
+	plain.Source = `<a href="http://example.com">BotClient</a>`
+	plain.Entities.Hashtags = append(plain.Entities.Hashtags, struct {
+		Indices []int
+		Text    string
+	}{Text: "Golang"})
+	original := newFakeTweet(2, "carol", "retweeted text")
+	retweet := newFakeTweet(3, "bob", "RT @carol: retweeted text")
+	retweet.RetweetedStatus = &original
+	media := newFakeTweet(4, "dave", "photo")
+	media.ExtendedEntities.Media = make([]anaconda.EntityMedia, 1)
+
+	testcase := []struct {
+		kind, value string
+		matched     []int64
+	}{
+		{filterUser, "@Alice", []int64{1}},
+		{filterUser, "carol", []int64{3}},
+		{filterKeyword, "HELLO", []int64{1}},
+		{filterRegex, `^retweeted|wor.d`, []int64{1, 3}},
+		{filterHashtag, "#golang", []int64{1}},
+		{filterSource, "botclient", []int64{1}},
+		{filterRetweets, "bob", []int64{3}},
+		{filterRetweets, "carol", nil},
+		{filterMedia, "", []int64{4}},
+	}
+	for _, c := range testcase {
+		rule := mustFilterRule(t, c.kind, c.value)
+		matched := make([]int64, 0)
+		for _, tw := range []*Tweet{&plain, &retweet, &media} {
+			if rule.match(tw) {
+				matched = append(matched, tw.Id)
+			}
+		}
+		if len(matched) != len(c.matched) {
+			t.Fatalf("%s: expected %v, but %v", rule, c.matched, matched)
+		}
+		for i := range matched {
+			if matched[i] != c.matched[i] {
+				t.Fatalf("%s: expected %v, but %v", rule, c.matched, matched)
+			}
+		}
+	}
+
+	for _, c := range [][2]string{{"unknown", "x"}, {filterRegex, "("}, {filterKeyword, " "}, {filterMedia, "x"}} {
+		if _, err := newFilterRule(c[0], c[1]); err == nil {
+			t.Fatalf("Rule %v must be invalid", c)
+		}
+	}
+}
+
+func TestWrapTweetsWithFilters(t *testing.T) {
+	defer resetFilters()
+	user = UserConfig{ID: 100, ScreenName: "me"}
+	resetFilters()
+	filters.add(mustFilterRule(t, filterKeyword, "spoiler"))
+	tweets := func() []Tweet {
+		own := newFakeTweet(3, "me", "my spoiler")
+		own.User.Id = user.ID
+		return []Tweet{newFakeTweet(1, "alice", "spoiler!"), newFakeTweet(2, "bob", "fine"), own}
+	}
+
+	tss := wrapTweets(tweets())
+	if len(tss) != 3 || !tss[0].hidden() || tss[1].hidden() || tss[2].hidden() {
+		t.Fatalf("Matched tweets must be hidden except own tweets: %+v", tss)
+	}
+	if tss[0].countLines() != 0 {
+		t.Fatalf("Hidden tweet must take no line")
+	}
+
+	filters.setMode(filterModeCollapse)
+	tss = wrapTweets(tweets())
+	if len(tss) != 3 || tss[0].Filtered != "keyword spoiler" || tss[1].Filtered != "" {
+		t.Fatalf("Matched tweets must be collapsed: %+v", tss)
+	}
+	if tss[0].countLines() != 1 {
+		t.Fatalf("Collapsed tweet must be a line")
+	}
+
+	// Users muted on the server are filtered by their IDs
+	filters.setMuted([]int64{int64(len("bob"))})
+	if r := filters.match(tss[1].Content); r != "muted @bob" {
+		t.Fatalf("Muted user must be filtered, but %q", r)
+	}
+}
+
+func TestApplyFilter(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	resetFilters()
+	tv := newTweetview()
+	tv.addNewTweet(wrapTweets([]Tweet{
+		newFakeTweet(4, "alice", "a"), newFakeTweet(3, "bob", "b"),
+		newFakeTweet(2, "alice", "c"), newFakeTweet(1, "carol", "d"),
+	}))
+	tv.cursorPosition = 2
+
+	filters.add(mustFilterRule(t, filterUser, "alice"))
+	filters.setMode(filterModeCollapse)
+	tv.applyFilter()
+	if len(tv.tweets) != 5 || tv.tweets[2].Filtered == "" || tv.cursorPosition != 2 {
+		t.Fatalf("Tweets must be collapsed in place")
+	}
+
+	filters.setMode(filterModeHide)
+	tv.applyFilter()
+	if len(tv.tweets) != 5 || tv.cursorPosition != 1 || tv.tweets[1].Content.Id != 3 {
+		t.Fatalf("Cursor must move to the tweet above the hidden one: %d of %d",
+			tv.cursorPosition, len(tv.tweets))
+	}
+	tv.cursorDown()
+	if tv.cursorPosition != 3 {
+		t.Fatalf("Cursor must skip hidden tweets, but %d", tv.cursorPosition)
+	}
+	tv.cursorMoveToTop()
+	if tv.cursorPosition != 1 {
+		t.Fatalf("Top must be the first tweet shown, but %d", tv.cursorPosition)
+	}
+
+	filters.add(mustFilterRule(t, filterKeyword, "b"))
+	filters.add(mustFilterRule(t, filterKeyword, "d"))
+	tv.applyFilter()
+	if tv.visibleCount(len(tv.tweets)) != 1 || !tv.tweets[tv.cursorPosition].ReloadMark {
+		t.Fatalf("Only the ReloadMark must be shown: cursor %d", tv.cursorPosition)
+	}
+}
+
+func TestUnmuteShowsHiddenTweets(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	initializeState()
+	resetFilters()
+	view := newView(newFakeClient(), nil)
+	tv := view.timelineview.tweetview
+	tv.addNewTweet(wrapTweets([]Tweet{
+		newFakeTweet(4, "alice", "a"), newFakeTweet(3, "bob", "spoiler"),
+		newFakeTweet(2, "alice", "c"), newFakeTweet(1, "carol", "spoiler"),
+	}))
+	shown := func() []int64 {
+		ids := make([]int64, 0)
+		for _, ts := range tv.tweets {
+			if ts.Content != nil && !ts.hidden() {
+				ids = append(ids, ts.Content.Id)
+			}
+		}
+		return ids
+	}
+	before := shown()
+
+	rule := mustFilterRule(t, filterKeyword, "spoiler")
+	view.mute(rule)
+	if ids := shown(); len(ids) != 2 || ids[0] != 4 || ids[1] != 2 {
+		t.Fatalf("Muted tweets must be hidden: %v", ids)
+	}
+	if tv.newestID() != 4 {
+		t.Fatalf("Hidden tweets must be kept for paging")
+	}
+	view.unmute(rule)
+	after := shown()
+	if len(after) != len(before) {
+		t.Fatalf("Unmuted tweets must be shown again: %v, but %v", before, after)
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("Unmuted tweets must be shown again: %v, but %v", before, after)
+		}
+	}
+}
+
+func TestLoadIntervalOfHiddenPage(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	resetFilters()
+	filters.add(mustFilterRule(t, filterUser, "bob"))
+	home := make([]Tweet, 0)
+	for id := int64(1); id <= 10; id++ {
+		sn := "alice"
+		if id >= 4 && id <= 8 {
+			sn = "bob"
+		}
+		home = append(home, newFakeTweet(id, sn, "hello"))
+	}
+	page := func(maxID int64) []Tweet {
+		return filterTimeline(home, url.Values{"max_id": {strconv.FormatInt(maxID, 10)}, "count": {"4"}})
+	}
+	tv := newTweetview()
+	tv.addNewTweet(wrapTweets(filterTimeline(home, url.Values{"count": {"2"}})))
+
+	// 9, 8, 7, 6 are loaded, but only 9 is shown which is already loaded
+	tv.addIntervalTweet(page(tv.gapMaxID(2)))
+	if len(tv.tweets) != 6 || tv.visibleCount(6) != 3 || tv.gapMaxID(5) != 6 {
+		t.Fatalf("Next page must start from the oldest hidden tweet, but %d", tv.gapMaxID(5))
+	}
+	tv.addIntervalTweet(page(tv.gapMaxID(5)))
+	if tv.visibleCount(len(tv.tweets)) != 4 || tv.tweets[7].Content.Id != 3 || tv.gapMaxID(8) != 3 {
+		t.Fatalf("Tweets below hidden ones must be loaded: %d tweets", len(tv.tweets))
+	}
+}
+
+func TestFilterCachedUserTimeline(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	initializeState()
+	resetFilters()
+	uv := newUsertimelineview(newFakeClient())
+	uv.setUserScreenName("alice")
+	uv.tweets = wrapTweets([]Tweet{
+		newFakeTweet(3, "alice", "spoiler"), newFakeTweet(2, "alice", "fine"), newFakeTweet(1, "alice", "spoiler"),
+	})
+	uv.setUserScreenName("bob")
+
+	filters.add(mustFilterRule(t, filterKeyword, "spoiler"))
+	uv.setUserScreenName("alice")
+	if uv.visibleCount(len(uv.tweets)) != 1 || uv.tweets[uv.cursorPosition].Content.Id != 2 {
+		t.Fatalf("Cached timeline must be filtered when it is restored: cursor %d", uv.cursorPosition)
+	}
+}
+
+func TestTurnToFilteredUserTimeline(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	initializeState()
+	resetFilters()
+	view := newView(newFakeClient(), nil)
+	view.usertimelineview.setUserScreenName("alice")
+	view.usertimelineview.tweets = wrapTweets([]Tweet{
+		newFakeTweet(3, "alice", "spoiler"), newFakeTweet(2, "alice", "fine"),
+	})
+	view.usertimelineview.setUserScreenName("bob")
+
+	filters.add(mustFilterRule(t, filterKeyword, "spoiler"))
+	view.turnUserTimelineMode("alice")
+	uv := view.usertimelineview
+	if uv.tweets[uv.cursorPosition].Content.Id != 2 {
+		t.Fatalf("Cursor must not be on a hidden tweet: cursor %d", uv.cursorPosition)
+	}
+}
+
+func TestFiltersStore(t *testing.T) {
+	fs := newFilterSet()
+	fs.setMode(filterModeCollapse)
+	fs.add(mustFilterRule(t, filterRegex, `(?i)^rt\b`))
+	fs.add(mustFilterRule(t, filterHashtag, "#Ringot"))
+	if fs.add(mustFilterRule(t, filterHashtag, "ringot")) {
+		t.Fatalf("Rules must not be duplicated")
+	}
+	store := newTweetStore(filepath.Join(t.TempDir(), "12345"))
+	if err := store.saveFilters(fs); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.loadFilters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.mode() != filterModeCollapse || len(loaded.Rules) != 2 {
+		t.Fatalf("Filters were not restored: %+v", loaded)
+	}
+	rt := newFakeTweet(1, "alice", "RT something")
+	if loaded.match(&rt) != `regex (?i)^rt\b` {
+		t.Fatalf("Regex must be compiled again after loading")
+	}
+	if !loaded.remove(mustFilterRule(t, filterHashtag, "RINGOT")) || len(loaded.Rules) != 1 {
+		t.Fatalf("Rule must be removed")
+	}
+}
+
+func TestMuteSync(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	initializeState()
+	resetFilters()
+	client := newFakeClient()
+	view := newView(client, nil)
+	for _, sn := range []string{"a", "bb", "ccc", "dddd", "eeeee"} {
+		client.MuteUser(sn)
+	}
+
+	go view.syncMutes()
+	select {
+	case <-view.filterCh:
+	case <-time.After(time.Second):
+		t.Fatalf("mutes were not synced")
+	}
+	if len(filters.mutedIDs) != 5 {
+		t.Fatalf("All pages of muted users must be loaded: %v", filters.mutedIDs)
+	}
+
+	view.unmute(mustFilterRule(t, filterUser, "bb"))
+	select {
+	case <-view.filterCh:
+	case <-time.After(time.Second):
+		t.Fatalf("unmuting was not notified")
+	}
+	if _, ok := client.muted["bb"]; ok || filters.mutedIDs[2] {
+		t.Fatalf("User must be unmuted on the server")
+	}
+
+	client.calls = nil
+	view.mute(mustFilterRule(t, filterUser, "frank"))
+	if len(filters.Rules) != 1 {
+		t.Fatalf("Rule must be added")
+	}
+	waitState(t, "Muted user @frank")
+	client.waitCall(t, "MuteUser")
+}
@@ -0,0 +1,296 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+)
+
+// imageClient downloads images to preview
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// PreviewSizeMax is the largest image downloaded to preview
+const PreviewSizeMax = 15 << 20
+
+// previewCell is a cell of a half block, fg is the upper pixel and bg is the lower one
+type previewCell struct {
+	fg, bg termbox.Attribute
+}
+
+// previewImage is an image of a preview, cells are cached for the size drawn last
+type previewImage struct {
+	url     string
+	img     image.Image
+	err     error
+	loading bool
+
+	cells [][]previewCell
+	cols  int
+	rows  int
+	mode  termbox.OutputMode
+}
+
+// imagePreview shows images of a tweet over the timeline, one at a time
+type imagePreview struct {
+	images []*previewImage
+	index  int
+}
+
+func newImagePreview(urls []string) *imagePreview {
+	images := make([]*previewImage, len(urls))
+	for i, url := range urls {
+		images[i] = &previewImage{url: url, loading: true}
+	}
+	return &imagePreview{images: images}
+}
+
+func (p *imagePreview) current() *previewImage {
+	return p.images[p.index]
+}
+
+func (p *imagePreview) next() {
+	p.index = (p.index + 1) % len(p.images)
+}
+
+func (p *imagePreview) previous() {
+	p.index = (p.index + len(p.images) - 1) % len(p.images)
+}
+
+// tweetMedia returns media of t, or of the retweeted tweet
+func tweetMedia(t *Tweet) []anaconda.EntityMedia {
+	if t.RetweetedStatus != nil {
+		t = t.RetweetedStatus
+	}
+	if len(t.ExtendedEntities.Media) > 0 {
+		return t.ExtendedEntities.Media
+	}
+	return t.Entities.Media
+}
+
+// previewURLs returns images of t, thumbnails are used for videos,
+// small size is enough for cells of the terminal
+func previewURLs(t *Tweet) []string {
+	urls := make([]string, 0)
+	for _, m := range tweetMedia(t) {
+		urls = append(urls, m.Media_url_https+":small")
+	}
+	return urls
+}
+
+// fetchImage downloads and decodes a JPEG, PNG or GIF (the first frame)
+func fetchImage(url string) (image.Image, error) {
+	res, err := imageClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+	img, _, err := image.Decode(io.LimitReader(res.Body, PreviewSizeMax))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode the image: %v", err)
+	}
+	return img, nil
+}
+
+// loadPreviewImages downloads all images of p at once
+func (view *view) loadPreviewImages(p *imagePreview) {
+	for _, pi := range p.images {
+		go func(pi *previewImage) {
+			img, err := fetchImage(pi.url)
+			view.updateCh <- func() {
+				pi.img, pi.err, pi.loading = img, err, false
+			}
+		}(pi)
+	}
+}
+
+// fitSize returns the number of cells to show an image of w x h pixels,
+// a cell has two pixels in a column and is about twice as high as it is wide
+func fitSize(w, h, maxCols, maxRows int) (int, int) {
+	if w <= 0 || h <= 0 || maxCols <= 0 || maxRows <= 0 {
+		return 0, 0
+	}
+	cols, rows := maxCols, (h*maxCols/w+1)/2
+	if rows > maxRows {
+		cols, rows = w*maxRows*2/h, maxRows
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return cols, rows
+}
+
+// averageColor returns the mean of pixels in r, transparent pixels are black
+func averageColor(img image.Image, r image.Rectangle) color.RGBA {
+	var sr, sg, sb, n uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			sr, sg, sb, n = sr+uint64(cr>>8), sg+uint64(cg>>8), sb+uint64(cb>>8), n+1
+		}
+	}
+	if n == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 0xff}
+}
+
+// halfBlocks downsamples img to cols x rows cells
+func halfBlocks(img image.Image, cols, rows int, mode termbox.OutputMode) [][]previewCell {
+	b := img.Bounds()
+	// box returns pixels of img covered by the pixel (x, y) of the cells
+	box := func(x, y int) image.Rectangle {
+		r := image.Rect(
+			b.Min.X+x*b.Dx()/cols, b.Min.Y+y*b.Dy()/(rows*2),
+			b.Min.X+(x+1)*b.Dx()/cols, b.Min.Y+(y+1)*b.Dy()/(rows*2))
+		// Each pixel takes at least a pixel of img when it's enlarged
+		if r.Dx() == 0 {
+			r.Max.X++
+		}
+		if r.Dy() == 0 {
+			r.Max.Y++
+		}
+		return r.Intersect(b)
+	}
+	cells := make([][]previewCell, rows)
+	for y := range cells {
+		cells[y] = make([]previewCell, cols)
+		for x := range cells[y] {
+			cells[y][x] = previewCell{
+				fg: colorAttribute(averageColor(img, box(x, y*2)), mode),
+				bg: colorAttribute(averageColor(img, box(x, y*2+1)), mode),
+			}
+		}
+	}
+	return cells
+}
+
+// Levels of the 6x6x6 color cube of the 256 colors, from 16 to 231
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// basicColors are RGB of the 8 colors of xterm, from black to white
+var basicColors = [8]color.RGBA{
+	{0, 0, 0, 0xff}, {205, 0, 0, 0xff}, {0, 205, 0, 0xff}, {205, 205, 0, 0xff},
+	{0, 0, 238, 0xff}, {205, 0, 205, 0xff}, {0, 205, 205, 0xff}, {229, 229, 229, 0xff},
+}
+
+func colorDistance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func nearestLevel(v uint8) int {
+	best := 0
+	for i, l := range cubeLevels {
+		if abs(int(v)-l) < abs(int(v)-cubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// paletteIndex returns the nearest color of the cube and the grayscale of the 256 colors
+func paletteIndex(c color.RGBA) int {
+	r, g, b := nearestLevel(c.R), nearestLevel(c.G), nearestLevel(c.B)
+	cube := color.RGBA{uint8(cubeLevels[r]), uint8(cubeLevels[g]), uint8(cubeLevels[b]), 0xff}
+	// Grays from 232 are 8, 18, ..., 238
+	gray := ((int(c.R)+int(c.G)+int(c.B))/3 - 3) / 10
+	if gray < 0 {
+		gray = 0
+	} else if gray > 23 {
+		gray = 23
+	}
+	v := uint8(8 + gray*10)
+	if colorDistance(c, color.RGBA{v, v, v, 0xff}) < colorDistance(c, cube) {
+		return 232 + gray
+	}
+	return 16 + r*36 + g*6 + b
+}
+
+// colorAttribute returns the attribute of c, only 8 colors are used in 16color theme
+func colorAttribute(c color.RGBA, mode termbox.OutputMode) termbox.Attribute {
+	if mode == termbox.Output256 {
+		return termbox.Attribute(paletteIndex(c) + 1)
+	}
+	best := 0
+	for i, b := range basicColors {
+		if colorDistance(c, b) < colorDistance(c, basicColors[best]) {
+			best = i
+		}
+	}
+	return termbox.ColorBlack + termbox.Attribute(best)
+}
+
+// draw fills the timeline area with the current image, centered
+func (p *imagePreview) draw() {
+	width, height := getTermSize()
+	pi := p.current()
+	fillLine(0, 0, ColorGray2)
+	title := fmt.Sprintf("Image %d/%d [←/→/Enter/C-g] ", p.index+1, len(p.images))
+	drawText(title, 1, 0, ColorYellow, ColorGray2)
+	x := 1 + runewidth.StringWidth(title)
+	drawText(runewidth.Truncate(pi.url, width-x-1, "…"), x, 0, ColorWhite, ColorGray2)
+	maxRows := height - 3
+	for y := 1; y <= maxRows; y++ {
+		fillLine(0, y, ColorBackground)
+	}
+	switch {
+	case pi.loading:
+		drawText("Loading...", 1, 1, ColorLowlight, ColorBackground)
+		return
+	case pi.err != nil:
+		drawText(pi.err.Error(), 1, 1, ColorRed, ColorBackground)
+		return
+	}
+	b := pi.img.Bounds()
+	cols, rows := fitSize(b.Dx(), b.Dy(), width, maxRows)
+	mode := termbox.SetOutputMode(termbox.OutputCurrent)
+	if pi.cells == nil || pi.cols != cols || pi.rows != rows || pi.mode != mode {
+		pi.cells, pi.cols, pi.rows, pi.mode = halfBlocks(pi.img, cols, rows, mode), cols, rows, mode
+	}
+	left := (width - cols) / 2
+	for y, line := range pi.cells {
+		for x, c := range line {
+			termbox.SetCell(left+x, 1+y, '▀', c.fg, c.bg)
+		}
+	}
+}
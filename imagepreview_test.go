@@ -0,0 +1,137 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaletteIndex(t *testing.T) {
+	testcase := []struct {
+		c        color.RGBA
+		expected int
+	}{
+		{color.RGBA{0, 0, 0, 0xff}, 16},
+		{color.RGBA{255, 0, 0, 0xff}, 196},
+		{color.RGBA{255, 255, 255, 0xff}, 231},
+		{color.RGBA{0, 100, 200, 0xff}, 16 + 0*36 + 1*6 + 4},
+		{color.RGBA{128, 128, 128, 0xff}, 244},
+		{color.RGBA{30, 30, 32, 0xff}, 234},
+	}
+	for _, c := range testcase {
+		if i := paletteIndex(c.c); i != c.expected {
+			t.Fatalf("%v: expected %d, but %d", c.c, c.expected, i)
+		}
+	}
+	if a := colorAttribute(color.RGBA{250, 10, 10, 0xff}, termbox.OutputNormal); a != termbox.ColorRed {
+		t.Fatalf("Nearest basic color must be used in 16color theme: %d", a)
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	testcase := []struct {
+		w, h, maxCols, maxRows int
+		cols, rows             int
+	}{
+		// Wide image fits the width, 80x40 pixels are 80x20 cells
+		{800, 400, 80, 40, 80, 20},
+		// Tall image fits the height
+		{400, 800, 80, 20, 20, 20},
+		{1, 1000, 80, 20, 1, 20},
+		{0, 10, 80, 20, 0, 0},
+	}
+	for _, c := range testcase {
+		if cols, rows := fitSize(c.w, c.h, c.maxCols, c.maxRows); cols != c.cols || rows != c.rows {
+			t.Fatalf("%dx%d in %dx%d: expected %dx%d, but %dx%d",
+				c.w, c.h, c.maxCols, c.maxRows, c.cols, c.rows, cols, rows)
+		}
+	}
+}
+
+// stripes returns an image with red upper half and blue lower half
+func stripes(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if y < h/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 0xff})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 0xff})
+			}
+		}
+	}
+	return img
+}
+
+func TestHalfBlocks(t *testing.T) {
+	red, blue := termbox.Attribute(196+1), termbox.Attribute(21+1)
+	cells := halfBlocks(stripes(10, 8), 2, 1, termbox.Output256)
+	if len(cells) != 1 || len(cells[0]) != 2 {
+		t.Fatalf("Unexpected size of cells: %v", cells)
+	}
+	if c := cells[0][1]; c.fg != red || c.bg != blue {
+		t.Fatalf("Upper pixel must be fg and lower one must be bg: %v", c)
+	}
+	// Small images are enlarged
+	cells = halfBlocks(stripes(1, 2), 3, 2, termbox.Output256)
+	if cells[0][2].fg != red || cells[1][0].bg != blue {
+		t.Fatalf("Enlarged image is broken: %v", cells)
+	}
+}
+
+func TestImagePreview(t *testing.T) {
+	initialize()
+	initializeState()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/image.png" {
+			w.Write([]byte("not an image"))
+			return
+		}
+		png.Encode(w, stripes(4, 4))
+	}))
+	defer server.Close()
+
+	view := newView(newFakeClient(), nil)
+	view.openPreview([]string{server.URL + "/image.png", server.URL + "/text"})
+	receiveUpdate(t, view.updateCh)
+	receiveUpdate(t, view.updateCh)
+	p := view.preview
+	if p.images[0].loading || p.images[0].err != nil || p.images[0].img.Bounds().Dx() != 4 {
+		t.Fatalf("Image must be decoded: %+v", p.images[0])
+	}
+	if p.images[1].err == nil {
+		t.Fatalf("Text must not be decoded")
+	}
+	p.previous()
+	if p.current() != p.images[1] {
+		t.Fatalf("Images must be switched in a cycle")
+	}
+	p.next()
+	defer initialize()
+	setTermSize(20, 10)
+	p.draw()
+	if p.images[0].cols != 14 || p.images[0].rows != 7 {
+		t.Fatalf("Cells must be cached for the size: %dx%d", p.images[0].cols, p.images[0].rows)
+	}
+}
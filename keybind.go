@@ -31,6 +31,12 @@ const (
 	KEYBIND_MODE_USER_TIMELINE
 	KEYBIND_MODE_USER_FAVORITE
 	KEYBIND_MODE_LIST_VIEW
+	KEYBIND_MODE_PAGER
+	KEYBIND_MODE_COMMAND
+	KEYBIND_MODE_SEARCH_VIEW
+	KEYBIND_MODE_DM_VIEW
+	KEYBIND_MODE_PICKER
+	KEYBIND_MODE_PREVIEW
 )
 
 type Action uint8
@@ -44,6 +50,7 @@ type keybind struct {
 const ( /* common event action list */
 	ACTION_LIKE_TWEET = iota + 1
 	ACTION_MENTION
+	ACTION_REPLY_ALL
 	ACTION_RETWEET
 	ACTION_OPEN_IMAGES
 	ACTION_OPEN_USER_PROFILE_IMAGE
@@ -62,6 +69,18 @@ const ( /* common event action list */
 	ACTION_QUIT
 	ACTION_OPEN_URL
 	ACTION_SHOW_HELP
+	ACTION_HALF_PAGE_DOWN
+	ACTION_HALF_PAGE_UP
+	ACTION_SEARCH
+	ACTION_SEARCH_NEXT
+	ACTION_SEARCH_PREVIOUS
+	ACTION_SWITCH_TIME_FORMAT
+	ACTION_BLOCK_USER
+	ACTION_MUTE_USER
+	ACTION_REPORT_USER
+	ACTION_DELETE_TWEET
+	ACTION_QUOTE_TWEET
+	ACTION_TURN_THREAD_MODE
 )
 const ( /* home timeline action list */
 	ACTION_LOAD_PREVIOUSE_TWEETS = iota + 1
@@ -81,6 +100,7 @@ const ( /* input mode action list */
 	ACTION_INSERT_NEW_LINE
 	ACTION_TEXT_CUT
 	ACTION_TEXT_PASTE
+	ACTION_ATTACH_FILE
 )
 const ( /* confirm mode action list */
 	ACTION_CANCEL_SUBMIT = iota + 1
@@ -101,6 +121,39 @@ const ( /* list mode actin list */
 	ACTION_LOAD_PREVIOUSE_LIST = iota + 1
 	ACTION_LOAD_NEW_LIST
 )
+const ( /* search view mode action list */
+	ACTION_LOAD_PREVIOUS_SEARCH = iota + 1
+	ACTION_LOAD_NEW_SEARCH
+)
+const ( /* direct message view mode action list */
+	ACTION_DM_CURSOR_UP = iota + 1
+	ACTION_DM_CURSOR_DOWN
+	ACTION_OPEN_DM_CONVERSATION
+	ACTION_CLOSE_DM_CONVERSATION
+	ACTION_LOAD_NEW_DMS
+	ACTION_COMPOSE_DM
+)
+const ( /* pager mode action list */
+	ACTION_SCROLL_UP = iota + 1
+	ACTION_SCROLL_DOWN
+	ACTION_SCROLL_PAGE_UP
+	ACTION_SCROLL_PAGE_DOWN
+	ACTION_SCROLL_TO_TOP
+	ACTION_SCROLL_TO_BOTTOM
+	ACTION_EXIT_PAGER_MODE
+)
+const ( /* URL picker action list */
+	ACTION_PICKER_UP = iota + 1
+	ACTION_PICKER_DOWN
+	ACTION_PICKER_OPEN
+	ACTION_PICKER_CLOSE
+)
+const ( /* image preview action list */
+	ACTION_PREVIEW_PREVIOUS = iota + 1
+	ACTION_PREVIEW_NEXT
+	ACTION_PREVIEW_OPEN
+	ACTION_PREVIEW_CLOSE
+)
 
 const NO_MOD = 0
 const NO_KEY = 0
@@ -111,6 +164,7 @@ const NO_ACTION = 0
 var commonKeybindList = []keybind{
 	{NO_MOD, termbox.KeyCtrlS, NO_CH, ACTION_TURN_INPUT_MODE},
 	{NO_MOD, termbox.KeyCtrlW, NO_CH, ACTION_MENTION},
+	{termbox.ModAlt, NO_KEY, 'w', ACTION_REPLY_ALL},
 	{NO_MOD, termbox.KeyCtrlF, NO_CH, ACTION_LIKE_TWEET},
 	{NO_MOD, termbox.KeyCtrlV, NO_CH, ACTION_RETWEET},
 	{NO_MOD, termbox.KeyCtrlO, NO_CH, ACTION_OPEN_URL},
@@ -122,6 +176,13 @@ var commonKeybindList = []keybind{
 	{NO_MOD, termbox.KeyArrowRight, NO_CH, ACTION_TURN_CONVERSATION_VIEW_MODE},
 	{termbox.ModAlt, NO_KEY, 'x', ACTION_TURN_COMMAND_MODE}, /* TODO: need ModAlt field */
 	{NO_MOD, termbox.KeyCtrlQ, NO_CH, ACTION_QUIT},
+	{NO_MOD, termbox.KeyCtrlT, NO_CH, ACTION_SWITCH_TIME_FORMAT},
+	{termbox.ModAlt, NO_KEY, 'b', ACTION_BLOCK_USER},
+	{termbox.ModAlt, NO_KEY, 'm', ACTION_MUTE_USER},
+	{termbox.ModAlt, NO_KEY, 'r', ACTION_REPORT_USER},
+	{termbox.ModAlt, NO_KEY, 'd', ACTION_DELETE_TWEET},
+	{termbox.ModAlt, NO_KEY, 'q', ACTION_QUOTE_TWEET},
+	{termbox.ModAlt, NO_KEY, 't', ACTION_TURN_THREAD_MODE},
 
 	{NO_MOD, termbox.KeyArrowUp, NO_CH, ACTION_NEXT_TWEET},
 	{NO_MOD, termbox.KeyArrowDown, NO_CH, ACTION_PREVIOUS_TWEET},
@@ -153,6 +214,7 @@ var inputModeKeybindList = []keybind{
 	{NO_MOD, termbox.KeyEnter, NO_CH, ACTION_INSERT_NEW_LINE},
 	{NO_MOD, termbox.KeyCtrlW, NO_CH, ACTION_TEXT_CUT},
 	{NO_MOD, termbox.KeyCtrlY, NO_CH, ACTION_TEXT_PASTE},
+	{NO_MOD, termbox.KeyCtrlT, NO_CH, ACTION_ATTACH_FILE},
 }
 
 var commandModeKeybindList = []keybind{
@@ -207,39 +269,64 @@ var listModeKeybindList = []keybind{
 	{NO_MOD, termbox.KeyCtrlR, NO_CH, ACTION_LOAD_NEW_LIST},
 }
 
-func (view *view) handleAction(ev termbox.Event, mode KeybindMode) Action {
-	var keybindList []keybind
-	switch mode {
-	case KEYBIND_MODE_COMMON:
-		keybindList = commonKeybindList
-	case KEYBIND_MODE_CONVERSATION:
-		keybindList = conversationModeKeybindList
-	case KEYBIND_MODE_HOME_TIMELINE:
-		keybindList = homeTimelineKeybindList
-	case KEYBIND_MODE_INPUT:
-		if !view.buffer.commanding {
-			keybindList = inputModeKeybindList
-
-		} else {
-			keybindList = commandModeKeybindList
-		}
-	case KEYBIND_MODE_CONFIRM:
-		keybindList = confirmModeKeybindList
-	case KEYBIND_MODE_MENTION_VIEW:
-		keybindList = mentionViewModeKeybindList
-	case KEYBIND_MODE_USER_TIMELINE:
-		keybindList = userTimelineModeKeybindList
-	case KEYBIND_MODE_USER_FAVORITE:
-		keybindList = favoriteModeKeybindList
-	case KEYBIND_MODE_LIST_VIEW:
-		keybindList = listModeKeybindList
-	}
-	for i := 0; i < len(keybindList); i++ {
-		if (ev.Mod == keybindList[i].Mod) &&
-			(ev.Key == keybindList[i].Key) &&
-			(ev.Ch == keybindList[i].Ch) {
-			return keybindList[i].Action
-		}
+var searchModeKeybindList = []keybind{
+	{NO_MOD, termbox.KeyEnter, NO_CH, ACTION_LOAD_PREVIOUS_SEARCH},
+	{NO_MOD, termbox.KeySpace, NO_CH, ACTION_LOAD_PREVIOUS_SEARCH},
+	{NO_MOD, termbox.KeyCtrlR, NO_CH, ACTION_LOAD_NEW_SEARCH},
+}
+
+var dmModeKeybindList = []keybind{
+	{NO_MOD, termbox.KeyArrowUp, NO_CH, ACTION_DM_CURSOR_UP},
+	{NO_MOD, termbox.KeyArrowDown, NO_CH, ACTION_DM_CURSOR_DOWN},
+	{NO_MOD, termbox.KeyEnter, NO_CH, ACTION_OPEN_DM_CONVERSATION},
+	{NO_MOD, termbox.KeyArrowRight, NO_CH, ACTION_OPEN_DM_CONVERSATION},
+	{NO_MOD, termbox.KeyArrowLeft, NO_CH, ACTION_CLOSE_DM_CONVERSATION},
+	{NO_MOD, termbox.KeyCtrlG, NO_CH, ACTION_CLOSE_DM_CONVERSATION},
+	{NO_MOD, NO_KEY, 'q', ACTION_CLOSE_DM_CONVERSATION},
+	{NO_MOD, termbox.KeyCtrlR, NO_CH, ACTION_LOAD_NEW_DMS},
+	{NO_MOD, termbox.KeyCtrlW, NO_CH, ACTION_COMPOSE_DM},
+	{NO_MOD, termbox.KeyCtrlS, NO_CH, ACTION_COMPOSE_DM},
+}
+
+var pagerModeKeybindList = []keybind{
+	{NO_MOD, termbox.KeyArrowUp, NO_CH, ACTION_SCROLL_UP},
+	{NO_MOD, termbox.KeyArrowDown, NO_CH, ACTION_SCROLL_DOWN},
+	{NO_MOD, termbox.KeyPgup, NO_CH, ACTION_SCROLL_PAGE_UP},
+	{NO_MOD, termbox.KeyPgdn, NO_CH, ACTION_SCROLL_PAGE_DOWN},
+	{NO_MOD, termbox.KeySpace, NO_CH, ACTION_SCROLL_PAGE_DOWN},
+	{NO_MOD, termbox.KeyHome, NO_CH, ACTION_SCROLL_TO_TOP},
+	{NO_MOD, termbox.KeyEnd, NO_CH, ACTION_SCROLL_TO_BOTTOM},
+	{NO_MOD, termbox.KeyArrowLeft, NO_CH, ACTION_EXIT_PAGER_MODE},
+	{NO_MOD, termbox.KeyCtrlG, NO_CH, ACTION_EXIT_PAGER_MODE},
+	{NO_MOD, NO_KEY, 'q', ACTION_EXIT_PAGER_MODE},
+}
+
+var pickerModeKeybindList = []keybind{
+	{NO_MOD, termbox.KeyArrowUp, NO_CH, ACTION_PICKER_UP},
+	{NO_MOD, termbox.KeyArrowDown, NO_CH, ACTION_PICKER_DOWN},
+	{NO_MOD, termbox.KeyEnter, NO_CH, ACTION_PICKER_OPEN},
+	{NO_MOD, termbox.KeyEsc, NO_CH, ACTION_PICKER_CLOSE},
+	{NO_MOD, termbox.KeyCtrlG, NO_CH, ACTION_PICKER_CLOSE},
+	{NO_MOD, NO_KEY, 'q', ACTION_PICKER_CLOSE},
+}
+
+var previewModeKeybindList = []keybind{
+	{NO_MOD, termbox.KeyArrowLeft, NO_CH, ACTION_PREVIEW_PREVIOUS},
+	{NO_MOD, termbox.KeyArrowRight, NO_CH, ACTION_PREVIEW_NEXT},
+	{NO_MOD, termbox.KeyEnter, NO_CH, ACTION_PREVIEW_OPEN},
+	{NO_MOD, termbox.KeyEsc, NO_CH, ACTION_PREVIEW_CLOSE},
+	{NO_MOD, termbox.KeyCtrlG, NO_CH, ACTION_PREVIEW_CLOSE},
+	{NO_MOD, NO_KEY, 'q', ACTION_PREVIEW_CLOSE},
+}
+
+// cancelKey cancels a pending key sequence
+var cancelKey = keybind{NO_MOD, termbox.KeyCtrlG, NO_CH, NO_ACTION}
+
+// handleAction returns the action bound to the key sequence being processed
+func (view *view) handleAction(mode KeybindMode) Action {
+	if mode == KEYBIND_MODE_INPUT && view.buffer.commanding {
+		mode = KEYBIND_MODE_COMMAND
 	}
-	return NO_ACTION
+	action, _ := view.keymap.lookup(mode, view.keys.current)
+	return action
 }
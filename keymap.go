@@ -0,0 +1,547 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeymapFile is read from ProfileDir to change keybindings
+const KeymapFile = "keymap.json"
+
+// keymapModes lists names of KeybindMode used in keymap.json, in the order of :keys
+var keymapModes = []struct {
+	name string
+	mode KeybindMode
+}{
+	{"common", KEYBIND_MODE_COMMON},
+	{"home_timeline", KEYBIND_MODE_HOME_TIMELINE},
+	{"mention_view", KEYBIND_MODE_MENTION_VIEW},
+	{"conversation", KEYBIND_MODE_CONVERSATION},
+	{"user_timeline", KEYBIND_MODE_USER_TIMELINE},
+	{"user_favorite", KEYBIND_MODE_USER_FAVORITE},
+	{"list_view", KEYBIND_MODE_LIST_VIEW},
+	{"search_view", KEYBIND_MODE_SEARCH_VIEW},
+	{"dm_view", KEYBIND_MODE_DM_VIEW},
+	{"pager", KEYBIND_MODE_PAGER},
+	{"picker", KEYBIND_MODE_PICKER},
+	{"preview", KEYBIND_MODE_PREVIEW},
+	{"input", KEYBIND_MODE_INPUT},
+	{"command", KEYBIND_MODE_COMMAND},
+	{"confirm", KEYBIND_MODE_CONFIRM},
+}
+
+// These modes fall back to KEYBIND_MODE_COMMON, so their keys must not shadow it
+// Pager and dm_view are not included, they replace moving keys of common on purpose
+var keymapViewModes = []KeybindMode{
+	KEYBIND_MODE_HOME_TIMELINE,
+	KEYBIND_MODE_MENTION_VIEW,
+	KEYBIND_MODE_CONVERSATION,
+	KEYBIND_MODE_USER_TIMELINE,
+	KEYBIND_MODE_USER_FAVORITE,
+	KEYBIND_MODE_LIST_VIEW,
+	KEYBIND_MODE_SEARCH_VIEW,
+}
+
+var inputActionNames = map[string]Action{
+	"move_left":         ACTION_MOVE_LEFT,
+	"move_right":        ACTION_MOVE_RIGHT,
+	"move_up":           ACTION_MOVE_UP,
+	"move_down":         ACTION_MOVE_DOWN,
+	"insert_space":      ACTION_INSERT_SPACE,
+	"exit_input_mode":   ACTION_EXIT_INPUT_MODE,
+	"delete_rune":       ACTION_DELETE_RUNE,
+	"move_line_top":     ACTION_MOVE_LINE_TOP,
+	"move_line_bottom":  ACTION_MOVE_LINE_BOTTOM,
+	"turn_confirm_mode": ACTION_TURN_CONFIRM_MODE,
+	"insert_new_line":   ACTION_INSERT_NEW_LINE,
+	"text_cut":          ACTION_TEXT_CUT,
+	"text_paste":        ACTION_TEXT_PASTE,
+	"attach_file":       ACTION_ATTACH_FILE,
+}
+
+// actionNames maps names used in keymap.json to actions of each mode
+var actionNames = map[KeybindMode]map[string]Action{
+	KEYBIND_MODE_COMMON: {
+		"like_tweet":                  ACTION_LIKE_TWEET,
+		"mention":                     ACTION_MENTION,
+		"reply_all":                   ACTION_REPLY_ALL,
+		"retweet":                     ACTION_RETWEET,
+		"open_images":                 ACTION_OPEN_IMAGES,
+		"next_tweet":                  ACTION_NEXT_TWEET,
+		"previous_tweet":              ACTION_PREVIOUS_TWEET,
+		"page_down":                   ACTION_PAGE_DOWN,
+		"page_up":                     ACTION_PAGE_UP,
+		"move_to_top_tweet":           ACTION_MOVE_TO_TOP_TWEET,
+		"move_to_bottom_tweet":        ACTION_MOVE_TO_BOTTOM_TWEET,
+		"turn_input_mode":             ACTION_TURN_INPUT_MODE,
+		"turn_command_mode":           ACTION_TURN_COMMAND_MODE,
+		"turn_home_timeline_mode":     ACTION_TURN_HOME_TIMELINE_MODE,
+		"turn_conversation_view_mode": ACTION_TURN_CONVERSATION_VIEW_MODE,
+		"turn_mention_view_mode":      ACTION_TURN_MENTION_VIEW_MODE,
+		"turn_user_timeline_mode":     ACTION_TURN_USER_TIMELINE_MODE,
+		"quit":                        ACTION_QUIT,
+		"open_url":                    ACTION_OPEN_URL,
+		"half_page_down":              ACTION_HALF_PAGE_DOWN,
+		"half_page_up":                ACTION_HALF_PAGE_UP,
+		"search":                      ACTION_SEARCH,
+		"search_next":                 ACTION_SEARCH_NEXT,
+		"search_previous":             ACTION_SEARCH_PREVIOUS,
+		"switch_time_format":          ACTION_SWITCH_TIME_FORMAT,
+		"block_user":                  ACTION_BLOCK_USER,
+		"mute_user":                   ACTION_MUTE_USER,
+		"report_user":                 ACTION_REPORT_USER,
+		"delete_tweet":                ACTION_DELETE_TWEET,
+		"quote_tweet":                 ACTION_QUOTE_TWEET,
+		"turn_thread_mode":            ACTION_TURN_THREAD_MODE,
+	},
+	KEYBIND_MODE_HOME_TIMELINE: {
+		"load_previous_tweets": ACTION_LOAD_PREVIOUSE_TWEETS,
+		"load_new_tweets":      ACTION_LOAD_NEW_TWEETS,
+	},
+	KEYBIND_MODE_MENTION_VIEW: {
+		"load_previous_mentions": ACTION_LOAD_PREVIOUSE_MENTIONS,
+		"load_new_mentions":      ACTION_LOAD_NEW_MENTIONS,
+	},
+	KEYBIND_MODE_CONVERSATION: {
+		"exit_conversation_mode": ACTION_EXIT_CONVERSATION_MODE,
+	},
+	KEYBIND_MODE_USER_TIMELINE: {
+		"load_previous_user_tweets": ACTION_LOAD_PREVIOUSE_USER_TWEETS,
+		"load_new_user_tweets":      ACTION_LOAD_NEW_USER_TWEETS,
+		"open_user_profile_image":   ACTION_OPEN_USER_PROFILE_IMAGE,
+	},
+	KEYBIND_MODE_USER_FAVORITE: {
+		"load_previous_user_tweets": ACTION_LOAD_PREVIOUSE_USER_TWEETS,
+		"load_new_user_tweets":      ACTION_LOAD_NEW_USER_TWEETS,
+	},
+	KEYBIND_MODE_LIST_VIEW: {
+		"load_previous_list": ACTION_LOAD_PREVIOUSE_LIST,
+		"load_new_list":      ACTION_LOAD_NEW_LIST,
+	},
+	KEYBIND_MODE_SEARCH_VIEW: {
+		"load_previous_search": ACTION_LOAD_PREVIOUS_SEARCH,
+		"load_new_search":      ACTION_LOAD_NEW_SEARCH,
+	},
+	KEYBIND_MODE_DM_VIEW: {
+		"cursor_up":          ACTION_DM_CURSOR_UP,
+		"cursor_down":        ACTION_DM_CURSOR_DOWN,
+		"open_conversation":  ACTION_OPEN_DM_CONVERSATION,
+		"close_conversation": ACTION_CLOSE_DM_CONVERSATION,
+		"load_new_messages":  ACTION_LOAD_NEW_DMS,
+		"compose_message":    ACTION_COMPOSE_DM,
+	},
+	KEYBIND_MODE_PAGER: {
+		"scroll_up":        ACTION_SCROLL_UP,
+		"scroll_down":      ACTION_SCROLL_DOWN,
+		"scroll_page_up":   ACTION_SCROLL_PAGE_UP,
+		"scroll_page_down": ACTION_SCROLL_PAGE_DOWN,
+		"scroll_to_top":    ACTION_SCROLL_TO_TOP,
+		"scroll_to_bottom": ACTION_SCROLL_TO_BOTTOM,
+		"exit_pager_mode":  ACTION_EXIT_PAGER_MODE,
+	},
+	KEYBIND_MODE_PICKER: {
+		"picker_up":    ACTION_PICKER_UP,
+		"picker_down":  ACTION_PICKER_DOWN,
+		"picker_open":  ACTION_PICKER_OPEN,
+		"picker_close": ACTION_PICKER_CLOSE,
+	},
+	KEYBIND_MODE_PREVIEW: {
+		"preview_previous": ACTION_PREVIEW_PREVIOUS,
+		"preview_next":     ACTION_PREVIEW_NEXT,
+		"preview_open":     ACTION_PREVIEW_OPEN,
+		"preview_close":    ACTION_PREVIEW_CLOSE,
+	},
+	KEYBIND_MODE_INPUT:   inputActionNames,
+	KEYBIND_MODE_COMMAND: inputActionNames,
+	KEYBIND_MODE_CONFIRM: {
+		"cancel_submit": ACTION_CANCEL_SUBMIT,
+		"submit_tweet":  ACTION_SUBMIT_TWEET,
+	},
+}
+
+// noActionName unbinds a key in keymap.json
+const noActionName = "none"
+
+// viPreset is the name of vi-style preset, it also enables count prefixes and "gg"
+const viPreset = "vi"
+
+// keymapPresets are applied before bindings of keymap.json
+var keymapPresets = map[string]map[string]map[string]string{
+	viPreset: {
+		"common": {
+			"j":   "previous_tweet",
+			"k":   "next_tweet",
+			"G":   "move_to_bottom_tweet",
+			"g g": "move_to_top_tweet",
+			"C-d": "half_page_down",
+			"C-u": "half_page_up",
+			"/":   "search",
+			"n":   "search_next",
+			"N":   "search_previous",
+			// C-d is used by half_page_down
+			"u": "turn_user_timeline_mode",
+		},
+		"dm_view": {
+			"j": "cursor_down",
+			"k": "cursor_up",
+		},
+		"pager": {
+			"j":   "scroll_down",
+			"k":   "scroll_up",
+			"G":   "scroll_to_bottom",
+			"g g": "scroll_to_top",
+			"C-d": "scroll_page_down",
+			"C-u": "scroll_page_up",
+		},
+	},
+}
+
+// Names of special keys used in key descriptors
+var keyNames = []struct {
+	name string
+	key  termbox.Key
+}{
+	{"F1", termbox.KeyF1}, {"F2", termbox.KeyF2}, {"F3", termbox.KeyF3},
+	{"F4", termbox.KeyF4}, {"F5", termbox.KeyF5}, {"F6", termbox.KeyF6},
+	{"F7", termbox.KeyF7}, {"F8", termbox.KeyF8}, {"F9", termbox.KeyF9},
+	{"F10", termbox.KeyF10}, {"F11", termbox.KeyF11}, {"F12", termbox.KeyF12},
+	{"Insert", termbox.KeyInsert},
+	{"Delete", termbox.KeyDelete},
+	{"Home", termbox.KeyHome},
+	{"End", termbox.KeyEnd},
+	{"PgUp", termbox.KeyPgup},
+	{"PgDn", termbox.KeyPgdn},
+	{"Up", termbox.KeyArrowUp},
+	{"Down", termbox.KeyArrowDown},
+	{"Left", termbox.KeyArrowLeft},
+	{"Right", termbox.KeyArrowRight},
+	{"Tab", termbox.KeyTab},
+	{"Enter", termbox.KeyEnter},
+	{"Esc", termbox.KeyEsc},
+	{"Space", termbox.KeySpace},
+	{"Backspace", termbox.KeyBackspace2},
+	{"C-Space", termbox.KeyCtrlSpace},
+	{"C-\\", termbox.KeyCtrlBackslash},
+	{"C-]", termbox.KeyCtrlRsqBracket},
+	{"C-6", termbox.KeyCtrl6},
+	{"C-/", termbox.KeyCtrlSlash},
+}
+
+var errEmptyKey = errors.New("empty key")
+
+// parseKey converts a key descriptor like "C-f", "M-x", "PgDn" or "j" to keybind
+func parseKey(desc string) (keybind, error) {
+	var kb keybind
+	s := desc
+	if strings.HasPrefix(s, "M-") && len(s) > 2 {
+		kb.Mod = termbox.ModAlt
+		s = s[2:]
+	}
+	for _, kn := range keyNames {
+		if s == kn.name {
+			kb.Key = kn.key
+			return kb, nil
+		}
+	}
+	if strings.HasPrefix(s, "C-") && len(s) == 3 {
+		c := s[2]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if c >= 'a' && c <= 'z' {
+			kb.Key = termbox.KeyCtrlA + termbox.Key(c-'a')
+			return kb, nil
+		}
+	}
+	if r, size := utf8.DecodeRuneInString(s); size == len(s) && r != utf8.RuneError && r > ' ' {
+		kb.Ch = r
+		return kb, nil
+	}
+	return kb, fmt.Errorf("unknown key %q", desc)
+}
+
+// formatKey is the inverse of parseKey
+func formatKey(kb keybind) string {
+	prefix := ""
+	if kb.Mod&termbox.ModAlt != 0 {
+		prefix = "M-"
+	}
+	if kb.Ch != 0 {
+		return prefix + string(kb.Ch)
+	}
+	for _, kn := range keyNames {
+		if kb.Key == kn.key {
+			return prefix + kn.name
+		}
+	}
+	if kb.Key >= termbox.KeyCtrlA && kb.Key <= termbox.KeyCtrlZ {
+		return prefix + "C-" + string(rune('a'+kb.Key-termbox.KeyCtrlA))
+	}
+	return fmt.Sprintf("%s<0x%X>", prefix, uint16(kb.Key))
+}
+
+func actionName(mode KeybindMode, action Action) string {
+	for name, a := range actionNames[mode] {
+		if a == action {
+			return name
+		}
+	}
+	return fmt.Sprintf("action(%d)", action)
+}
+
+func modeName(mode KeybindMode) string {
+	for _, m := range keymapModes {
+		if m.mode == mode {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// keymap holds keybindings of every KeybindMode as tries of key sequences
+type keymap struct {
+	bindings map[KeybindMode]*keyNode
+	preset   string
+}
+
+func newDefaultKeymap() *keymap {
+	defaults := map[KeybindMode][]keybind{
+		KEYBIND_MODE_COMMON:        commonKeybindList,
+		KEYBIND_MODE_CONVERSATION:  conversationModeKeybindList,
+		KEYBIND_MODE_HOME_TIMELINE: homeTimelineKeybindList,
+		KEYBIND_MODE_INPUT:         inputModeKeybindList,
+		KEYBIND_MODE_COMMAND:       commandModeKeybindList,
+		KEYBIND_MODE_CONFIRM:       confirmModeKeybindList,
+		KEYBIND_MODE_MENTION_VIEW:  mentionViewModeKeybindList,
+		KEYBIND_MODE_USER_TIMELINE: userTimelineModeKeybindList,
+		KEYBIND_MODE_USER_FAVORITE: favoriteModeKeybindList,
+		KEYBIND_MODE_LIST_VIEW:     listModeKeybindList,
+		KEYBIND_MODE_SEARCH_VIEW:   searchModeKeybindList,
+		KEYBIND_MODE_DM_VIEW:       dmModeKeybindList,
+		KEYBIND_MODE_PAGER:         pagerModeKeybindList,
+		KEYBIND_MODE_PICKER:        pickerModeKeybindList,
+		KEYBIND_MODE_PREVIEW:       previewModeKeybindList,
+	}
+	km := &keymap{bindings: make(map[KeybindMode]*keyNode, len(defaults))}
+	for mode, list := range defaults {
+		root := newKeyNode()
+		for _, kb := range list {
+			root.insert([]keybind{kb}, kb.Action)
+		}
+		km.bindings[mode] = root
+	}
+	return km
+}
+
+func sameKey(a, b keybind) bool {
+	return a.Mod == b.Mod && a.Key == b.Key && a.Ch == b.Ch
+}
+
+// lookup returns the action bound to seq, and whether seq is a prefix of longer bindings
+func (km *keymap) lookup(mode KeybindMode, seq []keybind) (Action, bool) {
+	root, ok := km.bindings[mode]
+	if !ok {
+		return NO_ACTION, false
+	}
+	node := root.find(seq)
+	if node == nil {
+		return NO_ACTION, false
+	}
+	return node.action, len(node.children) > 0
+}
+
+// bind replaces the binding of seq, NO_ACTION only removes it
+func (km *keymap) bind(mode KeybindMode, seq []keybind, action Action) {
+	root, ok := km.bindings[mode]
+	if !ok {
+		root = newKeyNode()
+		km.bindings[mode] = root
+	}
+	if action == NO_ACTION {
+		root.remove(seq)
+	} else {
+		root.insert(seq, action)
+	}
+}
+
+type keymapEntry struct {
+	seq    []keybind
+	action Action
+}
+
+func (km *keymap) entries(mode KeybindMode) []keymapEntry {
+	result := make([]keymapEntry, 0)
+	if root, ok := km.bindings[mode]; ok {
+		root.walk(nil, func(seq []keybind, action Action) {
+			result = append(result, keymapEntry{seq, action})
+		})
+	}
+	return result
+}
+
+// conflicts returns sequences which can't be typed,
+// because they are prefixes of other ones, or hide bindings of KEYBIND_MODE_COMMON
+func (km *keymap) conflicts() []string {
+	result := make([]string, 0)
+	for _, m := range keymapModes {
+		entries := km.entries(m.mode)
+		for _, e := range entries {
+			for _, other := range entries {
+				if len(e.seq) < len(other.seq) && isKeyPrefix(e.seq, other.seq) {
+					result = append(result, fmt.Sprintf("%s is bound to %s in %s and is a prefix of %s",
+						formatKeys(e.seq), actionName(m.mode, e.action), m.name, formatKeys(other.seq)))
+				}
+			}
+		}
+	}
+	common := km.entries(KEYBIND_MODE_COMMON)
+	for _, mode := range keymapViewModes {
+		for _, e := range km.entries(mode) {
+			for _, c := range common {
+				if isKeyPrefix(e.seq, c.seq) || isKeyPrefix(c.seq, e.seq) {
+					result = append(result, fmt.Sprintf("%s is bound to %s in %s and %s is %s in common",
+						formatKeys(e.seq), actionName(mode, e.action), modeName(mode),
+						formatKeys(c.seq), actionName(KEYBIND_MODE_COMMON, c.action)))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// keymapConfig is the format of keymap.json,
+// ex) {"preset": "vi", "bindings": {"common": {"C-f": "like_tweet", "C-w": "none"}}}
+type keymapConfig struct {
+	Preset   string                       `json:"preset"`
+	Bindings map[string]map[string]string `json:"bindings"`
+}
+
+// apply changes bindings as config says, and reports every wrong entry
+func (km *keymap) apply(config keymapConfig) error {
+	if config.Preset != "" {
+		preset, ok := keymapPresets[config.Preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q", config.Preset)
+		}
+		if err := km.applyBindings(preset); err != nil {
+			return err
+		}
+		km.preset = config.Preset
+	}
+	return km.applyBindings(config.Bindings)
+}
+
+func (km *keymap) applyBindings(bindings map[string]map[string]string) error {
+	problems := make([]string, 0)
+	for _, m := range keymapModes {
+		entries, ok := bindings[m.name]
+		if !ok {
+			continue
+		}
+		descs := make([]string, 0, len(entries))
+		for desc := range entries {
+			descs = append(descs, desc)
+		}
+		sort.Strings(descs)
+		bound := make(map[string]string)
+		for _, desc := range descs {
+			name := entries[desc]
+			seq, err := parseKeys(desc)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", m.name, err))
+				continue
+			}
+			action := Action(NO_ACTION)
+			if name != noActionName {
+				action, ok = actionNames[m.mode][name]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s: unknown action %q", m.name, name))
+					continue
+				}
+			}
+			key := formatKeys(seq)
+			if other, ok := bound[key]; ok && entries[other] != name {
+				problems = append(problems, fmt.Sprintf("%s: %s and %s are the same key", m.name, other, desc))
+				continue
+			}
+			bound[key] = desc
+			km.bind(m.mode, seq, action)
+		}
+	}
+	for name := range bindings {
+		if _, ok := keymapModeByName(name); !ok {
+			problems = append(problems, fmt.Sprintf("unknown mode %q", name))
+		}
+	}
+	problems = append(problems, km.conflicts()...)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func keymapModeByName(name string) (KeybindMode, bool) {
+	for _, m := range keymapModes {
+		if m.name == name {
+			return m.mode, true
+		}
+	}
+	return 0, false
+}
+
+// loadKeymap reads keymap.json on top of the default keybindings,
+// the defaults are used as they are if the file doesn't exist
+func loadKeymap(path string) (*keymap, error) {
+	km := newDefaultKeymap()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return km, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var config keymapConfig
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return nil, err
+	}
+	if err := km.apply(config); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// lines formats the active bindings for pagerview
+func (km *keymap) lines() []pagerLine {
+	lines := make([]pagerLine, 0, 100)
+	for _, m := range keymapModes {
+		lines = append(lines, pagerLine{Text: "[" + m.name + "]", Color: ColorYellow})
+		for _, e := range km.entries(m.mode) {
+			text := fmt.Sprintf("  %-12s %s", formatKeys(e.seq), actionName(m.mode, e.action))
+			lines = append(lines, pagerLine{Text: text, Color: ColorWhite})
+		}
+	}
+	return lines
+}
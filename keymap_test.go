@@ -0,0 +1,116 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	testcase := map[string]keybind{
+		"C-f":   {Key: termbox.KeyCtrlF},
+		"M-x":   {Mod: termbox.ModAlt, Ch: 'x'},
+		"PgDn":  {Key: termbox.KeyPgdn},
+		"j":     {Ch: 'j'},
+		"M-C-r": {Mod: termbox.ModAlt, Key: termbox.KeyCtrlR},
+		"Enter": {Key: termbox.KeyEnter},
+	}
+	for desc, expected := range testcase {
+		kb, err := parseKey(desc)
+		if err != nil || kb != expected {
+			t.Fatalf("%s: Expected %v, but %v (%v)", desc, expected, kb, err)
+		}
+		if formatKey(kb) != desc {
+			t.Fatalf("Expected %s, but %s", desc, formatKey(kb))
+		}
+	}
+	if _, err := parseKey("C-Foo"); err == nil {
+		t.Fatalf("Unknown key must be an error")
+	}
+}
+
+func TestKeymapApply(t *testing.T) {
+	km := newDefaultKeymap()
+	if conflicts := km.conflicts(); len(conflicts) != 0 {
+		t.Fatalf("Default keybindings must not conflict: %v", conflicts)
+	}
+	err := km.apply(keymapConfig{Bindings: map[string]map[string]string{
+		"common": {"C-l": "like_tweet", "C-f": "none"},
+		"input":  {"C-k": "text_cut"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Key: termbox.KeyCtrlL}}); a != ACTION_LIKE_TWEET {
+		t.Fatalf("C-l must be like_tweet, but %d", a)
+	}
+	if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Key: termbox.KeyCtrlF}}); a != NO_ACTION {
+		t.Fatalf("C-f must be unbound, but %d", a)
+	}
+	if a, _ := km.lookup(KEYBIND_MODE_COMMAND, []keybind{{Key: termbox.KeyCtrlK}}); a != NO_ACTION {
+		t.Fatalf("Bindings of input mode must not leak into command mode")
+	}
+}
+
+func TestKeymapSequence(t *testing.T) {
+	km := newDefaultKeymap()
+	err := km.apply(keymapConfig{Bindings: map[string]map[string]string{
+		"common": {"C-x": "none", "C-x C-f": "like_tweet", "C-x m": "turn_mention_view_mode"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := []keybind{{Key: termbox.KeyCtrlX}}
+	if a, isPrefix := km.lookup(KEYBIND_MODE_COMMON, prefix); a != NO_ACTION || !isPrefix {
+		t.Fatalf("C-x must be a prefix")
+	}
+	seq := append(prefix, keybind{Key: termbox.KeyCtrlF})
+	if a, isPrefix := km.lookup(KEYBIND_MODE_COMMON, seq); a != ACTION_LIKE_TWEET || isPrefix {
+		t.Fatalf("C-x C-f must be like_tweet, but %d", a)
+	}
+	// Single keys keep working
+	if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Key: termbox.KeyCtrlV}}); a != ACTION_RETWEET {
+		t.Fatalf("C-v must be retweet, but %d", a)
+	}
+	km.bind(KEYBIND_MODE_COMMON, seq, NO_ACTION)
+	if node := km.bindings[KEYBIND_MODE_COMMON].find(seq); node != nil {
+		t.Fatalf("Unbound sequence must be removed from trie")
+	}
+}
+
+func TestKeymapConflict(t *testing.T) {
+	testcase := []struct {
+		bindings map[string]map[string]string
+		problem  string
+	}{
+		{map[string]map[string]string{"common": {"C-r": "like_tweet"}}, "C-r is bound to load_new_tweets in home_timeline"},
+		{map[string]map[string]string{"input": {"Tab": "text_cut", "C-i": "text_paste"}}, "are the same key"},
+		{map[string]map[string]string{"common": {"C-l": "fly"}}, "unknown action"},
+		{map[string]map[string]string{"timeline": {"C-l": "quit"}}, "unknown mode"},
+		{map[string]map[string]string{"common": {"C-x C-f": "like_tweet"}}, "C-x is bound to turn_mention_view_mode in common and is a prefix of C-x C-f"},
+		{map[string]map[string]string{"home_timeline": {"C-z C-r": "load_new_tweets"}}, "C-z C-r is bound to load_new_tweets in home_timeline"},
+	}
+	for _, c := range testcase {
+		err := newDefaultKeymap().apply(keymapConfig{Bindings: c.bindings})
+		if err == nil || !strings.Contains(err.Error(), c.problem) {
+			t.Fatalf("Expected %q, but %v", c.problem, err)
+		}
+	}
+}
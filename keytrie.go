@@ -0,0 +1,152 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"sort"
+	"strings"
+)
+
+// keyNode is a node of trie of key sequences, ex) C-x -> C-f
+// Keys of children have no Action, the action is kept by the node
+type keyNode struct {
+	action   Action
+	children map[keybind]*keyNode
+}
+
+func newKeyNode() *keyNode {
+	return &keyNode{children: make(map[keybind]*keyNode)}
+}
+
+func eventKey(ev termbox.Event) keybind {
+	return keybind{Mod: ev.Mod, Key: ev.Key, Ch: ev.Ch}
+}
+
+func (n *keyNode) insert(seq []keybind, action Action) {
+	node := n
+	for _, kb := range seq {
+		kb.Action = NO_ACTION
+		child, ok := node.children[kb]
+		if !ok {
+			child = newKeyNode()
+			node.children[kb] = child
+		}
+		node = child
+	}
+	node.action = action
+}
+
+// remove unbinds seq, and drops nodes which no longer lead to any action
+func (n *keyNode) remove(seq []keybind) {
+	if len(seq) == 0 {
+		n.action = NO_ACTION
+		return
+	}
+	kb := seq[0]
+	kb.Action = NO_ACTION
+	child, ok := n.children[kb]
+	if !ok {
+		return
+	}
+	child.remove(seq[1:])
+	if child.action == NO_ACTION && len(child.children) == 0 {
+		delete(n.children, kb)
+	}
+}
+
+func (n *keyNode) find(seq []keybind) *keyNode {
+	node := n
+	for _, kb := range seq {
+		kb.Action = NO_ACTION
+		child, ok := node.children[kb]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+// walk calls fn with every bound sequence, in order of their descriptors
+func (n *keyNode) walk(prefix []keybind, fn func(seq []keybind, action Action)) {
+	if n.action != NO_ACTION {
+		seq := append([]keybind{}, prefix...)
+		fn(seq, n.action)
+	}
+	keys := make([]keybind, 0, len(n.children))
+	for kb := range n.children {
+		keys = append(keys, kb)
+	}
+	sort.Slice(keys, func(i, j int) bool { return formatKey(keys[i]) < formatKey(keys[j]) })
+	for _, kb := range keys {
+		n.children[kb].walk(append(prefix, kb), fn)
+	}
+}
+
+// parseKeys converts a descriptor of key sequence like "C-x C-f" to keybinds
+func parseKeys(desc string) ([]keybind, error) {
+	fields := strings.Fields(desc)
+	seq := make([]keybind, 0, len(fields))
+	for _, f := range fields {
+		kb, err := parseKey(f)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, kb)
+	}
+	if len(seq) == 0 {
+		return nil, errEmptyKey
+	}
+	return seq, nil
+}
+
+func formatKeys(seq []keybind) string {
+	descs := make([]string, len(seq))
+	for i, kb := range seq {
+		descs[i] = formatKey(kb)
+	}
+	return strings.Join(descs, " ")
+}
+
+// isKeyPrefix returns true when a equals b or a is a prefix of b
+func isKeyPrefix(a, b []keybind) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameKey(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// keySequence keeps keys typed so far for multi-key bindings
+type keySequence struct {
+	pending []keybind
+	// current is the sequence handlers are processing now
+	current []keybind
+	// generation invalidates timeouts of older sequences
+	generation int
+}
+
+func (ks *keySequence) clear() {
+	ks.pending = nil
+	ks.generation++
+}
@@ -30,42 +30,51 @@ import (
 type listview struct {
 	*tweetview
 	list anaconda.List
+	// cache keeps timelines of lists shown before, keyed by list IDs
+	cache  *timelineCache
+	lists  map[int64]anaconda.List
+	client TwitterClient
 
 	loading             lock
-	loadNewTweetCh      chan []anaconda.Tweet
-	loadIntervalTweetCh chan []anaconda.Tweet
+	loadNewTweetCh      chan []Tweet
+	loadIntervalTweetCh chan []Tweet
 }
 
-func newListview() *listview {
+func newListview(client TwitterClient) *listview {
 	return &listview{
 		tweetview:           newTweetview(),
-		loadNewTweetCh:      make(chan []anaconda.Tweet),
-		loadIntervalTweetCh: make(chan []anaconda.Tweet),
+		cache:               newTimelineCache(),
+		lists:               make(map[int64]anaconda.List),
+		client:              client,
+		loadNewTweetCh:      make(chan []Tweet),
+		loadIntervalTweetCh: make(chan []Tweet),
 	}
 }
 
-func (lv *listview) loadTweet(sinceID int64) {
+func (lv *listview) loadTweet(sinceID int64) error {
 	if lv.loading.isLocking() {
-		return
+		return nil
 	}
 	lv.loading.lock()
 	defer lv.loading.unlock()
 	changeBufferState("Loading List...")
 	if err := lv.fetchList(); err != nil {
-		changeBufferState("List Err")
-		return
+		notifyError("Loading List", err)
+		return err
 	}
-	val := url.Values{}
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.List.New))
 	if sinceID > 0 {
 		val.Add("since_id", strconv.FormatInt(sinceID, 10))
 	}
-	timeline, err := api.GetListTweets(lv.list.Id, true, val)
+	timeline, err := lv.client.GetListTweets(lv.list.Id, true, val)
 	if err != nil {
-		changeBufferState("Err:Loading List")
-		return
+		notifyError("Loading List", err)
+		return err
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
 	lv.loadNewTweetCh <- timeline
+	return nil
 }
 
 func (lv *listview) loadIntervalTweet(maxID int64) {
@@ -76,16 +85,17 @@ func (lv *listview) loadIntervalTweet(maxID int64) {
 	defer lv.loading.unlock()
 	changeBufferState("Loading List...")
 	if err := lv.fetchList(); err != nil {
-		changeBufferState("List Err")
+		notifyError("Loading List", err)
 		return
 	}
-	val := url.Values{}
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.List.Older))
 	if maxID > 0 {
 		val.Add("max_id", strconv.FormatInt(maxID, 10))
 	}
-	timeline, err := api.GetListTweets(lv.list.Id, true, val)
+	timeline, err := lv.client.GetListTweets(lv.list.Id, true, val)
 	if err != nil {
-		changeBufferState("Err:Loading List")
+		notifyError("Loading List", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -93,14 +103,42 @@ func (lv *listview) loadIntervalTweet(maxID int64) {
 }
 
 func (lv *listview) setListName(owner, name string) {
+	lv.storeCache()
 	lv.list = anaconda.List{Slug: name}
 	lv.list.User.ScreenName = owner
 	lv.tweets = []tweetstatus{tweetstatus{ReloadMark: true}}
+	for id, l := range lv.lists {
+		if strings.EqualFold(l.Slug, name) && strings.EqualFold(l.User.ScreenName, owner) {
+			lv.restoreCache(id)
+			break
+		}
+	}
 }
 
 func (lv *listview) setListID(id int64) {
+	lv.storeCache()
 	lv.list = anaconda.List{Id: id}
 	lv.tweets = []tweetstatus{tweetstatus{ReloadMark: true}}
+	lv.restoreCache(id)
+}
+
+// storeCache keeps the timeline of the list shown now
+func (lv *listview) storeCache() {
+	if lv.list.Id == 0 || lv.isEmpty() {
+		return
+	}
+	lv.cache.store(strconv.FormatInt(lv.list.Id, 10), lv.tweets)
+	lv.lists[lv.list.Id] = lv.list
+}
+
+func (lv *listview) restoreCache(id int64) {
+	if c, ok := lv.cache.take(strconv.FormatInt(id, 10)); ok {
+		lv.list = lv.lists[id]
+		lv.tweets = c
+		// Rules may have been changed while the timeline was cached
+		lv.cursorPosition = 0
+		lv.applyFilter()
+	}
 }
 
 func (lv *listview) fetchList() error {
@@ -114,7 +152,7 @@ func (lv *listview) fetchList() error {
 		} else {
 			return errors.New("can't fetch List information")
 		}
-		list, err := api.GetList(val)
+		list, err := lv.client.GetList(val)
 		if err != nil {
 			return err
 		}
@@ -19,7 +19,6 @@ package main
 
 import (
 	"fmt"
-	"net/url"
 	"strconv"
 )
 
@@ -27,31 +26,32 @@ type mentionview struct {
 	*timelineview
 }
 
-func newMentionview() *mentionview {
+func newMentionview(client TwitterClient) *mentionview {
 	return &mentionview{
-		timelineview: newTimelineview(),
+		timelineview: newTimelineview(client),
 	}
 }
 
-func (mv *mentionview) loadTweet(sinceID int64) {
+func (mv *mentionview) loadTweet(sinceID int64) error {
 	if mv.loading.isLocking() {
-		return
+		return nil
 	}
 	mv.loading.lock()
 	defer mv.loading.unlock()
 	changeBufferState("Mention Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(CountTweet))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Mention.New))
 	if sinceID > 0 {
 		val.Add("since_id", strconv.FormatInt(sinceID, 10))
 	}
-	timeline, err := api.GetMentionsTimeline(val)
+	timeline, err := mv.client.GetMentionsTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
-		return
+		notifyError("Loading", err)
+		return err
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
 	mv.loadNewTweetCh <- timeline
+	return nil
 }
 
 func (mv *mentionview) loadIntervalTweet(maxID int64) {
@@ -61,14 +61,14 @@ func (mv *mentionview) loadIntervalTweet(maxID int64) {
 	mv.loading.lock()
 	defer mv.loading.unlock()
 	changeBufferState("Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(CountTweet))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Mention.Older))
 	if maxID > 0 {
 		val.Add("max_id", strconv.FormatInt(maxID, 10))
 	}
-	timeline, err := api.GetMentionsTimeline(val)
+	timeline, err := mv.client.GetMentionsTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
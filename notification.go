@@ -0,0 +1,183 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/nsf/termbox-go"
+	"sync"
+	"time"
+)
+
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarning
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityWarning:
+		return "WARN"
+	case severityError:
+		return "ERROR"
+	}
+	return "INFO"
+}
+
+func (s severity) color() termbox.Attribute {
+	switch s {
+	case severityWarning:
+		return ColorYellow
+	case severityError:
+		return ColorRed
+	}
+	return ColorWhite
+}
+
+// notification is a message shown in the state line and kept in the message log
+type notification struct {
+	Severity severity
+	// Action is what was being done when it happened, ex) "Favorite"
+	Action  string
+	Message string
+	// Code is the error code returned by Twitter API, 0 if there is none
+	Code int
+	Time time.Time
+	// Logged keeps an info in the message log, warnings and errors are always kept,
+	// and states like "Loading..." are not
+	Logged bool
+}
+
+// newErrorNotification makes a notification from err,
+// keeping the details of API errors
+func newErrorNotification(action string, err error) notification {
+	n := notification{Severity: severityError, Action: action}
+	switch e := err.(type) {
+	case *rateLimitError:
+		n.Severity = severityWarning
+		n.Message = e.Error()
+	case *anaconda.ApiError:
+		if len(e.Decoded.Errors) > 0 {
+			n.Code = e.Decoded.Errors[0].Code
+			n.Message = e.Decoded.Errors[0].Message
+		} else {
+			n.Message = fmt.Sprintf("HTTP %d", e.StatusCode)
+		}
+	case nil:
+	default:
+		n.Message = e.Error()
+	}
+	return n
+}
+
+// String returns the text shown in the state line
+func (n notification) String() string {
+	s := n.Message
+	if n.Action != "" {
+		s = n.Action
+		if n.Message != "" {
+			s += ": " + n.Message
+		}
+	}
+	if n.Code != 0 {
+		s += fmt.Sprintf(" (code %d)", n.Code)
+	}
+	if n.Severity == severityError {
+		s = "Err:" + s
+	}
+	return s
+}
+
+// messageLog keeps the latest notifications to be inspected by :messages
+type messageLog struct {
+	entries []notification
+	max     int
+	mutex   sync.RWMutex
+}
+
+func newMessageLog(max int) *messageLog {
+	return &messageLog{
+		entries: make([]notification, 0, max),
+		max:     max,
+	}
+}
+
+func (ml *messageLog) add(n notification) {
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	if len(ml.entries) >= ml.max {
+		copy(ml.entries, ml.entries[1:])
+		ml.entries = ml.entries[:len(ml.entries)-1]
+	}
+	ml.entries = append(ml.entries, n)
+}
+
+func (ml *messageLog) list() []notification {
+	ml.mutex.RLock()
+	defer ml.mutex.RUnlock()
+	result := make([]notification, len(ml.entries))
+	copy(result, ml.entries)
+	return result
+}
+
+// lines formats the log for pagerview, the newest message comes first
+func (ml *messageLog) lines() []pagerLine {
+	entries := ml.list()
+	lines := make([]pagerLine, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		n := entries[i]
+		text := fmt.Sprintf("%s %-5s %s", n.Time.Format("15:04:05"), n.Severity, n.String())
+		lines = append(lines, pagerLine{Text: text, Color: n.Severity.color()})
+	}
+	if len(lines) == 0 {
+		lines = append(lines, pagerLine{Text: "No messages", Color: ColorLowlight})
+	}
+	return lines
+}
+
+// notify shows n in the state line for a while, and records it unless it's a state
+func notify(n notification) {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if messages != nil && (n.Severity != severityInfo || n.Logged) {
+		messages.add(n)
+	}
+	// The goroutine must not read globals after notify returns
+	states, clears, seconds := stateCh, stateClearCh, settings.StatusClearSeconds
+	go func() {
+		states <- n
+		if n.Message != "" || n.Action != "" {
+			clears <- seconds
+		}
+	}()
+}
+
+// notifyError reports err occurred while doing action
+func notifyError(action string, err error) {
+	notify(newErrorNotification(action, err))
+}
+
+// notifyWarning reports a problem which is not an error of API, ex) wrong usage of commands
+func notifyWarning(action, message string) {
+	notify(notification{Severity: severityWarning, Action: action, Message: message})
+}
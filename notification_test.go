@@ -0,0 +1,98 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"github.com/ChimeraCoder/anaconda"
+	"strings"
+	"testing"
+)
+
+func TestErrorNotification(t *testing.T) {
+	apiErr := &anaconda.ApiError{
+		StatusCode: 403,
+		Decoded: anaconda.TwitterErrorResponse{
+			Errors: []anaconda.TwitterError{{Code: 139, Message: "You have already favorited this status."}},
+		},
+	}
+	n := newErrorNotification("Favorite", apiErr)
+	if n.Severity != severityError || n.Code != 139 {
+		t.Fatalf("API error details were lost: %+v", n)
+	}
+	expected := "Err:Favorite: You have already favorited this status. (code 139)"
+	if n.String() != expected {
+		t.Fatalf("Expected %q, but %q", expected, n.String())
+	}
+
+	n = notification{Severity: severityInfo, Message: "Tweet!"}
+	if n.String() != "Tweet!" {
+		t.Fatalf("Info must be shown as it is, but %q", n.String())
+	}
+}
+
+func TestMessageLog(t *testing.T) {
+	ml := newMessageLog(3)
+	for _, s := range []string{"a", "b", "c", "d"} {
+		ml.add(notification{Severity: severityWarning, Message: s})
+	}
+	entries := ml.list()
+	if len(entries) != 3 || entries[0].Message != "b" || entries[2].Message != "d" {
+		t.Fatalf("Oldest messages must be dropped: %+v", entries)
+	}
+	lines := ml.lines()
+	if !strings.HasSuffix(lines[0].Text, "d") || lines[0].Color != ColorYellow {
+		t.Fatalf("The newest message must come first: %+v", lines[0])
+	}
+}
+
+func TestNotifyLog(t *testing.T) {
+	initializeState()
+	changeBufferState("Loading...")
+	notify(notification{Action: "New Direct Messages", Message: "from @alice", Logged: true})
+	notifyWarning("search", "previous search is still loading")
+	notifyError("Favorite", errors.New("network is down"))
+	entries := messages.list()
+	if len(entries) != 3 || entries[0].Action != "New Direct Messages" {
+		t.Fatalf("States must not be logged: %+v", entries)
+	}
+}
+
+func TestPagerviewScroll(t *testing.T) {
+	initialize()
+	pv := newPagerview()
+	pv.open("Test", func() []pagerLine {
+		lines := make([]pagerLine, 100)
+		for i := range lines {
+			lines[i] = pagerLine{Text: strings.Repeat("x", 10)}
+		}
+		return lines
+	})
+	pv.scrollBy(-5)
+	if pv.scroll != 0 {
+		t.Fatalf("Scroll must not be negative: %d", pv.scroll)
+	}
+	pv.scrollToBottom()
+	if pv.scroll != 100-pv.pageHeight() {
+		t.Fatalf("Unexpected bottom: %d", pv.scroll)
+	}
+	pv.scrollBy(10)
+	if pv.scroll != 100-pv.pageHeight() {
+		t.Fatalf("Scroll must stop at the bottom: %d", pv.scroll)
+	}
+}
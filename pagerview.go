@@ -0,0 +1,109 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"strings"
+)
+
+type pagerLine struct {
+	Text  string
+	Color termbox.Attribute
+}
+
+// pagerview shows scrollable lines of text, ex) the message log
+type pagerview struct {
+	title string
+	// source is called every drawing, so the content keeps up to date
+	source func() []pagerLine
+	scroll int
+}
+
+func newPagerview() *pagerview {
+	return &pagerview{}
+}
+
+func (pv *pagerview) open(title string, source func() []pagerLine) {
+	pv.title = title
+	pv.source = source
+	pv.scroll = 0
+}
+
+// lines returns the content wrapped to the width of terminal
+func (pv *pagerview) lines() []pagerLine {
+	if pv.source == nil {
+		return nil
+	}
+	width, _ := getTermSize()
+	result := make([]pagerLine, 0, 100)
+	for _, l := range pv.source() {
+		for _, s := range strings.Split(runewidth.Wrap(l.Text, width), "\n") {
+			result = append(result, pagerLine{Text: s, Color: l.Color})
+		}
+	}
+	return result
+}
+
+// pageHeight returns the number of lines shown at once, except the title
+func (pv *pagerview) pageHeight() int {
+	_, height := getTermSize()
+	return height - 3
+}
+
+func (pv *pagerview) maxScroll() int {
+	max := len(pv.lines()) - pv.pageHeight()
+	if max < 0 {
+		return 0
+	}
+	return max
+}
+
+func (pv *pagerview) scrollBy(n int) {
+	pv.scroll += n
+	if max := pv.maxScroll(); pv.scroll > max {
+		pv.scroll = max
+	}
+	if pv.scroll < 0 {
+		pv.scroll = 0
+	}
+}
+
+func (pv *pagerview) scrollToTop() {
+	pv.scroll = 0
+}
+
+func (pv *pagerview) scrollToBottom() {
+	pv.scroll = pv.maxScroll()
+}
+
+func (pv *pagerview) resetScroll() {
+	pv.scrollBy(0)
+}
+
+func (pv *pagerview) draw() {
+	fillLine(0, 0, ColorGray2)
+	drawText(pv.title, 1, 0, ColorWhite, ColorGray2)
+	lines := pv.lines()
+	height := pv.pageHeight()
+	for y := 0; y < height && pv.scroll+y < len(lines); y++ {
+		l := lines[pv.scroll+y]
+		drawText(l.Text, 0, y+1, l.Color, ColorBackground)
+	}
+}
@@ -0,0 +1,157 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint families of API, used as keys of rate limits
+const (
+	familyHomeTimeline       = "statuses/home_timeline"
+	familyMentionsTimeline   = "statuses/mentions_timeline"
+	familyUserTimeline       = "statuses/user_timeline"
+	familyShowTweet          = "statuses/show"
+	familyFavorites          = "favorites/list"
+	familyListStatuses       = "lists/statuses"
+	familyListShow           = "lists/show"
+	familyUsersShow          = "users/show"
+	familySearch             = "search/tweets"
+	familyDirectMessages     = "direct_messages"
+	familyDirectMessagesSent = "direct_messages/sent"
+	familyMutedUsersIds      = "mutes/users/ids"
+)
+
+type rateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// rateLimitError is returned instead of calling API which would exceed the limit
+type rateLimitError struct {
+	family string
+	reset  time.Time
+}
+
+func (e *rateLimitError) Error() string {
+	return "rate limited until " + e.reset.Local().Format("15:04")
+}
+
+// rateLimiter keeps x-rate-limit-* headers of the last response per endpoint family
+type rateLimiter struct {
+	limits map[string]rateLimit
+	mutex  sync.RWMutex
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		limits: make(map[string]rateLimit),
+	}
+}
+
+// endpointFamily converts a path like "/1.1/statuses/show/123.json" to "statuses/show"
+func endpointFamily(path string) string {
+	path = strings.TrimSuffix(path, ".json")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 0 && strings.HasPrefix(parts[0], "1") {
+		parts = parts[1:]
+	}
+	if len(parts) > 0 {
+		if _, err := strconv.ParseInt(parts[len(parts)-1], 10, 64); err == nil {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (rl *rateLimiter) update(family string, header http.Header) {
+	limit, err1 := strconv.Atoi(header.Get("X-Rate-Limit-Limit"))
+	remaining, err2 := strconv.Atoi(header.Get("X-Rate-Limit-Remaining"))
+	reset, err3 := strconv.ParseInt(header.Get("X-Rate-Limit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	rl.limits[family] = rateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+}
+
+func (rl *rateLimiter) get(family string) (rateLimit, bool) {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+	limit, ok := rl.limits[family]
+	if ok && time.Now().After(limit.Reset) {
+		// The window was reset, but we don't know a new budget yet
+		return limit, false
+	}
+	return limit, ok
+}
+
+// check returns rateLimitError when no more request is allowed in this window
+func (rl *rateLimiter) check(family string) error {
+	if limit, ok := rl.get(family); ok && limit.Remaining <= 0 {
+		return &rateLimitError{family: family, reset: limit.Reset}
+	}
+	return nil
+}
+
+// wrapError converts API's rate limit error to rateLimitError
+func (rl *rateLimiter) wrapError(family string, err error) error {
+	if apiErr, ok := err.(*anaconda.ApiError); ok {
+		if isRateLimitError, reset := apiErr.RateLimitCheck(); isRateLimitError {
+			rl.mutex.Lock()
+			limit := rl.limits[family]
+			limit.Remaining = 0
+			limit.Reset = reset
+			rl.limits[family] = limit
+			rl.mutex.Unlock()
+			return &rateLimitError{family: family, reset: reset}
+		}
+	}
+	return err
+}
+
+// rateLimitTransport records rate limits from headers of every response
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limiter.update(endpointFamily(req.URL.Path), resp.Header)
+	}
+	return resp, err
+}
+
+func formatRateLimit(limit rateLimit) string {
+	return fmt.Sprintf("API %d/%d ~%s", limit.Remaining, limit.Limit,
+		limit.Reset.Local().Format("15:04"))
+}
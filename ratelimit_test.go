@@ -0,0 +1,81 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointFamily(t *testing.T) {
+	testcase := map[string]string{
+		"/1.1/statuses/home_timeline.json": familyHomeTimeline,
+		"/1.1/statuses/show.json":          familyShowTweet,
+		"/1.1/statuses/retweet/123.json":   "statuses/retweet",
+		"/1.1/lists/statuses.json":         familyListStatuses,
+	}
+	for path, expected := range testcase {
+		if val := endpointFamily(path); val != expected {
+			t.Fatalf("Expected %v, but %v", expected, val)
+		}
+	}
+}
+
+func TestRateLimiterCheck(t *testing.T) {
+	rl := newRateLimiter()
+	if err := rl.check(familyHomeTimeline); err != nil {
+		t.Fatalf("Unknown family must not be limited: %v", err)
+	}
+
+	reset := time.Now().Add(time.Minute * 10)
+	header := http.Header{}
+	header.Set("X-Rate-Limit-Limit", "15")
+	header.Set("X-Rate-Limit-Remaining", "1")
+	header.Set("X-Rate-Limit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	rl.update(familyHomeTimeline, header)
+	if err := rl.check(familyHomeTimeline); err != nil {
+		t.Fatalf("One more request must be allowed: %v", err)
+	}
+	if limit, ok := rl.get(familyHomeTimeline); !ok || limit.Remaining != 1 || limit.Limit != 15 {
+		t.Fatalf("Unexpected rate limit: %v", limit)
+	}
+
+	header.Set("X-Rate-Limit-Remaining", "0")
+	rl.update(familyHomeTimeline, header)
+	err := rl.check(familyHomeTimeline)
+	if _, ok := err.(*rateLimitError); !ok {
+		t.Fatalf("Expected rateLimitError, but %v", err)
+	}
+	expected := "rate limited until " + reset.Format("15:04")
+	if err.Error() != expected {
+		t.Fatalf("Expected %q, but %q", expected, err.Error())
+	}
+	if s := newErrorNotification("Loading", err).String(); !strings.HasSuffix(s, expected) {
+		t.Fatalf("State must tell when the limit ends: %q", s)
+	}
+
+	// After the window is reset, requests must be allowed again
+	header.Set("X-Rate-Limit-Reset", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+	rl.update(familyHomeTimeline, header)
+	if err := rl.check(familyHomeTimeline); err != nil {
+		t.Fatalf("Request must be allowed after reset: %v", err)
+	}
+}
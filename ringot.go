@@ -20,6 +20,7 @@ package main
 import (
 	"fmt"
 	"github.com/nsf/termbox-go"
+	"time"
 )
 
 // These const variables are used by printVersion
@@ -41,7 +42,25 @@ func printVersion() {
 
 // Configuraion
 const (
-	CountTweet = 200
+	// Number of tweets kept in TweetMap, except pinned ones
+	TweetMapCapacity = 3000
+	// Number of timelines kept by usertimelineview, listview and searchview each
+	UserTimelineCacheMax = 20
+	// Number of tweets kept in each cached timeline
+	UserTimelineCacheTweets = 200
+	// Intervals of polling timelines, 0 disables polling
+	PollIntervalHome    = time.Second * 90
+	PollIntervalMention = time.Second * 120
+	PollIntervalList    = time.Second * 180
+	PollIntervalDM      = time.Second * 180
+	// Number of direct messages loaded at once
+	CountDirectMessage = 50
+	// Number of queries kept in the history of searches
+	SearchHistoryMax = 20
+	// Number of messages kept for :messages
+	MessageLogMax = 500
+	// A pending key sequence is canceled after this
+	KeySequenceTimeout = time.Second * 3
 )
 
 // DisableSequences
@@ -54,8 +73,8 @@ var (
 	}
 )
 
-// Color Configuration
-const (
+// Color Configuration, these are replaced by the theme of settings
+var (
 	ColorBackground = termbox.ColorDefault
 	ColorRed        = termbox.ColorRed
 	ColorWhite      = termbox.ColorWhite
@@ -68,6 +87,12 @@ const (
 	ColorGray3      = termbox.Attribute(254)
 	ColorLowlight   = termbox.Attribute(240)
 	ColorBlack      = termbox.ColorBlack
+	// Colors of entities in tweets
+	ColorMention = termbox.Attribute(216)
+	ColorHashtag = termbox.ColorBlue
+	ColorCashtag = termbox.ColorGreen
+	ColorURL     = termbox.ColorCyan
+	ColorMedia   = termbox.Attribute(214)
 )
 
 // Label Colors Configuration
@@ -0,0 +1,140 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/azr/backoff"
+	"sync"
+	"time"
+)
+
+type pollTarget int
+
+const (
+	pollHome pollTarget = iota
+	pollMention
+	pollList
+	pollDM
+)
+
+var pollTargetNames = map[string]pollTarget{
+	"home":    pollHome,
+	"mention": pollMention,
+	"list":    pollList,
+	"dm":      pollDM,
+}
+
+// Shortest intervals not to exceed the rate limits of API, per 15 minutes
+// home_timeline allows 15, mentions_timeline 75 and lists/statuses 900 requests,
+// direct_messages allows 15 and polling it calls direct_messages/sent together
+var pollMinIntervals = map[pollTarget]time.Duration{
+	pollHome:    time.Minute,
+	pollMention: time.Second * 12,
+	pollList:    time.Second,
+	pollDM:      time.Minute,
+}
+
+// pollRequest asks view to load new tweets of target,
+// the result of loading must be sent to result
+type pollRequest struct {
+	target pollTarget
+	result chan error
+}
+
+// scheduler polls timelines periodically in the background
+type scheduler struct {
+	requestCh chan pollRequest
+
+	intervals map[pollTarget]time.Duration
+	changed   map[pollTarget]chan struct{}
+	mutex     sync.Mutex
+}
+
+func newScheduler() *scheduler {
+	sc := &scheduler{
+		requestCh: make(chan pollRequest),
+		intervals: make(map[pollTarget]time.Duration),
+		changed:   make(map[pollTarget]chan struct{}),
+	}
+	for _, target := range pollTargetNames {
+		sc.changed[target] = make(chan struct{}, 1)
+	}
+	sc.intervals[pollHome] = PollIntervalHome
+	sc.intervals[pollMention] = PollIntervalMention
+	sc.intervals[pollList] = PollIntervalList
+	sc.intervals[pollDM] = PollIntervalDM
+	return sc
+}
+
+func (sc *scheduler) start() {
+	for _, target := range pollTargetNames {
+		go sc.run(target)
+	}
+}
+
+// setInterval changes the interval of polling, 0 disables polling of target
+func (sc *scheduler) setInterval(target pollTarget, d time.Duration) time.Duration {
+	if d > 0 && d < pollMinIntervals[target] {
+		d = pollMinIntervals[target]
+	}
+	sc.mutex.Lock()
+	sc.intervals[target] = d
+	sc.mutex.Unlock()
+	select {
+	case sc.changed[target] <- struct{}{}:
+	default:
+	}
+	return d
+}
+
+func (sc *scheduler) interval(target pollTarget) time.Duration {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	d := sc.intervals[target]
+	if d > 0 && d < pollMinIntervals[target] {
+		d = pollMinIntervals[target]
+	}
+	return d
+}
+
+func (sc *scheduler) run(target pollTarget) {
+	bo := backoff.NewExponential()
+	bo.InitialInterval = time.Second * 30
+	bo.MaxInterval = time.Minute * 15
+	bo.Reset()
+	for {
+		d := sc.interval(target)
+		if d <= 0 {
+			<-sc.changed[target]
+			continue
+		}
+		select {
+		case <-time.After(d):
+		case <-sc.changed[target]:
+			continue
+		}
+		result := make(chan error, 1)
+		sc.requestCh <- pollRequest{target: target, result: result}
+		if err := <-result; err != nil {
+			// Wait additionally, errors are likely to continue for a while
+			bo.BackOff()
+		} else {
+			bo.Reset()
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+type searchview struct {
+	*tweetview
+	query    string
+	searches *savedSearches
+	// cache keeps results of queries searched before
+	cache  *timelineCache
+	client TwitterClient
+
+	loading             lock
+	loadNewTweetCh      chan []Tweet
+	loadIntervalTweetCh chan []Tweet
+}
+
+func newSearchview(client TwitterClient) *searchview {
+	return &searchview{
+		tweetview:           newTweetview(),
+		searches:            newSavedSearches(),
+		cache:               newTimelineCache(),
+		client:              client,
+		loadNewTweetCh:      make(chan []Tweet),
+		loadIntervalTweetCh: make(chan []Tweet),
+	}
+}
+
+func (sv *searchview) fetch(v url.Values) ([]Tweet, error) {
+	v.Add("result_type", "recent")
+	result, err := sv.client.GetSearch(sv.query, v)
+	if err != nil {
+		return nil, err
+	}
+	return result.Statuses, nil
+}
+
+func (sv *searchview) loadTweet(sinceID int64) {
+	if sv.loading.isLocking() {
+		return
+	}
+	sv.loading.lock()
+	defer sv.loading.unlock()
+	changeBufferState("Searching...")
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Search.New))
+	if sinceID > 0 {
+		val.Add("since_id", strconv.FormatInt(sinceID, 10))
+	}
+	timeline, err := sv.fetch(val)
+	if err != nil {
+		notifyError("Search", err)
+		return
+	}
+	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
+	sv.loadNewTweetCh <- timeline
+}
+
+func (sv *searchview) loadIntervalTweet(maxID int64) {
+	if sv.loading.isLocking() {
+		return
+	}
+	sv.loading.lock()
+	defer sv.loading.unlock()
+	changeBufferState("Searching...")
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Search.Older))
+	if maxID > 0 {
+		val.Add("max_id", strconv.FormatInt(maxID, 10))
+	}
+	timeline, err := sv.fetch(val)
+	if err != nil {
+		notifyError("Search", err)
+		return
+	}
+	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
+	sv.loadIntervalTweetCh <- timeline
+}
+
+// setQuery shows the cached result of query, only newer tweets are loaded then
+func (sv *searchview) setQuery(query string) {
+	if sv.query != "" && !sv.isEmpty() {
+		sv.cache.store(sv.query, sv.tweets)
+	}
+	sv.query = query
+	sv.tweets = []tweetstatus{tweetstatus{ReloadMark: true}}
+	if c, ok := sv.cache.take(query); ok {
+		sv.tweets = c
+		sv.cursorPosition = 0
+		sv.applyFilter()
+	}
+	sv.searches.addHistory(query)
+}
+
+func (sv *searchview) draw() {
+	sv.scrollOffset = 1
+	sv.tweetview.draw()
+
+	fillLine(0, 0, ColorGray2)
+	drawText("Search: "+sv.query, 0, 0, ColorWhite, ColorGray2)
+}
+
+func (sv *searchview) resetScroll() {
+	sv.scrollOffset = 1
+	sv.tweetview.resetScroll()
+}
+
+// savedSearches keeps recent queries and named queries, they are stored with the cache
+type savedSearches struct {
+	History []string          `json:"history"`
+	Saved   map[string]string `json:"saved"`
+}
+
+func newSavedSearches() *savedSearches {
+	return &savedSearches{
+		History: make([]string, 0, SearchHistoryMax),
+		Saved:   make(map[string]string),
+	}
+}
+
+// addHistory puts query at the top of history, without duplication
+func (ss *savedSearches) addHistory(query string) {
+	h := make([]string, 0, SearchHistoryMax)
+	h = append(h, query)
+	for _, q := range ss.History {
+		if q != query && len(h) < SearchHistoryMax {
+			h = append(h, q)
+		}
+	}
+	ss.History = h
+}
+
+// lastQuery returns the latest query, "" if nothing has been searched
+func (ss *savedSearches) lastQuery() string {
+	if len(ss.History) == 0 {
+		return ""
+	}
+	return ss.History[0]
+}
+
+// lines formats saved searches and history for pagerview
+func (ss *savedSearches) lines() []pagerLine {
+	lines := make([]pagerLine, 0, len(ss.Saved)+len(ss.History)+2)
+	lines = append(lines, pagerLine{Text: "[saved]", Color: ColorYellow})
+	names := make([]string, 0, len(ss.Saved))
+	for name := range ss.Saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		text := fmt.Sprintf("  %-12s %s", name, ss.Saved[name])
+		lines = append(lines, pagerLine{Text: text, Color: ColorWhite})
+	}
+	lines = append(lines, pagerLine{Text: "[history]", Color: ColorYellow})
+	for i, q := range ss.History {
+		text := fmt.Sprintf("  %-12d %s", i+1, q)
+		lines = append(lines, pagerLine{Text: text, Color: ColorWhite})
+	}
+	return lines
+}
@@ -0,0 +1,92 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchviewLoad(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	for id := int64(1); id <= 10; id++ {
+		text := "nothing"
+		if id%2 == 0 {
+			text = "Ringot is great"
+		}
+		client.searchable = append(client.searchable, newFakeTweet(id, "alice", text))
+	}
+	sv := newSearchview(client)
+	sv.setQuery("ringot")
+
+	go sv.loadTweet(0)
+	tw := receiveTweets(t, sv.loadNewTweetCh)
+	if len(tw) != 5 {
+		t.Fatalf("Expected 5 matched tweets, but %d", len(tw))
+	}
+	sv.addNewTweet(wrapTweets(tw[:2]))
+
+	go sv.loadIntervalTweet(sv.tweets[1].Content.Id)
+	tw = receiveTweets(t, sv.loadIntervalTweetCh)
+	sv.addIntervalTweet(tw)
+	if len(sv.tweets) != 6 || sv.tweets[4].Content.Id != 2 {
+		t.Fatalf("Older results must be added below without duplication: %d tweets", len(sv.tweets))
+	}
+
+	client.searchable = append(client.searchable, newFakeTweet(12, "bob", "ringot again"))
+	go sv.loadTweet(sv.newestID())
+	tw = receiveTweets(t, sv.loadNewTweetCh)
+	if len(tw) != 1 || tw[0].Id != 12 {
+		t.Fatalf("Only newer results must be loaded: %d tweets", len(tw))
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	ss := newSavedSearches()
+	for i := 0; i < SearchHistoryMax+5; i++ {
+		ss.addHistory(fmt.Sprintf("query%d", i))
+	}
+	ss.addHistory("query10")
+	if len(ss.History) != SearchHistoryMax {
+		t.Fatalf("History must be limited to %d, but %d", SearchHistoryMax, len(ss.History))
+	}
+	if ss.lastQuery() != "query10" || ss.History[1] != fmt.Sprintf("query%d", SearchHistoryMax+4) {
+		t.Fatalf("A repeated query must move to the top: %v", ss.History[:2])
+	}
+	for _, q := range ss.History[1:] {
+		if q == "query10" {
+			t.Fatalf("History must not have duplicates")
+		}
+	}
+
+	ss.Saved["rg"] = "ringot OR #ringot"
+	store := newTweetStore(filepath.Join(t.TempDir(), "12345"))
+	if err := store.saveSearches(ss); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.loadSearches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Saved["rg"] != "ringot OR #ringot" || loaded.lastQuery() != "query10" {
+		t.Fatalf("Saved searches were not restored: %+v", loaded)
+	}
+}
@@ -0,0 +1,449 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nsf/termbox-go"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingsFile is read from ProfileDir to change appearances and behaviors
+const SettingsFile = "settings.json"
+
+// settings is replaced by cli.setting, the defaults are used until then
+var settings = defaultSettings()
+
+// Settings is the content of SettingsFile, omitted fields keep their defaults
+type Settings struct {
+	Theme  string           `json:"theme"`
+	Themes map[string]Theme `json:"themes"`
+	// FetchCounts are the "count" parameters of each view
+	FetchCounts FetchCounts `json:"fetch_counts"`
+	TimeFormat  TimeFormat  `json:"time_format"`
+	// StatusClearSeconds is how long a message stays in the state line
+	StatusClearSeconds int `json:"status_clear_seconds"`
+	// Opener is a command to open URLs and media, "{}" is replaced with the URL,
+	// ex) "firefox --new-tab {}", $BROWSER or the OS default is used if empty
+	Opener string `json:"opener"`
+}
+
+// FetchCount has counts of loading new tweets and older tweets
+type FetchCount struct {
+	New   int `json:"new"`
+	Older int `json:"older"`
+}
+
+// FetchCounts keeps FetchCount of each view
+type FetchCounts struct {
+	Home         FetchCount `json:"home"`
+	Mention      FetchCount `json:"mention"`
+	UserTimeline FetchCount `json:"user_timeline"`
+	Favorite     FetchCount `json:"favorite"`
+	List         FetchCount `json:"list"`
+	Search       FetchCount `json:"search"`
+}
+
+// TimeFormat has the style of timestamps shown under tweets, and thresholds of
+// relative style, ex) a tweet older than Now and not older than FewMinutes is "A few minutes ago"
+type TimeFormat struct {
+	// Style is "relative", "iso" or "zone"
+	Style string `json:"style"`
+	// Timezone is a name of IANA Time Zone used by "zone" style, ex) "UTC", "Asia/Tokyo"
+	Timezone string `json:"timezone"`
+	location *time.Location
+
+	Now        duration `json:"now"`
+	FewMinutes duration `json:"few_minutes"`
+	Minutes    duration `json:"minutes"`
+	Hours      duration `json:"hours"`
+	// Days is the limit of showing "(N days ago)" after the date
+	Days duration `json:"days"`
+}
+
+// duration is written as a string in JSON, ex) "36h"
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func defaultSettings() *Settings {
+	themes := make(map[string]Theme, len(builtinThemes))
+	for name, t := range builtinThemes {
+		themes[name] = t
+	}
+	return &Settings{
+		Theme:  "default",
+		Themes: themes,
+		FetchCounts: FetchCounts{
+			Home:         FetchCount{New: 200, Older: 50},
+			Mention:      FetchCount{New: 200, Older: 200},
+			UserTimeline: FetchCount{New: 20, Older: 200},
+			Favorite:     FetchCount{New: 20, Older: 200},
+			List:         FetchCount{New: 20, Older: 20},
+			Search:       FetchCount{New: 100, Older: 100},
+		},
+		TimeFormat: TimeFormat{
+			Style:      timeStyleRelative,
+			Now:        duration(time.Second * 30),
+			FewMinutes: duration(time.Minute * 5),
+			Minutes:    duration(time.Hour * 2),
+			Hours:      duration(time.Hour * 36),
+			Days:       duration(time.Hour * 24 * 14),
+		},
+		StatusClearSeconds: 30,
+	}
+}
+
+// loadSettings reads path and validates it,
+// the defaults are returned if path doesn't exist
+func loadSettings(path string) (*Settings, error) {
+	s := defaultSettings()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(s); err != nil {
+		return nil, err
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Settings) validate() error {
+	problems := make([]string, 0)
+	// max is the largest "count" each endpoint accepts
+	counts := []struct {
+		name  string
+		count FetchCount
+		max   int
+	}{
+		{"home", s.FetchCounts.Home, 200},
+		{"mention", s.FetchCounts.Mention, 200},
+		{"user_timeline", s.FetchCounts.UserTimeline, 200},
+		{"favorite", s.FetchCounts.Favorite, 200},
+		{"list", s.FetchCounts.List, 200},
+		{"search", s.FetchCounts.Search, 100},
+	}
+	for _, c := range counts {
+		if c.count.New < 1 || c.count.New > c.max || c.count.Older < 1 || c.count.Older > c.max {
+			problems = append(problems, fmt.Sprintf("fetch_counts.%s: counts must be between 1 and %d", c.name, c.max))
+		}
+	}
+	tf := s.TimeFormat
+	if !(0 <= tf.Now && tf.Now <= tf.FewMinutes && tf.FewMinutes <= tf.Minutes &&
+		tf.Minutes <= tf.Hours && tf.Hours <= tf.Days) {
+		problems = append(problems, "time_format: thresholds must be in ascending order")
+	}
+	if !isTimeStyle(tf.Style) {
+		problems = append(problems, fmt.Sprintf("time_format: unknown style %q", tf.Style))
+	}
+	if err := s.TimeFormat.setTimezone(tf.Timezone); err != nil {
+		problems = append(problems, "time_format: "+err.Error())
+	}
+	if s.StatusClearSeconds < 1 {
+		problems = append(problems, "status_clear_seconds: must be positive")
+	}
+	if _, err := s.theme(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// theme returns the selected theme, whose empty fields are filled by its base
+func (s *Settings) theme() (Theme, error) {
+	return s.resolveTheme(s.Theme, 0)
+}
+
+// themeBaseDepth limits chains of "base", so circular references are reported
+const themeBaseDepth = 8
+
+func (s *Settings) resolveTheme(name string, depth int) (Theme, error) {
+	t, ok := s.Themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	if depth >= themeBaseDepth {
+		return Theme{}, fmt.Errorf("theme %q: too deep bases", name)
+	}
+	base := t.Base
+	if base == "" && name != "default" {
+		base = "default"
+	}
+	if base != "" {
+		b, err := s.resolveTheme(base, depth+1)
+		if err != nil {
+			return Theme{}, err
+		}
+		t = t.fill(b)
+	}
+	if _, err := t.attributes(); err != nil {
+		return Theme{}, fmt.Errorf("theme %q: %v", name, err)
+	}
+	return t, nil
+}
+
+// Theme is a set of colors, a color is a name like "red" or
+// an index of 256 colors like "213". Names may have "bright_" prefix
+type Theme struct {
+	// Base is a theme whose colors are used for omitted ones, "default" if empty
+	Base string `json:"base"`
+	// Colors is 256 or 16, only names are allowed in 16 colors
+	Colors     int    `json:"colors"`
+	Background string `json:"background"`
+	Foreground string `json:"foreground"`
+	Red        string `json:"red"`
+	Yellow     string `json:"yellow"`
+	Green      string `json:"green"`
+	Blue       string `json:"blue"`
+	Pink       string `json:"pink"`
+	Gray1      string `json:"gray1"`
+	Gray2      string `json:"gray2"`
+	Gray3      string `json:"gray3"`
+	Lowlight   string `json:"lowlight"`
+	Black      string `json:"black"`
+	// Colors of entities in tweets
+	Mention string   `json:"mention"`
+	Hashtag string   `json:"hashtag"`
+	Cashtag string   `json:"cashtag"`
+	URL     string   `json:"url"`
+	Media   string   `json:"media"`
+	Labels  []string `json:"labels"`
+}
+
+var builtinThemes = map[string]Theme{
+	"default": {
+		Colors:     256,
+		Background: "default",
+		Foreground: "white",
+		Red:        "red",
+		Yellow:     "yellow",
+		Green:      "green",
+		Blue:       "blue",
+		Pink:       "213",
+		Gray1:      "241",
+		Gray2:      "238",
+		Gray3:      "253",
+		Lowlight:   "239",
+		Black:      "black",
+		Mention:    "215",
+		Hashtag:    "blue",
+		Cashtag:    "green",
+		URL:        "cyan",
+		Media:      "213",
+		Labels: []string{"red", "green", "39", "40", "63", "65", "69", "99", "109",
+			"118", "123", "125", "129", "149", "159", "166", "215", "226"},
+	},
+	// light is for terminals with white background
+	"light": {
+		Colors:     256,
+		Background: "default",
+		Foreground: "black",
+		Red:        "160",
+		Yellow:     "136",
+		Green:      "28",
+		Blue:       "25",
+		Pink:       "163",
+		Gray1:      "244",
+		Gray2:      "252",
+		Gray3:      "238",
+		Lowlight:   "253",
+		Black:      "250",
+		Mention:    "130",
+		Hashtag:    "25",
+		Cashtag:    "28",
+		URL:        "31",
+		Media:      "163",
+		Labels: []string{"red", "green", "22", "25", "28", "54", "58", "88", "90",
+			"94", "124", "130", "166", "30"},
+	},
+	// 16color is for terminals which can't show 256 colors
+	"16color": {
+		Colors:     16,
+		Background: "default",
+		Foreground: "white",
+		Red:        "red",
+		Yellow:     "yellow",
+		Green:      "green",
+		Blue:       "blue",
+		Pink:       "magenta",
+		Gray1:      "cyan",
+		Gray2:      "blue",
+		Gray3:      "white",
+		Lowlight:   "black",
+		Black:      "black",
+		Mention:    "yellow",
+		Hashtag:    "blue",
+		Cashtag:    "green",
+		URL:        "cyan",
+		Media:      "magenta",
+		Labels: []string{"red", "green", "yellow", "blue", "magenta", "cyan",
+			"bright_red", "bright_green", "bright_yellow", "bright_blue", "bright_magenta", "bright_cyan"},
+	},
+}
+
+// fill returns t whose empty fields are taken from base
+func (t Theme) fill(base Theme) Theme {
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&t.Background, base.Background},
+		{&t.Foreground, base.Foreground},
+		{&t.Red, base.Red},
+		{&t.Yellow, base.Yellow},
+		{&t.Green, base.Green},
+		{&t.Blue, base.Blue},
+		{&t.Pink, base.Pink},
+		{&t.Gray1, base.Gray1},
+		{&t.Gray2, base.Gray2},
+		{&t.Gray3, base.Gray3},
+		{&t.Lowlight, base.Lowlight},
+		{&t.Black, base.Black},
+		{&t.Mention, base.Mention},
+		{&t.Hashtag, base.Hashtag},
+		{&t.Cashtag, base.Cashtag},
+		{&t.URL, base.URL},
+		{&t.Media, base.Media},
+	}
+	for _, f := range fields {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	if t.Colors == 0 {
+		t.Colors = base.Colors
+	}
+	if len(t.Labels) == 0 {
+		t.Labels = base.Labels
+	}
+	return t
+}
+
+var colorNames = map[string]termbox.Attribute{
+	"default": termbox.ColorDefault,
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// parseColor converts a color of Theme to termbox.Attribute
+func parseColor(s string, colors int) (termbox.Attribute, error) {
+	name := strings.TrimPrefix(s, "bright_")
+	if c, ok := colorNames[name]; ok {
+		if name != s {
+			if c == termbox.ColorDefault {
+				return 0, fmt.Errorf("unknown color %q", s)
+			}
+			return c | termbox.AttrBold, nil
+		}
+		return c, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 255 {
+		return 0, fmt.Errorf("unknown color %q", s)
+	}
+	if colors != 256 {
+		return 0, fmt.Errorf("color %q needs 256 colors", s)
+	}
+	// Attributes of 256 colors start from 1, 0 is ColorDefault
+	return termbox.Attribute(n + 1), nil
+}
+
+// themeAttributes is Theme converted to the values of color variables
+type themeAttributes struct {
+	colors     [17]termbox.Attribute
+	labels     []termbox.Attribute
+	outputMode termbox.OutputMode
+}
+
+func (t Theme) attributes() (themeAttributes, error) {
+	var ta themeAttributes
+	switch t.Colors {
+	case 256:
+		ta.outputMode = termbox.Output256
+	case 16:
+		ta.outputMode = termbox.OutputNormal
+	default:
+		return ta, fmt.Errorf("colors must be 256 or 16, not %d", t.Colors)
+	}
+	names := []string{t.Background, t.Foreground, t.Red, t.Yellow, t.Green, t.Blue,
+		t.Pink, t.Gray1, t.Gray2, t.Gray3, t.Lowlight, t.Black,
+		t.Mention, t.Hashtag, t.Cashtag, t.URL, t.Media}
+	for i, name := range names {
+		c, err := parseColor(name, t.Colors)
+		if err != nil {
+			return ta, err
+		}
+		ta.colors[i] = c
+	}
+	if len(t.Labels) == 0 {
+		return ta, fmt.Errorf("labels must not be empty")
+	}
+	for _, name := range t.Labels {
+		c, err := parseColor(name, t.Colors)
+		if err != nil {
+			return ta, err
+		}
+		ta.labels = append(ta.labels, c)
+	}
+	return ta, nil
+}
+
+// applyTheme sets the color variables, and returns the output mode for termbox
+func applyTheme(t Theme) (termbox.OutputMode, error) {
+	ta, err := t.attributes()
+	if err != nil {
+		return termbox.OutputCurrent, err
+	}
+	ColorBackground, ColorWhite, ColorRed, ColorYellow, ColorGreen, ColorBlue,
+		ColorPink, ColorGray1, ColorGray2, ColorGray3, ColorLowlight, ColorBlack =
+		ta.colors[0], ta.colors[1], ta.colors[2], ta.colors[3], ta.colors[4], ta.colors[5],
+		ta.colors[6], ta.colors[7], ta.colors[8], ta.colors[9], ta.colors[10], ta.colors[11]
+	ColorMention, ColorHashtag, ColorCashtag, ColorURL, ColorMedia =
+		ta.colors[12], ta.colors[13], ta.colors[14], ta.colors[15], ta.colors[16]
+	LabelColors = ta.labels
+	return ta.outputMode, nil
+}
@@ -0,0 +1,143 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultTheme(t *testing.T) {
+	theme, err := defaultSettings().theme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode, err := applyTheme(theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != termbox.Output256 {
+		t.Fatalf("Default theme must use 256 colors")
+	}
+	// The default theme keeps the colors used before themes
+	if ColorPink != termbox.Attribute(214) || ColorGray1 != termbox.Attribute(0xe9+9) ||
+		ColorGray2 != termbox.Attribute(0xe9+6) || ColorLowlight != termbox.Attribute(240) {
+		t.Fatalf("Unexpected colors: %v %v %v %v", ColorPink, ColorGray1, ColorGray2, ColorLowlight)
+	}
+	if len(LabelColors) != 18 || LabelColors[17] != termbox.Attribute(227) {
+		t.Fatalf("Unexpected label colors: %v", LabelColors)
+	}
+}
+
+func TestBuiltinThemes(t *testing.T) {
+	s := defaultSettings()
+	for name := range builtinThemes {
+		if _, err := s.resolveTheme(name, 0); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	theme, _ := s.resolveTheme("16color", 0)
+	ta, _ := theme.attributes()
+	if ta.outputMode != termbox.OutputNormal {
+		t.Fatalf("16color must use OutputNormal")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	testcase := map[string]termbox.Attribute{
+		"default":    termbox.ColorDefault,
+		"red":        termbox.ColorRed,
+		"bright_red": termbox.ColorRed | termbox.AttrBold,
+		"0":          termbox.Attribute(1),
+		"255":        termbox.Attribute(256),
+	}
+	for s, expected := range testcase {
+		if c, err := parseColor(s, 256); err != nil || c != expected {
+			t.Fatalf("%s: Expected %v, but %v (%v)", s, expected, c, err)
+		}
+	}
+	for _, s := range []string{"256", "-1", "pink", "bright_default"} {
+		if _, err := parseColor(s, 256); err == nil {
+			t.Fatalf("%s must be an error", s)
+		}
+	}
+	if _, err := parseColor("213", 16); err == nil {
+		t.Fatalf("Numbers must be an error in 16 colors")
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ringot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, SettingsFile)
+
+	s, err := loadSettings(path)
+	if err != nil || s.Theme != "default" {
+		t.Fatalf("Missing file must give the defaults: %v", err)
+	}
+
+	content := `{
+		"theme": "mine",
+		"themes": {"mine": {"base": "light", "pink": "205"}},
+		"fetch_counts": {"home": {"new": 100, "older": 30}, "mention": {"new": 200, "older": 200}},
+		"time_format": {"now": "1m"},
+		"status_clear_seconds": 10
+	}`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err = loadSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.FetchCounts.Home.New != 100 || s.FetchCounts.Mention.New != 200 {
+		t.Fatalf("Unexpected fetch counts: %+v", s.FetchCounts)
+	}
+	if time.Duration(s.TimeFormat.Now) != time.Minute || time.Duration(s.TimeFormat.Hours) != time.Hour*36 {
+		t.Fatalf("Unexpected time format: %+v", s.TimeFormat)
+	}
+	theme, _ := s.theme()
+	if theme.Pink != "205" || theme.Foreground != "black" {
+		t.Fatalf("Omitted colors must be taken from base: %+v", theme)
+	}
+
+	for _, content := range []string{
+		`{"theme": "unknown"}`,
+		`{"themes": {"default": {"base": "loop"}, "loop": {"base": "default"}}}`,
+		`{"theme": "bad", "themes": {"bad": {"base": "16color", "pink": "213"}}}`,
+		`{"fetch_counts": {"list": {"new": 0, "older": 20}}}`,
+		`{"fetch_counts": {"home": {"new": 201, "older": 20}}}`,
+		`{"fetch_counts": {"search": {"new": 100, "older": 101}}}`,
+		`{"time_format": {"now": "3h"}}`,
+		`{"time_format": {"now": 30}}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadSettings(path); err == nil {
+			t.Fatalf("%s must be an error", content)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/ChimeraCoder/anaconda"
+	"os"
+	"path/filepath"
+)
+
+// These const variables are used by tweetStore
+const (
+	CacheFile = "cache.json"
+	// Saved searches and history of queries
+	SearchesFile = "searches.json"
+	// Rules of muting and filtering tweets
+	FiltersFile = "filters.json"
+	// Number of tweets kept on disk per timeline
+	StoreTimelineMax = 400
+)
+
+// tweetStore persists tweets, profiles and timelines under ~/.ringot/<account>/
+type tweetStore struct {
+	dir string
+}
+
+// storedStatus is a tweetstatus without its content, tweets are stored once
+type storedStatus struct {
+	ID         int64 `json:"id,omitempty"`
+	ReloadMark bool  `json:"reload_mark,omitempty"`
+}
+
+type storedCache struct {
+	Tweets    []Tweet                   `json:"tweets"`
+	Profiles  []anaconda.User           `json:"profiles"`
+	Timelines map[string][]storedStatus `json:"timelines"`
+	// Lists are shown in headers of saved list timelines
+	Lists []anaconda.List `json:"lists,omitempty"`
+}
+
+func newTweetStore(dir string) *tweetStore {
+	return &tweetStore{dir: dir}
+}
+
+func (st *tweetStore) path(name string) string {
+	return filepath.Join(st.dir, name)
+}
+
+func (st *tweetStore) save(timelines map[string][]tweetstatus, profiles []*anaconda.User, lists []anaconda.List) error {
+	cache := storedCache{
+		Tweets:    make([]Tweet, 0, StoreTimelineMax),
+		Profiles:  make([]anaconda.User, 0, len(profiles)),
+		Timelines: make(map[string][]storedStatus, len(timelines)),
+		Lists:     lists,
+	}
+	saved := make(map[int64]bool, StoreTimelineMax)
+	for name, tss := range timelines {
+		statuses := make([]storedStatus, 0, len(tss))
+		for _, ts := range tss {
+			if len(statuses) >= StoreTimelineMax {
+				break
+			}
+			if ts.ReloadMark {
+				// A leading or doubled mark has no meaning
+				if len(statuses) > 0 && !statuses[len(statuses)-1].ReloadMark {
+					statuses = append(statuses, storedStatus{ReloadMark: true})
+				}
+				continue
+			} else if ts.Empty || ts.Content == nil {
+				continue
+			}
+			statuses = append(statuses, storedStatus{ID: ts.Content.Id})
+			if !saved[ts.Content.Id] {
+				saved[ts.Content.Id] = true
+				cache.Tweets = append(cache.Tweets, *ts.Content)
+			}
+		}
+		// Older tweets can always be loaded again from the end of timeline
+		if len(statuses) > 0 && !statuses[len(statuses)-1].ReloadMark {
+			statuses = append(statuses, storedStatus{ReloadMark: true})
+		}
+		cache.Timelines[name] = statuses
+	}
+	for _, u := range profiles {
+		cache.Profiles = append(cache.Profiles, *u)
+	}
+
+	return st.writeJSON(CacheFile, &cache)
+}
+
+// writeJSON writes v to a temporary file first not to break the file on failure
+func (st *tweetStore) writeJSON(name string, v interface{}) error {
+	if _, err := os.Stat(st.dir); err != nil {
+		if err = os.MkdirAll(st.dir, 0700); err != nil {
+			return err
+		}
+	}
+	temp := st.path(name + ".tmp")
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(file).Encode(v); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp, st.path(name))
+}
+
+func (st *tweetStore) load() (map[string][]tweetstatus, []anaconda.User, []anaconda.List, error) {
+	file, err := os.Open(st.path(CacheFile))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer file.Close()
+	var cache storedCache
+	if err = json.NewDecoder(file).Decode(&cache); err != nil {
+		return nil, nil, nil, err
+	}
+
+	tweets := make(map[int64]*Tweet, len(cache.Tweets))
+	for i := range cache.Tweets {
+		tweets[cache.Tweets[i].Id] = &cache.Tweets[i]
+	}
+	timelines := make(map[string][]tweetstatus, len(cache.Timelines))
+	for name, statuses := range cache.Timelines {
+		tss := make([]tweetstatus, 0, len(statuses))
+		for _, s := range statuses {
+			if s.ReloadMark {
+				tss = append(tss, tweetstatus{ReloadMark: true})
+			} else if t, ok := tweets[s.ID]; ok {
+				tss = append(tss, tweetstatus{Content: t})
+			}
+		}
+		timelines[name] = tss
+	}
+	return timelines, cache.Profiles, cache.Lists, nil
+}
+
+func (st *tweetStore) saveSearches(ss *savedSearches) error {
+	return st.writeJSON(SearchesFile, ss)
+}
+
+// loadSearches returns empty searches when nothing has been saved
+func (st *tweetStore) loadSearches() (*savedSearches, error) {
+	ss := newSavedSearches()
+	file, err := os.Open(st.path(SearchesFile))
+	if os.IsNotExist(err) {
+		return ss, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(ss); err != nil {
+		return nil, err
+	}
+	if ss.Saved == nil {
+		ss.Saved = make(map[string]string)
+	}
+	return ss, nil
+}
+
+func (st *tweetStore) saveFilters(fs *filterSet) error {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return st.writeJSON(FiltersFile, fs)
+}
+
+// loadFilters returns an empty set when nothing has been saved
+func (st *tweetStore) loadFilters() (*filterSet, error) {
+	fs := newFilterSet()
+	file, err := os.Open(st.path(FiltersFile))
+	if os.IsNotExist(err) {
+		return fs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(fs); err != nil {
+		return nil, err
+	}
+	if err = fs.compile(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
@@ -0,0 +1,149 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/ChimeraCoder/anaconda"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestTweetStoreRoundTrip(t *testing.T) {
+	store := newTweetStore(filepath.Join(t.TempDir(), "12345"))
+	t1 := newFakeTweet(3, "alice", "third")
+	t2 := newFakeTweet(2, "bob", "second")
+	t3 := newFakeTweet(1, "alice", "first")
+	home := []tweetstatus{
+		{Content: &t1}, {Content: &t2}, {ReloadMark: true}, {Content: &t3}, {ReloadMark: true},
+	}
+	mention := []tweetstatus{{Content: &t2}, {ReloadMark: true}}
+	profile := &anaconda.User{ScreenName: "alice", Description: "hello"}
+
+	err := store.save(map[string][]tweetstatus{"home": home, "mention": mention},
+		[]*anaconda.User{profile}, nil)
+	if err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	timelines, profiles, _, err := store.load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	loaded := timelines["home"]
+	if len(loaded) != len(home) {
+		t.Fatalf("Expected %d statuses, but %d", len(home), len(loaded))
+	}
+	for i := range home {
+		if home[i].ReloadMark != loaded[i].ReloadMark {
+			t.Fatalf("ReloadMark at %d was not kept", i)
+		}
+		if home[i].Content != nil && home[i].Content.Id != loaded[i].Content.Id {
+			t.Fatalf("Expected ID %d at %d, but %d", home[i].Content.Id, i, loaded[i].Content.Id)
+		}
+	}
+	// A tweet shared by timelines must be restored as the same tweet
+	if timelines["mention"][0].Content != loaded[1].Content {
+		t.Fatalf("Shared tweet was restored twice")
+	}
+	if len(profiles) != 1 || profiles[0].Description != "hello" {
+		t.Fatalf("Profiles were not restored: %v", profiles)
+	}
+}
+
+func TestTweetStoreLoadWithoutCache(t *testing.T) {
+	store := newTweetStore(t.TempDir())
+	if _, _, _, err := store.load(); err == nil {
+		t.Fatalf("load must fail when there is no cache")
+	}
+}
+
+func TestSaveAndRestoreAllTimelines(t *testing.T) {
+	initialize()
+	initializeState()
+	store := newTweetStore(filepath.Join(t.TempDir(), "12345"))
+	timeline := func(id int64, sn string) []tweetstatus {
+		return append(wrapTweets([]Tweet{newFakeTweet(id, sn, "hello")}), tweetstatus{ReloadMark: true})
+	}
+	view := newView(newFakeClient(), store)
+	view.timelineview.tweets = timeline(1, "alice")
+	view.listview.list = anaconda.List{Id: 42, Slug: "friends", FullName: "@alice/friends"}
+	view.listview.list.User.ScreenName = "alice"
+	view.listview.tweets = timeline(2, "bob")
+	view.searchview.query = "golang"
+	view.searchview.tweets = timeline(3, "carol")
+	view.usertimelineview.setUserScreenName("dave")
+	view.usertimelineview.tweets = timeline(4, "dave")
+	// A cached timeline is saved too
+	view.usertimelineview.setUserScreenName("erin")
+	view.usertimelineview.tweets = timeline(5, "erin")
+	view.favoriteview.setUserScreenName("frank")
+	view.favoriteview.tweets = timeline(6, "grace")
+	view.saveCache()
+
+	restored := newView(newFakeClient(), store)
+	if !restored.restoreCache() || restored.timelineview.tweets[0].Content.Id != 1 {
+		t.Fatalf("Home timeline was not restored")
+	}
+	testcase := []struct {
+		name string
+		open func() *tweetview
+		id   int64
+	}{
+		{"list", func() *tweetview {
+			restored.listview.setListName("Alice", "Friends")
+			return restored.listview.tweetview
+		}, 2},
+		{"search", func() *tweetview {
+			restored.searchview.setQuery("golang")
+			return restored.searchview.tweetview
+		}, 3},
+		{"dave", func() *tweetview {
+			restored.usertimelineview.setUserScreenName("dave")
+			return restored.usertimelineview.tweetview
+		}, 4},
+		{"erin", func() *tweetview {
+			restored.usertimelineview.setUserScreenName("erin")
+			return restored.usertimelineview.tweetview
+		}, 5},
+		{"favorite", func() *tweetview {
+			restored.favoriteview.setUserScreenName("frank")
+			return restored.favoriteview.tweetview
+		}, 6},
+	}
+	for _, c := range testcase {
+		if tv := c.open(); tv.isEmpty() || tv.tweets[0].Content.Id != c.id {
+			t.Fatalf("Timeline of %s was not restored", c.name)
+		}
+	}
+	if restored.listview.list.FullName != "@alice/friends" {
+		t.Fatalf("List must be restored with its header: %+v", restored.listview.list)
+	}
+}
+
+func TestSaveCacheError(t *testing.T) {
+	initialize()
+	initializeState()
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	view := newView(newFakeClient(), newTweetStore(filepath.Join(file, "12345")))
+	view.saveCache()
+	waitState(t, "Err:Saving cache")
+}
@@ -0,0 +1,131 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// threadSeparator is a line splitting the text of a thread into tweets
+const threadSeparator = "---"
+
+// threadDraft is the rest of a thread which failed to be posted
+type threadDraft struct {
+	parts []string
+	// inReplyTo is the last posted tweet of the thread
+	inReplyTo int64
+}
+
+// text joins parts to edit them again
+func (d *threadDraft) text() string {
+	return strings.Join(d.parts, "\n"+threadSeparator+"\n")
+}
+
+// splitThread returns the text of each tweet, a part may be empty
+func splitThread(text string) []string {
+	parts := make([]string, 0)
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == threadSeparator {
+			parts = append(parts, strings.TrimSpace(strings.Join(lines, "\n")))
+			lines = lines[:0]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return append(parts, strings.TrimSpace(strings.Join(lines, "\n")))
+}
+
+// threadPartAt returns the index of the part at the byte offset of text,
+// a separator line belongs to the part above it
+func threadPartAt(text string, offset int) int {
+	lines := strings.Split(text[:offset], "\n")
+	index := 0
+	for _, line := range lines[:len(lines)-1] {
+		if strings.TrimSpace(line) == threadSeparator {
+			index++
+		}
+	}
+	return index
+}
+
+// threadTweets returns parts to post, empty ones are skipped
+func threadTweets(text string) []string {
+	tweets := make([]string, 0)
+	for _, p := range splitThread(text) {
+		if p != "" {
+			tweets = append(tweets, p)
+		}
+	}
+	return tweets
+}
+
+// turnThreadMode opens the input area for a thread, or for the rest of
+// the thread which failed to be posted
+func (view *view) turnThreadMode() {
+	view.buffer.inputing = true
+	view.buffer.counting = true
+	view.buffer.thread = true
+	view.buffer.inputTitle = "*Thread Edit Mode*"
+	draft := view.thread
+	if draft != nil {
+		view.buffer.inputTitle = "*Thread Edit Mode (resume)*"
+		view.buffer.setContent(draft.text())
+	} else {
+		view.buffer.clear()
+	}
+	view.buffer.updateCursorPosition()
+	view.buffer.post = func(text string, media attachments) {
+		inReplyTo := int64(0)
+		if draft != nil {
+			inReplyTo = draft.inReplyTo
+		}
+		view.sendThread(threadTweets(text), inReplyTo, media)
+	}
+}
+
+// sendThread posts tweets in order, each replies to the previous one
+func (view *view) sendThread(tweets []string, inReplyTo int64, media attachments) {
+	if view.timelineview.loading.isLocking() || len(tweets) == 0 {
+		view.restoreAttachments(media)
+		return
+	}
+	view.timelineview.loading.lock()
+	defer view.timelineview.loading.unlock()
+	for i, status := range tweets {
+		val := url.Values{}
+		if inReplyTo != 0 {
+			val.Set("in_reply_to_status_id", strconv.FormatInt(inReplyTo, 10))
+		}
+		t, err := view.postTweet(fmt.Sprintf("Thread %d/%d", i+1, len(tweets)), status, val, media)
+		if err != nil {
+			rest := &threadDraft{parts: tweets[i:], inReplyTo: inReplyTo}
+			view.updateCh <- func() { view.thread = rest }
+			notifyWarning("Thread", fmt.Sprintf("stopped at %d/%d: %v, :thread resumes the rest", i+1, len(tweets), err))
+			return
+		}
+		// Media are attached to the first tweet
+		media = nil
+		inReplyTo = t.Id
+	}
+	view.updateCh <- func() { view.thread = nil }
+	changeBufferState(fmt.Sprintf("Thread!(%d tweets)", len(tweets)))
+}
@@ -0,0 +1,86 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitThread(t *testing.T) {
+	text := "first\nline\n---\n\n  ---  \nthird\n---"
+	parts := splitThread(text)
+	if !reflect.DeepEqual(parts, []string{"first\nline", "", "third", ""}) {
+		t.Fatalf("Unexpected parts: %q", parts)
+	}
+	if tweets := threadTweets(text); !reflect.DeepEqual(tweets, []string{"first\nline", "third"}) {
+		t.Fatalf("Empty parts must be skipped: %q", tweets)
+	}
+	testcase := []struct {
+		offset, expected int
+	}{
+		{0, 0},
+		{strings.Index(text, "---") + 2, 0},
+		{strings.Index(text, "---") + 4, 1},
+		{strings.Index(text, "third"), 2},
+		{len(text), 2},
+	}
+	for _, c := range testcase {
+		if i := threadPartAt(text, c.offset); i != c.expected {
+			t.Fatalf("Offset %d: Expected part %d, but %d", c.offset, c.expected, i)
+		}
+	}
+
+	buffer := newBuffer()
+	buffer.counting = true
+	buffer.thread = true
+	buffer.setContent("short\n---\n" + strings.Repeat("a", TweetLengthMax+1))
+	if part, over := buffer.overLimit(); part != 1 || over != 1 {
+		t.Fatalf("Each part must be checked: part %d, %d over", part, over)
+	}
+}
+
+func TestSendThread(t *testing.T) {
+	initialize()
+	initializeState()
+	user = UserConfig{ID: 1, ScreenName: "me"}
+	client := newFakeClient()
+	view := newView(client, nil)
+	client.postLimit = 2
+
+	go view.sendThread([]string{"1/3", "2/3", "3/3"}, 0, nil)
+	receiveUpdate(t, view.updateCh)
+	if view.thread == nil || len(view.thread.parts) != 1 || view.thread.inReplyTo != client.posted[1].Id {
+		t.Fatalf("The rest of the thread must be kept: %+v", view.thread)
+	}
+	if client.posted[0].InReplyToStatusID != 0 || client.posted[1].InReplyToStatusID != client.posted[0].Id {
+		t.Fatalf("Tweets must be chained")
+	}
+
+	client.postLimit = 0
+	if !reflect.DeepEqual(splitThread(view.thread.text()), view.thread.parts) {
+		t.Fatalf("The rest must be restored: %q", view.thread.text())
+	}
+	go view.sendThread(view.thread.parts, view.thread.inReplyTo, nil)
+	receiveUpdate(t, view.updateCh)
+	if view.thread != nil || len(client.posted) != 3 || client.posted[2].InReplyToStatusID != client.posted[1].Id {
+		t.Fatalf("Resumed thread must reply to the last posted tweet")
+	}
+	waitState(t, "Thread!(1 tweets)")
+}
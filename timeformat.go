@@ -0,0 +1,116 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Styles of timestamps, used by "style" of time_format and :timefmt
+const (
+	timeStyleRelative = "relative"
+	timeStyleISO      = "iso"
+	timeStyleZone     = "zone"
+)
+
+// timeStyles is the order of styles switched by the key
+var timeStyles = []string{timeStyleRelative, timeStyleISO, timeStyleZone}
+
+func isTimeStyle(s string) bool {
+	for _, style := range timeStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// nextTimeStyle returns the style after s, to switch styles by a key
+func nextTimeStyle(s string) string {
+	for i, style := range timeStyles {
+		if s == style {
+			return timeStyles[(i+1)%len(timeStyles)]
+		}
+	}
+	return timeStyles[0]
+}
+
+// setTimezone changes the location used by "zone" style, "" means local time
+func (tf *TimeFormat) setTimezone(name string) error {
+	if name == "" {
+		tf.Timezone = ""
+		tf.location = nil
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	tf.Timezone = name
+	tf.location = loc
+	return nil
+}
+
+// format returns the timestamp of created shown under tweets
+func (tf *TimeFormat) format(created, now time.Time) string {
+	switch tf.Style {
+	case timeStyleISO:
+		return created.Local().Format(time.RFC3339)
+	case timeStyleZone:
+		loc := tf.location
+		if loc == nil {
+			loc = time.Local
+		}
+		return created.In(loc).Format("2006-01-02 15:04:05 MST")
+	}
+	return tf.relative(created, now)
+}
+
+// relative formats created like "3 hours ago", older ones have dates of local time
+func (tf *TimeFormat) relative(created, now time.Time) string {
+	created = created.Local()
+	now = now.Local()
+	sub := now.Sub(created)
+	date := fmt.Sprintf("%d/%d/%d %02d:%02d",
+		created.Year(), created.Month(), created.Day(), created.Hour(), created.Minute())
+	switch {
+	case sub <= time.Duration(tf.Now):
+		return "now"
+	case sub <= time.Duration(tf.FewMinutes):
+		return "A few minutes ago"
+	case sub <= time.Duration(tf.Minutes):
+		return fmt.Sprintf("%d minutes ago", sub/time.Minute)
+	case sub <= time.Duration(tf.Hours):
+		return fmt.Sprintf("%d hours ago", sub/time.Hour)
+	case sub <= time.Duration(tf.Days):
+		return fmt.Sprintf("%s (%d days ago)", date, calendarDays(created, now))
+	}
+	return date
+}
+
+// calendarDays returns how many dates are between from and to,
+// ex) 23:00 yesterday is 1 day ago even at 01:00 today
+func calendarDays(from, to time.Time) int {
+	y1, m1, d1 := from.Date()
+	y2, m2, d2 := to.Date()
+	// Dates at noon of UTC are not affected by daylight saving time
+	a := time.Date(y1, m1, d1, 12, 0, 0, 0, time.UTC)
+	b := time.Date(y2, m2, d2, 12, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
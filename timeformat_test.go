@@ -0,0 +1,87 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRelativeTimeFormat(t *testing.T) {
+	tf := defaultSettings().TimeFormat
+	now := time.Date(2016, 5, 10, 1, 0, 0, 0, time.Local)
+	testcase := []struct {
+		ago      time.Duration
+		expected string
+	}{
+		{time.Second * 10, "now"},
+		{time.Minute * 3, "A few minutes ago"},
+		{time.Minute * 90, "90 minutes ago"},
+		{time.Hour * 30, "30 hours ago"},
+		// 3 days and 2 hours before is 23:00 of May 6th, 4 dates ago
+		{time.Hour * 74, "2016/5/6 23:00 (4 days ago)"},
+		{time.Hour * 24 * 20, "2016/4/20 01:00"},
+	}
+	for _, c := range testcase {
+		if s := tf.format(now.Add(-c.ago), now); s != c.expected {
+			t.Fatalf("%v ago: Expected %q, but %q", c.ago, c.expected, s)
+		}
+	}
+}
+
+func TestAbsoluteTimeFormat(t *testing.T) {
+	tf := defaultSettings().TimeFormat
+	created := time.Date(2016, 5, 10, 1, 2, 3, 0, time.UTC)
+
+	tf.Style = timeStyleISO
+	if s := tf.format(created, created); s != created.Local().Format(time.RFC3339) {
+		t.Fatalf("Unexpected ISO format: %q", s)
+	}
+
+	tf.Style = timeStyleZone
+	if err := tf.setTimezone("Asia/Tokyo"); err != nil {
+		t.Skip("tzdata is not available: ", err)
+	}
+	if s := tf.format(created, created); s != "2016-05-10 10:02:03 JST" {
+		t.Fatalf("Unexpected zoned format: %q", s)
+	}
+	if err := tf.setTimezone("Nowhere/Unknown"); err == nil {
+		t.Fatalf("Unknown timezone must be an error")
+	}
+}
+
+func TestCalendarDays(t *testing.T) {
+	loc := time.FixedZone("X", 9*60*60)
+	from := time.Date(2016, 5, 9, 23, 0, 0, 0, loc)
+	if d := calendarDays(from, time.Date(2016, 5, 10, 0, 30, 0, 0, loc)); d != 1 {
+		t.Fatalf("Yesterday must be 1 day ago, but %d", d)
+	}
+	if d := calendarDays(from, time.Date(2016, 5, 9, 23, 59, 0, 0, loc)); d != 0 {
+		t.Fatalf("Today must be 0 days ago, but %d", d)
+	}
+}
+
+func TestNextTimeStyle(t *testing.T) {
+	s := timeStyleRelative
+	for i := 0; i < len(timeStyles); i++ {
+		s = nextTimeStyle(s)
+	}
+	if s != timeStyleRelative || nextTimeStyle("unknown") != timeStyleRelative {
+		t.Fatalf("Styles must be switched in a cycle")
+	}
+}
@@ -19,50 +19,50 @@ package main
 
 import (
 	"fmt"
-	"github.com/ChimeraCoder/anaconda"
-	"net/url"
 	"strconv"
 	"strings"
 )
 
 type timelineview struct {
 	*tweetview
+	client TwitterClient
 
 	loading             lock
-	loadNewTweetCh      chan []anaconda.Tweet
-	loadIntervalTweetCh chan []anaconda.Tweet
+	loadNewTweetCh      chan []Tweet
+	loadIntervalTweetCh chan []Tweet
 }
 
-func newTimelineview() *timelineview {
+func newTimelineview(client TwitterClient) *timelineview {
 	return &timelineview{
 		tweetview:           newTweetview(),
-		loadNewTweetCh:      make(chan []anaconda.Tweet),
-		loadIntervalTweetCh: make(chan []anaconda.Tweet),
+		client:              client,
+		loadNewTweetCh:      make(chan []Tweet),
+		loadIntervalTweetCh: make(chan []Tweet),
 	}
 }
 
-func (tv *timelineview) loadTweet(sinceID int64) {
+func (tv *timelineview) loadTweet(sinceID int64) error {
 	if tv.loading.isLocking() {
-		return
+		return nil
 	}
 	tv.loading.lock()
 	defer tv.loading.unlock()
 	changeBufferState("Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(CountTweet))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Home.New))
 	if sinceID > 0 {
 		val.Add("since_id", strconv.FormatInt(sinceID, 10))
 	}
-	timeline, err := api.GetHomeTimeline(val)
+	timeline, err := tv.client.GetHomeTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
-		return
+		notifyError("Loading", err)
+		return err
 	}
 
 	mentionCount := 0
 	sn := "@" + user.ScreenName
 	for _, t := range timeline {
-		if strings.Contains(t.Text, sn) {
+		if strings.Contains(fullText(&t), sn) {
 			mentionCount++
 		}
 	}
@@ -72,6 +72,7 @@ func (tv *timelineview) loadTweet(sinceID int64) {
 		changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
 	}
 	tv.loadNewTweetCh <- timeline
+	return nil
 }
 
 func (tv *timelineview) loadIntervalTweet(maxID int64) {
@@ -81,14 +82,14 @@ func (tv *timelineview) loadIntervalTweet(maxID int64) {
 	tv.loading.lock()
 	defer tv.loading.unlock()
 	changeBufferState("Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(50))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.Home.Older))
 	if maxID > 0 {
 		val.Add("max_id", strconv.FormatInt(maxID, 10))
 	}
-	timeline, err := api.GetHomeTimeline(val)
+	timeline, err := tv.client.GetHomeTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -0,0 +1,200 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	clist "container/list"
+	"errors"
+	"github.com/ChimeraCoder/anaconda"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// initializeState clears the state shared by tests, goroutines started by
+// former tests may still use it, so it is cleared in place instead of replaced
+func initializeState() {
+	if stateCh == nil {
+		stateCh = make(chan notification, 64)
+		stateClearCh = make(chan int, 64)
+		messages = newMessageLog(MessageLogMax)
+		tweetmap = newTweetMap()
+		profilemap = newProfileMap()
+		return
+	}
+	for len(stateCh) > 0 {
+		<-stateCh
+	}
+	for len(stateClearCh) > 0 {
+		<-stateClearCh
+	}
+	messages.mutex.Lock()
+	messages.entries = messages.entries[:0]
+	messages.mutex.Unlock()
+	tweetmap.mutex.Lock()
+	tweetmap.content = make(map[int64]*clist.Element, 300)
+	tweetmap.order = clist.New()
+	tweetmap.capacity = TweetMapCapacity
+	tweetmap.size = 0
+	tweetmap.pinned = nil
+	tweetmap.mutex.Unlock()
+	profilemap.mutex.Lock()
+	profilemap.content = make(map[string]*anaconda.User, 300)
+	profilemap.mutex.Unlock()
+}
+
+func waitState(t *testing.T, prefix string) string {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case n := <-stateCh:
+			if s := n.String(); strings.HasPrefix(s, prefix) {
+				return s
+			}
+		case <-timeout:
+			t.Fatalf("state starting with %q was not reported", prefix)
+		}
+	}
+}
+
+func receiveTweets(t *testing.T, ch chan []Tweet) []Tweet {
+	select {
+	case tw := <-ch:
+		return tw
+	case <-time.After(time.Second):
+		t.Fatalf("tweets were not delivered")
+	}
+	return nil
+}
+
+func TestTimelineviewLoadAndMerge(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	for id := int64(1); id <= 5; id++ {
+		client.home = append(client.home, newFakeTweet(id, "alice", "hello"))
+	}
+	tv := newTimelineview(client)
+
+	go tv.loadTweet(0)
+	tw := receiveTweets(t, tv.loadNewTweetCh)
+	tv.addNewTweet(wrapTweets(tw))
+	if len(tv.tweets) != 6 || tv.tweets[0].Content.Id != 5 || !tv.tweets[5].ReloadMark {
+		t.Fatalf("Unexpected timeline after first load: %d tweets", len(tv.tweets))
+	}
+
+	// Only tweets newer than the top one must be fetched
+	tv.cursorPosition = 2
+	client.home = append(client.home, newFakeTweet(6, "bob", "new"), newFakeTweet(7, "bob", "newer"))
+	go tv.loadTweet(tv.tweets[0].Content.Id)
+	tw = receiveTweets(t, tv.loadNewTweetCh)
+	if len(tw) != 2 {
+		t.Fatalf("Expected 2 new tweets, but %d", len(tw))
+	}
+	tv.addNewTweet(wrapTweets(tw))
+	if tv.tweets[0].Content.Id != 7 || tv.tweets[2].Content.Id != 5 {
+		t.Fatalf("New tweets were not merged on top")
+	}
+	if tv.tweets[tv.cursorPosition].Content.Id != 3 {
+		t.Fatalf("Cursor must stay on the same tweet, but moved to %d",
+			tv.tweets[tv.cursorPosition].Content.Id)
+	}
+	if tv.unread != 2 {
+		t.Fatalf("Expected 2 unread tweets, but %d", tv.unread)
+	}
+	for i := 0; i < 3; i++ {
+		tv.cursorUp()
+	}
+	if tv.unread != 1 {
+		t.Fatalf("Expected 1 unread tweet above the cursor, but %d", tv.unread)
+	}
+}
+
+func TestTimelineviewLoadInterval(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	for id := int64(1); id <= 10; id++ {
+		client.home = append(client.home, newFakeTweet(id, "alice", "hello"))
+	}
+	tv := newTimelineview(client)
+	tv.addNewTweet(wrapTweets(filterTimeline(client.home, nil)[:3]))
+
+	go tv.loadIntervalTweet(tv.tweets[2].Content.Id)
+	tw := receiveTweets(t, tv.loadIntervalTweetCh)
+	tv.addIntervalTweet(tw)
+	if len(tv.tweets) != 11 {
+		t.Fatalf("Expected 10 tweets and a reload mark, but %d", len(tv.tweets))
+	}
+	for i := 0; i < 10; i++ {
+		if tv.tweets[i].Content.Id != int64(10-i) {
+			t.Fatalf("Duplicated or missing tweet at %d", i)
+		}
+	}
+}
+
+func TestTimelineviewLoadError(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	client.err = errors.New("network is down")
+	tv := newTimelineview(client)
+
+	go tv.loadTweet(0)
+	waitState(t, "Err")
+	select {
+	case <-tv.loadNewTweetCh:
+		t.Fatalf("No tweets must be delivered when loading failed")
+	case <-time.After(time.Millisecond * 50):
+	}
+	if tv.loading.isLocking() {
+		t.Fatalf("loading lock must be released after an error")
+	}
+}
+
+func TestTweetviewFillGap(t *testing.T) {
+	initialize()
+	client := newFakeClient()
+	for id := int64(1); id <= 10; id++ {
+		client.home = append(client.home, newFakeTweet(id, "alice", "hello"))
+	}
+	all := filterTimeline(client.home, nil)
+	tv := newTweetview()
+	// 10, 9 | gap | 3, 2, 1
+	tv.addNewTweet(wrapTweets(all[7:]))
+	tv.addNewTweetWithGap(wrapTweets(all[:2]))
+	if !tv.tweets[2].ReloadMark {
+		t.Fatalf("A gap must be marked below new tweets")
+	}
+	tv.cursorPosition = 4
+	cursorID := tv.tweets[tv.cursorPosition].Content.Id
+
+	tv.addIntervalTweet(filterTimeline(client.home, url.Values{"max_id": {"9"}}))
+	for i := 0; i < 10; i++ {
+		if tv.tweets[i].ReloadMark || tv.tweets[i].Content.Id != int64(10-i) {
+			t.Fatalf("The gap was not filled correctly at %d", i)
+		}
+	}
+	if !tv.tweets[10].ReloadMark {
+		t.Fatalf("The last ReloadMark must be kept")
+	}
+	if tv.tweets[tv.cursorPosition].Content.Id != cursorID {
+		t.Fatalf("Cursor must stay on the tweet being read")
+	}
+}
@@ -0,0 +1,60 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/ChimeraCoder/anaconda"
+)
+
+// Tweet is anaconda.Tweet with fields which the vendored anaconda doesn't decode,
+// anacondaClient decodes responses itself to keep them
+type Tweet struct {
+	anaconda.Tweet
+	Entities         Entities `json:"entities"`
+	ExtendedEntities Entities `json:"extended_entities"`
+	// FullText is the text with tweet_mode=extended
+	FullText string `json:"full_text"`
+	// ExtendedTweet is the text over 140 characters without tweet_mode=extended
+	ExtendedTweet   *ExtendedTweet `json:"extended_tweet"`
+	QuotedStatusID  int64          `json:"quoted_status_id"`
+	QuotedStatus    *Tweet         `json:"quoted_status"`
+	RetweetedStatus *Tweet         `json:"retweeted_status"`
+}
+
+// Entities is anaconda.Entities with cashtags, ex) "$TWTR"
+type Entities struct {
+	anaconda.Entities
+	Symbols []struct {
+		Indices []int
+		Text    string
+	}
+}
+
+// ExtendedTweet is the full text of a tweet over 140 characters in compatibility mode
+type ExtendedTweet struct {
+	FullText         string   `json:"full_text"`
+	DisplayTextRange []int    `json:"display_text_range"`
+	Entities         Entities `json:"entities"`
+	ExtendedEntities Entities `json:"extended_entities"`
+}
+
+// SearchResponse is anaconda.SearchResponse of Tweet
+type SearchResponse struct {
+	Statuses []Tweet                 `json:"statuses"`
+	Metadata anaconda.SearchMetadata `json:"search_metadata"`
+}
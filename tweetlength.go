@@ -0,0 +1,184 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// Rules of twitter-text to count the length of a tweet
+const (
+	// TweetLengthMax is the weighted length a tweet can have
+	TweetLengthMax = 280
+	// URLs are shortened to t.co links of this length
+	TransformedURLLength = 23
+	// Weights are scaled, characters out of lightRuneRanges count twice
+	weightScale   = 100
+	defaultWeight = 200
+	lightWeight   = 100
+)
+
+// lightRuneRanges are Latin-1, most other scripts and some punctuations
+var lightRuneRanges = [][2]rune{
+	{0x0000, 0x10FF},
+	{0x2000, 0x200D},
+	{0x2010, 0x201F},
+	{0x2032, 0x2037},
+}
+
+// urlPattern matches URLs with a scheme, and domains with common TLDs,
+// ex) "https://github.com/pinkienort/ringot", "example.com/about"
+var urlPattern = regexp.MustCompile(`(?i)https?://[^\s]+|` +
+	`\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+` +
+	`(?:com|net|org|edu|gov|info|biz|io|co|me|jp|uk|de|fr|ly|gl|tv|dev|app)\b` +
+	`(?::[0-9]+)?(?:/[^\s]*)?`)
+
+func runeWeight(r rune) int {
+	for _, rg := range lightRuneRanges {
+		if rg[0] <= r && r <= rg[1] {
+			return lightWeight
+		}
+	}
+	return defaultWeight
+}
+
+// tweetLength counts text as Twitter does, ex) a URL is 23, a kanji is 2
+func tweetLength(text string) int {
+	weight := 0
+	last := 0
+	for _, loc := range urlIndices(text) {
+		weight += textWeight(text[last:loc[0]])
+		weight += TransformedURLLength * weightScale
+		last = loc[1]
+	}
+	weight += textWeight(text[last:])
+	return weight / weightScale
+}
+
+// textWeight counts an emoji sequence as one character of defaultWeight,
+// as twitter-text does, ex) "\U0001F468\u200D\U0001F469\u200D\U0001F467" is 2
+func textWeight(text string) int {
+	weight := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if n := emojiLength(runes[i:]); n > 0 {
+			weight += defaultWeight
+			i += n
+			continue
+		}
+		weight += runeWeight(runes[i])
+		i++
+	}
+	return weight
+}
+
+// Code points composing emoji sequences
+const (
+	zeroWidthJoiner   = 0x200D
+	variationSelector = 0xFE0F
+	combiningKeycap   = 0x20E3
+	skinToneFirst     = 0x1F3FB
+	skinToneLast      = 0x1F3FF
+	regionalFirst     = 0x1F1E6
+	regionalLast      = 0x1F1FF
+	tagFirst          = 0xE0020
+	tagLast           = 0xE007F
+)
+
+// emojiRuneRanges are pictographs which can start an emoji sequence
+var emojiRuneRanges = [][2]rune{
+	{0x2300, 0x23FF},
+	{0x2600, 0x27BF},
+	{0x2B00, 0x2BFF},
+	{0x1F000, 0x1FAFF},
+}
+
+func isEmojiRune(r rune) bool {
+	for _, rg := range emojiRuneRanges {
+		if rg[0] <= r && r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmojiModifier(r rune) bool {
+	return r == variationSelector || (skinToneFirst <= r && r <= skinToneLast) ||
+		(tagFirst <= r && r <= tagLast)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return regionalFirst <= r && r <= regionalLast
+}
+
+// emojiLength returns the number of runes of the emoji sequence at the head of runes,
+// such as keycaps, flags, and pictographs with modifiers joined by ZWJ
+func emojiLength(runes []rune) int {
+	if len(runes) == 0 {
+		return 0
+	}
+	r := runes[0]
+	if (r == '#' || r == '*' || ('0' <= r && r <= '9')) && len(runes) > 1 {
+		n := 1
+		if runes[n] == variationSelector && len(runes) > 2 {
+			n++
+		}
+		if runes[n] == combiningKeycap {
+			return n + 1
+		}
+		return 0
+	}
+	if isRegionalIndicator(r) {
+		if len(runes) > 1 && isRegionalIndicator(runes[1]) {
+			return 2
+		}
+		return 1
+	}
+	if !isEmojiRune(r) && !(len(runes) > 1 && runes[1] == variationSelector) {
+		return 0
+	}
+	n := 1
+	for n < len(runes) {
+		if isEmojiModifier(runes[n]) {
+			n++
+		} else if runes[n] == zeroWidthJoiner && n+1 < len(runes) && isEmojiRune(runes[n+1]) {
+			n += 2
+		} else {
+			break
+		}
+	}
+	return n
+}
+
+// urlIndices returns byte ranges of URLs in text, except domains of e-mail addresses
+func urlIndices(text string) [][]int {
+	indices := make([][]int, 0)
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		if r, _ := utf8.DecodeLastRuneInString(text[:loc[0]]); r == '@' {
+			continue
+		}
+		indices = append(indices, loc)
+	}
+	return indices
+}
+
+// remainingLength is negative when text is too long to tweet
+func remainingLength(text string) int {
+	return TweetLengthMax - tweetLength(text)
+}
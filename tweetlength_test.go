@@ -0,0 +1,76 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTweetLength(t *testing.T) {
+	testcase := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"Hello, world", 12},
+		{"こんにちは", 10},
+		{"café “quoted”", 13},
+		{"😀", 2},
+		{"\U0001F468\u200D\U0001F469\u200D\U0001F467", 2},
+		{"\U0001F44D\U0001F3FD ok", 5},
+		{"\U0001F1EF\U0001F1F5\U0001F1FA\U0001F1F8", 4},
+		{"\u2764\uFE0F", 2},
+		{"#\uFE0F\u20E3 #1", 5},
+		{"\U0001F3F4\U000E0067\U000E0062\U000E0065\U000E006E\U000E0067\U000E007F", 2},
+		{"see https://github.com/pinkienort/ringot/blob/master/README.md", 4 + TransformedURLLength},
+		{"example.com and http://a.b", TransformedURLLength*2 + 5},
+		{"mail me@example.com", 19},
+		{"日本語 example.jp/とても", 7 + TransformedURLLength},
+	}
+	for _, c := range testcase {
+		if l := tweetLength(c.text); l != c.expected {
+			t.Fatalf("%q: Expected %d, but %d", c.text, c.expected, l)
+		}
+	}
+	if r := remainingLength(strings.Repeat("あ", TweetLengthMax/2+1)); r != -2 {
+		t.Fatalf("Expected -2, but %d", r)
+	}
+}
+
+func TestBufferLengthLimit(t *testing.T) {
+	initialize()
+	buffer := newBuffer()
+	buffer.setContent(strings.Repeat("a", TweetLengthMax))
+	if _, over := buffer.overLimit(); over != 0 {
+		t.Fatalf("Length must be counted only while writing a tweet")
+	}
+	buffer.counting = true
+	if _, over := buffer.overLimit(); over != 0 {
+		t.Fatalf("%d characters must be able to be tweeted", TweetLengthMax)
+	}
+	buffer.insertRune('あ')
+	if _, over := buffer.overLimit(); over != 2 {
+		t.Fatalf("Expected 2 over, but %d", over)
+	}
+	buffer.setContent(strings.Repeat("a", TweetLengthMax*2))
+	buffer.insertRune('a')
+	if tweetLength(string(buffer.content)) != TweetLengthMax*2 {
+		t.Fatalf("Too long text must not be written")
+	}
+}
@@ -19,7 +19,6 @@ package main
 
 import (
 	"fmt"
-	"github.com/ChimeraCoder/anaconda"
 	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
 	"strings"
@@ -31,6 +30,8 @@ type tweetview struct {
 	cursorPosition int
 	scroll         int
 	scrollOffset   int
+	// Number of new tweets above the cursor which are not read yet
+	unread int
 }
 
 func newTweetview() *tweetview {
@@ -50,8 +51,8 @@ func (tv *tweetview) isEmpty() bool {
 
 func (tv *tweetview) cursorDown() {
 	_, h := getTermSize()
-	if tv.cursorPosition+1 < len(tv.tweets) {
-		tv.cursorPosition++
+	if next := tv.visibleFrom(tv.cursorPosition+1, 1); next >= 0 {
+		tv.cursorPosition = next
 		sum := 0
 		tweets := tv.tweets[:tv.cursorPosition+1]
 		for _, t := range tweets {
@@ -65,8 +66,8 @@ func (tv *tweetview) cursorDown() {
 }
 
 func (tv *tweetview) cursorUp() {
-	if tv.cursorPosition > 0 {
-		tv.cursorPosition--
+	if prev := tv.visibleFrom(tv.cursorPosition-1, -1); prev >= 0 {
+		tv.cursorPosition = prev
 		sum := 0
 		tweets := tv.tweets[:tv.cursorPosition]
 		for _, t := range tweets {
@@ -79,17 +80,49 @@ func (tv *tweetview) cursorUp() {
 				tv.scroll = 0
 			}
 		}
+		if above := tv.visibleCount(tv.cursorPosition); tv.unread > above {
+			tv.unread = above
+		}
+	}
+}
+
+// visibleFrom returns the index of the first tweet not hidden by filters,
+// searching from index by step, or -1 when there is no such tweet
+func (tv *tweetview) visibleFrom(index, step int) int {
+	for ; index >= 0 && index < len(tv.tweets); index += step {
+		if !tv.tweets[index].hidden() {
+			return index
+		}
+	}
+	return -1
+}
+
+// visibleCount returns the number of tweets above end which are not hidden
+func (tv *tweetview) visibleCount(end int) int {
+	count := 0
+	for _, t := range tv.tweets[:end] {
+		if !t.hidden() {
+			count++
+		}
 	}
+	return count
 }
 
 func (tv *tweetview) cursorMoveToTop() {
 	tv.cursorPosition = 0
+	if first := tv.visibleFrom(0, 1); first >= 0 {
+		tv.cursorPosition = first
+	}
 	tv.scroll = 0
+	tv.unread = 0
 }
 
 func (tv *tweetview) cursorMoveToBottom() {
 	_, height := getTermSize()
 	tv.cursorPosition = len(tv.tweets) - 1
+	if last := tv.visibleFrom(len(tv.tweets)-1, -1); last >= 0 {
+		tv.cursorPosition = last
+	}
 
 	sum := 0
 	for _, t := range tv.tweets {
@@ -102,33 +135,146 @@ func (tv *tweetview) addNewTweet(tss []tweetstatus) {
 	if len(tv.tweets) > 1 {
 		tv.scroll += sumTweetLines(tss)
 		tv.cursorPosition += len(tss)
+		for _, ts := range tss {
+			if ts.Content != nil && !ts.hidden() {
+				tv.unread++
+			}
+		}
 	}
 	tv.tweets = append(tss, tv.tweets...)
 }
 
-func (tv *tweetview) addIntervalTweet(tss []tweetstatus) {
-	adjustScroll := false
-	if tv.tweets[tv.cursorPosition].ReloadMark {
-		adjustScroll = true
+// addNewTweetWithGap is addNewTweet for responses which may not reach the
+// tweets already loaded, so a ReloadMark is kept between them
+func (tv *tweetview) addNewTweetWithGap(tss []tweetstatus) {
+	if tv.isEmpty() || len(tss) == 0 {
+		tv.addNewTweet(tss)
+		return
 	}
+	t := make([]tweetstatus, len(tss), len(tss)+1)
+	copy(t, tss)
+	t = append(t, tweetstatus{ReloadMark: true})
+	tv.addNewTweet(t)
+}
 
-	i := 0
-	for i = range tv.tweets {
-		if tv.tweets[i].ReloadMark {
-			break
+// gapMaxID returns max_id to load tweets of the ReloadMark at index,
+// which is the tweet above the mark even if it is hidden by filters
+func (tv *tweetview) gapMaxID(index int) int64 {
+	if index < 0 || index >= len(tv.tweets) {
+		return 0
+	}
+	if index > 0 && tv.tweets[index-1].Content != nil {
+		return tv.tweets[index-1].Content.Id
+	}
+	return 0
+}
+
+// findGap returns the index of ReloadMark loaded from maxID,
+// or the first ReloadMark when there is no such mark
+func (tv *tweetview) findGap(maxID int64) int {
+	first := -1
+	for i, t := range tv.tweets {
+		if !t.ReloadMark {
+			continue
+		}
+		if tv.gapMaxID(i) == maxID {
+			return i
+		}
+		if first < 0 {
+			first = i
 		}
 	}
-	tweets := tv.tweets[:i]
-	if tweets[len(tweets)-1].Content.Id == tss[0].Content.Id {
-		tss = tss[1:]
+	return first
+}
+
+// addIntervalTweet fills the gap with a response of max_id
+func (tv *tweetview) addIntervalTweet(tweets []Tweet) {
+	if len(tweets) == 0 {
+		return
 	}
-	t := append(tweets, tss...)
-	t = append(t, tweetstatus{ReloadMark: true})
+	maxID := tweets[0].Id
+	gap := tv.findGap(maxID)
+	if gap < 0 {
+		return
+	}
+	above := tv.tweets[:gap]
+	below := tv.tweets[gap+1:]
+	// max_id is inclusive, the first one has been loaded already
+	if tv.gapMaxID(gap) == maxID {
+		tweets = tweets[1:]
+	}
+	if len(tweets) == 0 {
+		return
+	}
+	// The gap is filled when loaded tweets reach the tweets below it
+	closed := false
+	if len(below) > 0 && below[0].Content != nil {
+		for i := range tweets {
+			if tweets[i].Id <= below[0].Content.Id {
+				tweets = tweets[:i]
+				closed = true
+				break
+			}
+		}
+	}
+	tss := wrapTweets(tweets)
+	t := make([]tweetstatus, 0, len(tv.tweets)+len(tss))
+	t = append(t, above...)
+	t = append(t, tss...)
+	if !closed {
+		t = append(t, tweetstatus{ReloadMark: true})
+	}
+	t = append(t, below...)
+
+	prevLines := sumTweetLines(tv.tweets)
+	prevLen := len(tv.tweets)
 	tv.tweets = t
-	if adjustScroll {
+	if tv.cursorPosition == gap {
 		tv.cursorPosition--
 		tv.cursorDown()
+	} else if tv.cursorPosition > gap {
+		// Keep the cursor on the tweet being read
+		tv.cursorPosition += len(t) - prevLen
+		tv.scroll += sumTweetLines(t) - prevLines
+	}
+}
+
+// removeTweets removes tweets which f returns true for, the cursor stays
+// on the same tweet, or moves to the one above the removed tweet
+func (tv *tweetview) removeTweets(f func(tweetstatus) bool) {
+	t := make([]tweetstatus, 0, len(tv.tweets))
+	cursor := 0
+	for i, ts := range tv.tweets {
+		if ts.Content != nil && f(ts) {
+			continue
+		}
+		if i <= tv.cursorPosition {
+			cursor = len(t)
+		}
+		t = append(t, ts)
+	}
+	if len(t) == len(tv.tweets) {
+		return
+	}
+	if len(t) == 0 {
+		t = append(t, tweetstatus{ReloadMark: true})
+	}
+	if tv.unread > cursor {
+		tv.unread = cursor
+	}
+	tv.tweets = t
+	tv.cursorPosition = cursor
+	tv.resetScroll()
+}
+
+// newestID returns since_id to load new tweets, tweets hidden by filters count
+func (tv *tweetview) newestID() int64 {
+	for _, t := range tv.tweets {
+		if t.Content != nil {
+			return t.Content.Id
+		}
 	}
+	return 0
 }
 
 const (
@@ -146,6 +292,9 @@ func (tv *tweetview) draw() {
 
 	for ; index < len(tweets); index++ {
 		tweetstatus := tweets[index]
+		if tweetstatus.hidden() {
+			continue
+		}
 		countLine := tweetstatus.countLines()
 		if y > height {
 			break
@@ -169,6 +318,11 @@ func (tv *tweetview) draw() {
 			y++
 			continue
 		}
+		if tweetstatus.Filtered != "" {
+			tv.drawFiltered(tweetstatus, y, cursorColor)
+			y++
+			continue
+		}
 		tweet := tweetstatus.Content
 		favorited := tweet.Favorited
 		retweeted := tweet.Retweeted
@@ -211,47 +365,31 @@ func (tv *tweetview) draw() {
 		y++
 
 		lines := strings.Split(runewidth.Wrap(text, width-2), "\n")
+		offsets := lineOffsets(text, lines)
+		spans := entitySpans(tweet)
 		for i, t := range lines {
 			drawText(" ", 0, y+i, ColorBackground, labelColor)
 			drawText(" ", 1, y+i, ColorWhite, cursorColor)
-			drawTextWithAutoNotice(t, 2, y+i, ColorWhite, bgColor)
+			drawTextWithEntities(t, offsets[i], spans, 2, y+i, ColorWhite, bgColor)
 		}
 		y += len(lines)
 
+		if tweet.QuotedStatus != nil {
+			quote := quoteLines(tweet.QuotedStatus, width-2)
+			for i := range quote {
+				drawText(" ", 0, y+i, ColorBackground, labelColor)
+				drawText(" ", 1, y+i, ColorWhite, cursorColor)
+			}
+			drawQuote(tweet.QuotedStatus, quote, 2, y, bgColor)
+			y += len(quote)
+		}
+
 		// Draw Tweet Detail
 		createdAtTime, err := tweet.CreatedAtTime()
 		if err != nil {
 			continue
 		}
-		sub := now.Sub(createdAtTime)
-		var strTime string
-		if sub <= time.Second*30 {
-			strTime = "now"
-		} else if sub <= time.Minute*5 {
-			strTime = "A few minutes ago"
-		} else if sub <= time.Hour*2 {
-			m := sub / time.Minute
-			strTime = fmt.Sprintf("%d minutes ago", m)
-		} else if sub <= time.Hour*36 {
-			h := sub / time.Hour
-			strTime = fmt.Sprintf("%d hours ago", h)
-		} else if sub <= time.Hour*24*14 {
-			d := (sub / (time.Hour * 24))
-			di := (sub % (time.Hour * 24))
-			// Round up if time of now is later than six o'clock
-			if now.Hour() >= 6 && di > 0 {
-				d++
-			}
-			strTime = fmt.Sprintf("%d/%d/%d %02d:%02d (%d days ago)",
-				createdAtTime.Year(), createdAtTime.Month(),
-				createdAtTime.Day(),
-				createdAtTime.Hour(), createdAtTime.Minute(), d)
-		} else {
-			strTime = fmt.Sprintf("%d/%d/%d %02d:%02d",
-				createdAtTime.Year(), createdAtTime.Month(),
-				createdAtTime.Day(),
-				createdAtTime.Hour(), createdAtTime.Minute())
-		}
+		strTime := settings.TimeFormat.format(createdAtTime, now)
 
 		drawText(" ", 0, y, ColorBackground, labelColor)
 		x = 1
@@ -297,19 +435,66 @@ func (tv *tweetview) resetScroll() {
 
 }
 
+// quoteIndent is the width of "│ " before lines of a quoted tweet
+const quoteIndent = 2
+
+// quoteLines returns lines of the nested block of quoted tweet q, which is
+// drawn in width, the first line is the author
+func quoteLines(q *Tweet, width int) []string {
+	lines := []string{"@" + q.User.ScreenName + " " + q.User.Name}
+	return append(lines, strings.Split(runewidth.Wrap(q.Text, width-quoteIndent), "\n")...)
+}
+
+// drawQuote draws lines made by quoteLines from (x, y)
+func drawQuote(q *Tweet, lines []string, x, y int, bgColor termbox.Attribute) {
+	// The first line is the author
+	offsets := append([]int{0}, lineOffsets(q.Text, lines[1:])...)
+	spans := entitySpans(q)
+	for i, t := range lines {
+		drawText("│", x, y+i, ColorGray1, bgColor)
+		if i == 0 {
+			drawText(t, x+quoteIndent, y+i, generateLabelColorByUserID(q.User.Id), bgColor)
+		} else {
+			drawTextWithEntities(t, offsets[i], spans, x+quoteIndent, y+i, ColorWhite, bgColor)
+		}
+	}
+}
+
+// drawFiltered draws a collapsed tweet in a line
+func (tv *tweetview) drawFiltered(ts tweetstatus, y int, cursorColor termbox.Attribute) {
+	width, _ := getTermSize()
+	tweet := ts.Content
+	if tweet.RetweetedStatus != nil {
+		tweet = tweet.RetweetedStatus
+	}
+	drawText(" ", 0, y, ColorBackground, ColorLowlight)
+	drawText(" ", 1, y, ColorBackground, cursorColor)
+	text := fmt.Sprintf("Filtered tweet of @%s (%s)", tweet.User.ScreenName, ts.Filtered)
+	drawText(runewidth.Truncate(text, width-2, "…"), 2, y, ColorGray1, ColorBackground)
+}
+
 type tweetstatus struct {
-	Content    *anaconda.Tweet
+	Content    *Tweet
 	ReloadMark bool
 	Empty      bool
+	// Filtered is the rule matched to the tweet, drawn as one line in collapse mode
+	// and not drawn in hide mode
+	Filtered string
 
 	pWidth     int
 	cacheCount int
 }
 
+// hidden reports whether the tweet is filtered in hide mode, hidden tweets
+// stay in timelines to be shown again when filters are changed
+func (status *tweetstatus) hidden() bool {
+	return status.Filtered != "" && filters.mode() == filterModeHide
+}
+
 func (status *tweetstatus) countLines() int {
-	if status.Empty {
+	if status.Empty || status.hidden() {
 		return 0
-	} else if status.ReloadMark {
+	} else if status.ReloadMark || status.Filtered != "" {
 		return 1
 	}
 	w, _ := getTermSize()
@@ -323,6 +508,9 @@ func (status *tweetstatus) countLines() int {
 	text := tweet.Text
 	lines := strings.Split(runewidth.Wrap(text, w-2), "\n")
 	lineCount := 1 + len(lines) + 1
+	if tweet.QuotedStatus != nil {
+		lineCount += len(quoteLines(tweet.QuotedStatus, w-2))
+	}
 
 	// Caching
 	status.pWidth = w
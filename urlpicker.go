@@ -0,0 +1,170 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// pickerItem is a link shown in the URL picker
+type pickerItem struct {
+	label string
+	url   string
+}
+
+// urlPicker is a numbered menu of links of a tweet, drawn over the view
+type urlPicker struct {
+	items  []pickerItem
+	cursor int
+}
+
+// tweetLinks returns expanded URLs, media and permalinks of t
+func tweetLinks(t *Tweet) []pickerItem {
+	if t.RetweetedStatus != nil {
+		t = t.RetweetedStatus
+	}
+	items := make([]pickerItem, 0)
+	for _, u := range t.Entities.Urls {
+		if t.QuotedStatus != nil && isQuotedStatusURL(u.Expanded_url, t.QuotedStatusID) {
+			continue
+		}
+		items = append(items, pickerItem{label: u.Display_url, url: u.Expanded_url})
+	}
+	media := tweetMedia(t)
+	for i, m := range media {
+		label := fmt.Sprintf("%s %d/%d", m.Type, i+1, len(media))
+		items = append(items, pickerItem{label: label, url: mediaURL(m)})
+	}
+	if q := t.QuotedStatus; q != nil {
+		items = append(items, pickerItem{label: "quoted tweet of @" + q.User.ScreenName, url: permalink(q)})
+	}
+	return append(items, pickerItem{label: "this tweet", url: permalink(t)})
+}
+
+// mediaURL returns the video of the highest bitrate, or the image
+func mediaURL(m anaconda.EntityMedia) string {
+	url := m.Media_url_https
+	bitrate := -1
+	for _, v := range m.VideoInfo.Variants {
+		if v.ContentType == "video/mp4" && v.Bitrate > bitrate {
+			url, bitrate = v.Url, v.Bitrate
+		}
+	}
+	return url
+}
+
+func newURLPicker(t *Tweet) *urlPicker {
+	return &urlPicker{items: tweetLinks(t)}
+}
+
+func (up *urlPicker) cursorUp() {
+	if up.cursor > 0 {
+		up.cursor--
+	}
+}
+
+func (up *urlPicker) cursorDown() {
+	if up.cursor < len(up.items)-1 {
+		up.cursor++
+	}
+}
+
+// pick returns the item of the number key, or the item under the cursor for 0
+func (up *urlPicker) pick(ch rune) (pickerItem, bool) {
+	if ch == 0 {
+		return up.items[up.cursor], true
+	}
+	i := int(ch - '1')
+	if i < 0 || i >= len(up.items) || i >= 9 {
+		return pickerItem{}, false
+	}
+	return up.items[i], true
+}
+
+// draw shows items above the buffer, only the first 9 items have numbers
+func (up *urlPicker) draw() {
+	width, height := getTermSize()
+	top := height - 3 - len(up.items)
+	if top < 1 {
+		top = 1
+	}
+	fillLine(0, top-1, ColorGray2)
+	drawText("Open URL [1-9/Enter/C-g]", 1, top-1, ColorYellow, ColorGray2)
+	for i, item := range up.items {
+		y := top + i
+		if y >= height-2 {
+			break
+		}
+		bg := ColorBackground
+		if i == up.cursor {
+			bg = ColorLowlight
+		}
+		fillLine(0, y, bg)
+		number := "  "
+		if i < 9 {
+			number = fmt.Sprintf("%d ", i+1)
+		}
+		drawText(number, 1, y, ColorYellow, bg)
+		drawText(item.label, 3, y, ColorWhite, bg)
+		x := 4 + runewidth.StringWidth(item.label)
+		drawText(runewidth.Truncate(item.url, width-x-1, "…"), x, y, ColorURL, bg)
+	}
+}
+
+// openerArgs returns the command to open target, settings.Opener is a template
+// like "firefox --new-tab {}", $BROWSER and OS defaults are used if it's empty
+func openerArgs(opener, target string) ([]string, error) {
+	if opener == "" {
+		// $BROWSER may be a list separated by ":", the first one is used
+		opener = strings.Split(os.Getenv("BROWSER"), ":")[0]
+	}
+	if opener == "" {
+		switch runtime.GOOS {
+		case "darwin":
+			opener = "open"
+		case "windows":
+			opener = "cmd /c start"
+		default:
+			// Linux and BSDs
+			opener = "xdg-open"
+		}
+	}
+	fields := strings.Fields(opener)
+	if len(fields) == 0 {
+		return nil, errors.New("opener is empty")
+	}
+	replaced := false
+	for i, f := range fields {
+		f = os.ExpandEnv(f)
+		if strings.Contains(f, "{}") || strings.Contains(f, "%s") {
+			f = strings.NewReplacer("{}", target, "%s", target).Replace(f)
+			replaced = true
+		}
+		fields[i] = f
+	}
+	if !replaced {
+		fields = append(fields, target)
+	}
+	return fields, nil
+}
@@ -0,0 +1,106 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/ChimeraCoder/anaconda"
+	"strings"
+	"testing"
+)
+
+func TestTweetLinks(t *testing.T) {
+	quoted := newFakeTweet(5, "bob", "quoted")
+	tw := newFakeTweet(10, "alice", "see")
+	tw.QuotedStatus = &quoted
+	tw.QuotedStatusID = quoted.Id
+	for _, u := range []string{"https://example.com/a", "https://twitter.com/bob/status/5"} {
+		tw.Entities.Urls = append(tw.Entities.Urls, struct {
+			Indices      []int
+			Url          string
+			Display_url  string
+			Expanded_url string
+		}{Display_url: strings.TrimPrefix(u, "https://"), Expanded_url: u})
+	}
+	video := anaconda.EntityMedia{Type: "video", Media_url_https: "https://pbs.twimg.com/thumb.jpg"}
+	video.VideoInfo.Variants = []anaconda.Variant{
+		{Bitrate: 832000, ContentType: "video/mp4", Url: "https://video.twimg.com/low.mp4"},
+		{ContentType: "application/x-mpegURL", Url: "https://video.twimg.com/pl.m3u8"},
+		{Bitrate: 2176000, ContentType: "video/mp4", Url: "https://video.twimg.com/high.mp4"},
+	}
+	tw.ExtendedEntities.Media = []anaconda.EntityMedia{video}
+
+	expected := []pickerItem{
+		{"example.com/a", "https://example.com/a"},
+		{"video 1/1", "https://video.twimg.com/high.mp4"},
+		{"quoted tweet of @bob", "https://twitter.com/bob/status/5"},
+		{"this tweet", "https://twitter.com/alice/status/10"},
+	}
+	items := tweetLinks(&tw)
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %v, but %v", expected, items)
+	}
+	for i := range items {
+		if items[i] != expected[i] {
+			t.Fatalf("Expected %v, but %v", expected[i], items[i])
+		}
+	}
+}
+
+func TestURLPickerPick(t *testing.T) {
+	up := &urlPicker{items: []pickerItem{{"a", "A"}, {"b", "B"}, {"c", "C"}}}
+	if item, ok := up.pick('2'); !ok || item.url != "B" {
+		t.Fatalf("Number key must pick the item: %v", item)
+	}
+	if _, ok := up.pick('4'); ok {
+		t.Fatalf("Number out of items must be ignored")
+	}
+	up.cursorDown()
+	up.cursorDown()
+	up.cursorDown()
+	if item, _ := up.pick(0); item.url != "C" {
+		t.Fatalf("Enter must pick the item under the cursor: %v", item)
+	}
+}
+
+func TestOpenerArgs(t *testing.T) {
+	const target = "https://example.com/?q=a b"
+	t.Setenv("BROWSER", "w3m:lynx")
+	t.Setenv("RINGOT_PROFILE", "work")
+	testcase := []struct {
+		opener   string
+		expected []string
+	}{
+		{"firefox -P $RINGOT_PROFILE --new-tab {}", []string{"firefox", "-P", "work", "--new-tab", target}},
+		{"open -a Safari %s", []string{"open", "-a", "Safari", target}},
+		{"chromium", []string{"chromium", target}},
+		{"", []string{"w3m", target}},
+	}
+	for _, c := range testcase {
+		args, err := openerArgs(c.opener, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(args, "|") != strings.Join(c.expected, "|") {
+			t.Fatalf("%q: expected %q, but %q", c.opener, c.expected, args)
+		}
+	}
+	t.Setenv("BROWSER", "")
+	if args, _ := openerArgs("", target); len(args) < 2 || args[len(args)-1] != target {
+		t.Fatalf("OS default must be used: %q", args)
+	}
+}
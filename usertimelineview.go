@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/mattn/go-runewidth"
-	"net/url"
 	"strconv"
 	"strings"
 )
@@ -30,19 +29,21 @@ type usertimelineview struct {
 	*tweetview
 	screenName  string
 	userProfile *anaconda.User
-	cache       map[string][]tweetstatus
+	cache       *timelineCache
+	client      TwitterClient
 
 	loading             lock
-	loadNewTweetCh      chan []anaconda.Tweet
-	loadIntervalTweetCh chan []anaconda.Tweet
+	loadNewTweetCh      chan []Tweet
+	loadIntervalTweetCh chan []Tweet
 }
 
-func newUsertimelineview() *usertimelineview {
+func newUsertimelineview(client TwitterClient) *usertimelineview {
 	return &usertimelineview{
 		tweetview:           newTweetview(),
-		cache:               make(map[string][]tweetstatus),
-		loadNewTweetCh:      make(chan []anaconda.Tweet),
-		loadIntervalTweetCh: make(chan []anaconda.Tweet),
+		cache:               newTimelineCache(),
+		client:              client,
+		loadNewTweetCh:      make(chan []Tweet),
+		loadIntervalTweetCh: make(chan []Tweet),
 	}
 }
 
@@ -50,10 +51,13 @@ func (uv *usertimelineview) setUserScreenName(name string) {
 	name = strings.ToLower(name)
 	if name != uv.screenName {
 		// Store
-		uv.cache[uv.screenName] = uv.tweets
+		uv.cache.store(uv.screenName, uv.tweets)
 
-		if c, ok := uv.cache[name]; ok {
+		if c, ok := uv.cache.take(name); ok {
 			uv.tweets = c
+			// Rules may have been changed while the timeline was cached
+			uv.cursorPosition = 0
+			uv.applyFilter()
 		} else {
 			uv.tweets = []tweetstatus{tweetstatus{ReloadMark: true}}
 		}
@@ -76,24 +80,24 @@ func (uv *usertimelineview) loadTweet(sinceID int64) {
 	changeBufferState("Loading...")
 
 	if _, ok := profilemap.get(uv.screenName); !ok {
-		u, err := api.GetUsersShow(uv.screenName, nil)
+		u, err := uv.client.GetUsersShow(uv.screenName, nil)
 		if err == nil {
 			profilemap.registerProfile(&u)
 		} else {
-			changeBufferState("Err:User profile Loading")
+			notifyError("User profile Loading", err)
 			return
 		}
 	}
 
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(20))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.UserTimeline.New))
 	val.Add("screen_name", uv.screenName)
 	if sinceID > 0 {
 		val.Add("since_id", strconv.FormatInt(sinceID, 10))
 	}
-	timeline, err := api.GetUserTimeline(val)
+	timeline, err := uv.client.GetUserTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -107,15 +111,15 @@ func (uv *usertimelineview) loadIntervalTweet(maxID int64) {
 	uv.loading.lock()
 	defer uv.loading.unlock()
 	changeBufferState("Loading...")
-	val := url.Values{}
-	val.Add("count", strconv.Itoa(CountTweet))
+	val := tweetValues()
+	val.Add("count", strconv.Itoa(settings.FetchCounts.UserTimeline.Older))
 	val.Add("screen_name", uv.screenName)
 	if maxID > 0 {
 		val.Add("max_id", strconv.FormatInt(maxID, 10))
 	}
-	timeline, err := api.GetUserTimeline(val)
+	timeline, err := uv.client.GetUserTimeline(val)
 	if err != nil {
-		changeBufferState("Err:Loading")
+		notifyError("Loading", err)
 		return
 	}
 	changeBufferState(fmt.Sprintf("Load!(%d tweets)", len(timeline)))
@@ -139,21 +143,21 @@ func (uv *usertimelineview) addNewTweet(tss []tweetstatus) {
 	uv.tweetview.addNewTweet(tss)
 }
 
-func (uv *usertimelineview) addIntervalTweet(tss []tweetstatus) {
+func (uv *usertimelineview) addIntervalTweet(tweets []Tweet) {
 	if uv.userProfile == nil {
 		u, ok := profilemap.get(uv.screenName)
 		if ok {
 			uv.userProfile = u
 		}
 	}
-	if len(tss) == 0 {
+	if len(tweets) == 0 {
 		return
 	}
-	s1, s2 := strings.ToLower(tss[0].Content.User.ScreenName), strings.ToLower(uv.screenName)
+	s1, s2 := strings.ToLower(tweets[0].User.ScreenName), strings.ToLower(uv.screenName)
 	if s1 != s2 {
 		return
 	}
-	uv.tweetview.addIntervalTweet(tss)
+	uv.tweetview.addIntervalTweet(tweets)
 }
 
 func (uv *usertimelineview) draw() {
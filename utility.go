@@ -20,22 +20,15 @@ package main
 import (
 	"errors"
 	"fmt"
-	"github.com/ChimeraCoder/anaconda"
 	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
-	"io/ioutil"
 	"math/rand"
-	"net/http"
-	"os"
+	"net/url"
 	"os/exec"
-	"path"
-	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
-	"unicode/utf8"
 )
 
 func byteSliceRemove(bytes []byte, from int, to int) []byte {
@@ -88,64 +81,6 @@ func drawText(str string, x int, y int, fg termbox.Attribute, bg termbox.Attribu
 	}
 }
 
-func drawTextWithAutoNotice(str string, x int, y int, fg termbox.Attribute, bg termbox.Attribute) {
-	pos := 0
-	foreColor := fg
-	backColor := bg
-	fgChanging := false
-	bgChanging := false
-	t := []byte(str)
-	for {
-		if len(t) == 0 {
-			break
-		}
-		c, s := utf8.DecodeRune(t)
-		if !(bgChanging || fgChanging) && len(t) > s {
-			if c == '@' {
-				tc, _ := utf8.DecodeRune(t[s:])
-				if isScreenNameUsable(tc) {
-					backColor = ColorLowlight
-					bgChanging = true
-				}
-			} else {
-				found := false
-				s2 := 0
-				if c == ' ' {
-					var tc rune
-					tc, s2 = utf8.DecodeRune(t[s:])
-					if tc == '#' {
-						found = true
-					}
-				} else if c == '#' && pos == 0 {
-					found = true
-				}
-				if found {
-					tc, _ := utf8.DecodeRune(t[s+s2:])
-					if tc != ' ' {
-						foreColor = ColorBlue
-						fgChanging = true
-					}
-				}
-			}
-		} else {
-			if bgChanging && !isScreenNameUsable(c) {
-				backColor = bg
-				bgChanging = false
-			} else if fgChanging && c == ' ' {
-				tc, _ := utf8.DecodeRune(t[s:])
-				if tc != '#' {
-					foreColor = fg
-					fgChanging = false
-				}
-			}
-		}
-
-		termbox.SetCell(x+pos, y, c, foreColor, backColor)
-		pos += runewidth.RuneWidth(c)
-		t = t[s:]
-	}
-}
-
 func isScreenNameUsable(r rune) bool {
 	if r >= 'a' && r <= 'z' {
 		return true
@@ -168,6 +103,70 @@ func isScreenNameUsableStr(s string) bool {
 	return true
 }
 
+// mentionedScreenNames returns screen names mentioned in text in order,
+// "@" in e-mail addresses is not a mention
+func mentionedScreenNames(text string) []string {
+	names := make([]string, 0)
+	prev := ' '
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '@' && !isScreenNameUsable(prev) {
+			j := i + 1
+			for j < len(runes) && isScreenNameUsable(runes[j]) {
+				j++
+			}
+			if j > i+1 {
+				names = append(names, string(runes[i+1:j]))
+			}
+		}
+		prev = runes[i]
+	}
+	return names
+}
+
+// replyAllScreenNames returns the author, the retweeted author and mentioned users,
+// without duplicates and the user
+func replyAllScreenNames(t *Tweet) []string {
+	candidates := []string{t.User.ScreenName}
+	tweets := []*Tweet{t}
+	if t.RetweetedStatus != nil {
+		candidates = append(candidates, t.RetweetedStatus.User.ScreenName)
+		tweets = append(tweets, t.RetweetedStatus)
+	}
+	for _, tw := range tweets {
+		for _, m := range tw.Entities.User_mentions {
+			candidates = append(candidates, m.Screen_name)
+		}
+	}
+	return uniqueScreenNames(candidates)
+}
+
+func uniqueScreenNames(candidates []string) []string {
+	names := make([]string, 0, len(candidates))
+	found := map[string]bool{strings.ToLower(user.ScreenName): true}
+	for _, sn := range candidates {
+		if key := strings.ToLower(sn); sn != "" && !found[key] {
+			found[key] = true
+			names = append(names, sn)
+		}
+	}
+	return names
+}
+
+// replyConfirmText lists users notified by the reply, ex) "notify @alice @bob?"
+func replyConfirmText(status string) string {
+	names := uniqueScreenNames(mentionedScreenNames(status))
+	if len(names) == 0 {
+		return confirmText
+	}
+	const shown = 5
+	s := "@" + strings.Join(names, " @")
+	if len(names) > shown {
+		s = fmt.Sprintf("@%s +%d", strings.Join(names[:shown], " @"), len(names)-shown)
+	}
+	return "notify " + s + "?[Enter/C-g]"
+}
+
 func fillLine(offset int, y int, bg termbox.Attribute) {
 	width, _ := getTermSize()
 	x := offset
@@ -198,48 +197,111 @@ var (
 		"&gt;", ">")
 )
 
-func wrapTweets(tweets []anaconda.Tweet) []tweetstatus {
-	result := make([]tweetstatus, len(tweets))
-	for i := 0; i < len(tweets); i++ {
-		tweet := &tweets[i]
-		for {
-			if tweet.RetweetedStatus != nil {
-				tweet = tweet.RetweetedStatus
-			} else {
-				break
-			}
-		}
-		tweet.Text = replacer.Replace(tweet.Text)
-		for _, url := range tweet.Entities.Urls {
-			tweet.Text = strings.Replace(tweet.Text, url.Url, url.Display_url, -1)
-		}
-		for _, media := range tweet.ExtendedEntities.Media {
-			tweet.Text = strings.Replace(tweet.Text, media.Url, media.Display_url, -1)
-		}
-		result[i] = tweetstatus{Content: &tweets[i]}
+// tweetValues returns parameters to fetch tweets with full_text
+func tweetValues() url.Values {
+	val := url.Values{}
+	val.Add("tweet_mode", "extended")
+	return val
+}
+
+// fullText returns the text of t fetched in extended mode, or in compatibility mode
+func fullText(t *Tweet) string {
+	if t.ExtendedTweet != nil && t.ExtendedTweet.FullText != "" {
+		return t.ExtendedTweet.FullText
+	} else if t.FullText != "" {
+		return t.FullText
 	}
-	return result
+	return t.Text
+}
+
+// expandText moves the full text of t and its entities to Text and Entities
+func expandText(t *Tweet) {
+	t.Text = fullText(t)
+	if t.ExtendedTweet != nil {
+		t.Entities = t.ExtendedTweet.Entities
+		t.ExtendedEntities = t.ExtendedTweet.ExtendedEntities
+		t.ExtendedTweet = nil
+	}
+	t.FullText = ""
 }
 
-func wrapTweet(t *anaconda.Tweet) tweetstatus {
+// formatText unescapes the text of t, or its retweeted status, and replaces
+// URLs with display URLs. The link to a quoted tweet is removed, because the
+// quoted tweet is drawn below the text. Indices of entities are moved
+// to point the rewritten text
+func formatText(t *Tweet) {
 	tweet := t
-	for {
-		if tweet.RetweetedStatus != nil {
-			tweet = tweet.RetweetedStatus
-		} else {
-			break
-		}
+	expandText(tweet)
+	for tweet.RetweetedStatus != nil {
+		tweet = tweet.RetweetedStatus
+		expandText(tweet)
 	}
+	// Indices of entities are offsets in the unescaped text
 	tweet.Text = replacer.Replace(tweet.Text)
-	for _, url := range tweet.Entities.Urls {
-		tweet.Text = strings.Replace(tweet.Text, url.Url, url.Display_url, -1)
+	edits := make([]textEdit, 0, len(tweet.Entities.Urls)+1)
+	for i, u := range tweet.Entities.Urls {
+		e := textEdit{from: u.Url, to: u.Display_url, targets: []*[]int{&tweet.Entities.Urls[i].Indices}}
+		if tweet.QuotedStatus != nil && isQuotedStatusURL(u.Expanded_url, tweet.QuotedStatusID) {
+			e.to = ""
+		}
+		edits = append(edits, e)
 	}
-	for _, media := range tweet.ExtendedEntities.Media {
-		tweet.Text = strings.Replace(tweet.Text, media.Url, media.Display_url, -1)
+	// All media of a tweet share a URL
+	var media *textEdit
+	for _, e := range []*Entities{&tweet.Entities, &tweet.ExtendedEntities} {
+		for i := range e.Media {
+			if media == nil {
+				media = &textEdit{from: e.Media[i].Url, to: e.Media[i].Display_url}
+			}
+			media.targets = append(media.targets, &e.Media[i].Indices)
+		}
+	}
+	if media != nil {
+		edits = append(edits, *media)
+	}
+	tweet.Text = rewriteText(tweet.Text, edits, entityIndices(tweet))
+	if tweet.QuotedStatus != nil {
+		formatText(tweet.QuotedStatus)
+	}
+}
+
+// isQuotedStatusURL reports whether url is the permalink of the tweet of id,
+// ex) https://twitter.com/alice/status/12345
+func isQuotedStatusURL(url string, id int64) bool {
+	return strings.HasSuffix(url, "/status/"+strconv.FormatInt(id, 10))
+}
+
+// permalink is the URL of t, used to quote it
+func permalink(t *Tweet) string {
+	return fmt.Sprintf("https://twitter.com/%s/status/%d", t.User.ScreenName, t.Id)
+}
+
+// wrapTweets makes tweets ready to draw, tweets matched to filters are marked
+// to be hidden or collapsed by the mode
+func wrapTweets(tweets []Tweet) []tweetstatus {
+	result := make([]tweetstatus, 0, len(tweets))
+	for i := 0; i < len(tweets); i++ {
+		formatText(&tweets[i])
+		result = append(result, tweetstatus{Content: &tweets[i], Filtered: filters.match(&tweets[i])})
 	}
+	return result
+}
+
+func wrapTweet(t *Tweet) tweetstatus {
+	formatText(t)
 	return tweetstatus{Content: t}
 }
 
+func formatBytes(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
 func sumTweetLines(tweetsStatusSlice []tweetstatus) int {
 	sum := 0
 	tweets := tweetsStatusSlice
@@ -249,144 +311,53 @@ func sumTweetLines(tweetsStatusSlice []tweetstatus) int {
 	return sum
 }
 
+// openCommand opens path with the opener of settings
 func openCommand(path string) {
-	var commandName string
-	switch runtime.GOOS {
-	case "linux":
-		commandName = "xdg-open"
-	case "darwin":
-		commandName = "open"
-	default:
+	args, err := openerArgs(settings.Opener, path)
+	if err != nil {
+		notifyError("Open", err)
 		return
-
 	}
-	exec.Command(commandName, path).Run()
-}
-
-const (
-	tempDir = "ringot"
-)
-
-func downloadMedia(url string, filefullpath string, wg *sync.WaitGroup, mes chan int) {
-	defer func() {
-		if err := recover(); err != nil {
-			mes <- -1
-		} else {
-			mes <- 1
-		}
-		wg.Done()
-	}()
-	if _, err := os.Stat(filefullpath); err == nil {
-		return
+	if err := exec.Command(args[0], args[1:]...).Run(); err != nil {
+		notifyError("Open "+path, err)
 	}
+}
 
-	res, err := http.Get(url)
-	if err != nil {
-		panic(err)
-	}
-	if res.StatusCode != 200 {
-		panic(errors.New(res.Status))
-	}
-	body, err := ioutil.ReadAll(res.Body)
+func favoriteTweet(client TwitterClient, id int64) error {
+	_, err := client.Favorite(id)
 	if err != nil {
-		panic(err)
-	}
-	tempdir := filepath.Join(os.TempDir(), tempDir)
-	if _, err := os.Stat(tempdir); err != nil {
-		err := os.Mkdir(tempdir, 0775)
-		if err != nil {
-			panic(err)
-		}
-	}
-	file, err := os.OpenFile(filefullpath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0664)
-	if err != nil {
-		panic(err)
-	}
-	defer file.Close()
-	file.Write(body)
-}
-
-func openMedia(urls []string) {
-	wg := new(sync.WaitGroup)
-	mes := make(chan int, len(urls))
-	fileps := make([]string, 0)
-	changeBufferState(fmt.Sprintf("Downloading...(0/%d)", len(urls)))
-	for _, url := range urls {
-		_, filename := path.Split(url)
-		filefullpath := filepath.Join(os.TempDir(), tempDir, filename)
-		fileps = append(fileps, filefullpath)
-		wg.Add(1)
-		go downloadMedia(url, filefullpath, wg, mes)
-	}
-	receiveErrors := 0
-	go func() {
-		count := 0
-		for {
-			rec := <-mes
-			if rec == -1 {
-				receiveErrors++
-				continue
-			}
-			count++
-			changeBufferState(fmt.Sprintf("Downloading...(%d/%d)", count, len(urls)))
-			if count == len(urls) {
-				break
-			}
-		}
-	}()
-	wg.Wait()
-	// Reverse
-	for i := len(fileps) - 1; i >= 0; i-- {
-		if _, err := os.Stat(fileps[i]); err == nil {
-			openCommand(fileps[i])
-		}
-		time.Sleep(time.Millisecond)
-	}
-
-	if receiveErrors > 0 {
-		if receiveErrors == 1 {
-			changeBufferState("Err:media downloading was failed")
-		} else if receiveErrors == len(urls) {
-			changeBufferState("Err:all of media downloading were failed")
-		} else {
-			changeBufferState("Err:some of media downloading were failed")
-		}
-	} else {
-		changeBufferState("")
+		notifyError("Favorite", err)
 	}
+	return err
 }
 
-func favoriteTweet(id int64) {
-	_, err := api.Favorite(id)
+func unfavoriteTweet(client TwitterClient, id int64) error {
+	_, err := client.Unfavorite(id)
 	if err != nil {
-		changeBufferState("Err:Favorite")
-		return
+		notifyError("Unfavorite", err)
 	}
+	return err
 }
 
-func unfavoriteTweet(id int64) {
-	_, err := api.Unfavorite(id)
+func retweet(client TwitterClient, id int64) error {
+	_, err := client.Retweet(id, false)
 	if err != nil {
-		changeBufferState("Err:Unfavorite")
-		return
+		notifyError("Retweet", err)
 	}
+	return err
 }
 
-func retweet(id int64) {
-	_, err := api.Retweet(id, false)
+func unretweet(client TwitterClient, id int64) error {
+	_, err := client.UnRetweet(id, false)
 	if err != nil {
-		changeBufferState("Err:Retweet")
-		return
+		notifyError("Undo Retweet", err)
 	}
+	return err
 }
 
+// changeBufferState shows an informative message in the state line
 func changeBufferState(state string) {
-	go func() {
-		stateCh <- state
-		if state != "" {
-			stateClearCh <- 30
-		}
-	}()
+	notify(notification{Severity: severityInfo, Message: state})
 }
 
 func getTermSize() (int, int) {
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"github.com/ChimeraCoder/anaconda"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -108,3 +110,130 @@ func TestGenerateLabelColorByUserID(t *testing.T) {
 		}
 	}
 }
+
+func TestWrapQuotedTweet(t *testing.T) {
+	initialize()
+	quoted := newFakeTweet(5, "bob", "quoted &amp; wrapped")
+	tweet := newFakeTweet(6, "alice", "Look at this https://t.co/abc")
+	tweet.QuotedStatusID = 5
+	tweet.QuotedStatus = &quoted
+	tweet.Entities.Urls = append(tweet.Entities.Urls, struct {
+		Indices      []int
+		Url          string
+		Display_url  string
+		Expanded_url string
+	}{Url: "https://t.co/abc", Display_url: "twitter.com/bob/status/5",
+		Expanded_url: permalink(&quoted)})
+
+	ts := wrapTweet(&tweet)
+	if tweet.Text != "Look at this" {
+		t.Fatalf("Link to the quoted tweet must be removed: %q", tweet.Text)
+	}
+	if quoted.Text != "quoted & wrapped" {
+		t.Fatalf("Quoted tweet must be formatted: %q", quoted.Text)
+	}
+	// Name, a line of text, the quoted block of 2 lines and time
+	if n := ts.countLines(); n != 5 {
+		t.Fatalf("Expected 5 lines, but %d", n)
+	}
+	if !isQuotedStatusURL("https://twitter.com/bob/status/5", 5) || isQuotedStatusURL("https://twitter.com/bob/status/55", 5) {
+		t.Fatalf("Unexpected matching of permalinks")
+	}
+}
+
+func TestReplyAllScreenNames(t *testing.T) {
+	user = UserConfig{ID: 1, ScreenName: "me"}
+	original := newFakeTweet(7, "carol", "@Me @dave hi, mail me@example.com")
+	for _, sn := range []string{"Me", "dave"} {
+		original.Entities.User_mentions = append(original.Entities.User_mentions, struct {
+			Name        string
+			Indices     []int
+			Screen_name string
+			Id          int64
+			Id_str      string
+		}{Screen_name: sn})
+	}
+	retweet := newFakeTweet(8, "bob", "RT @carol: @Me @dave hi")
+	retweet.RetweetedStatus = &original
+	retweet.Entities.User_mentions = append(retweet.Entities.User_mentions, original.Entities.User_mentions[0])
+	retweet.Entities.User_mentions[0].Screen_name = "Carol"
+
+	names := replyAllScreenNames(&retweet)
+	if !reflect.DeepEqual(names, []string{"bob", "carol", "dave"}) {
+		t.Fatalf("Unexpected screen names: %v", names)
+	}
+	if names := mentionedScreenNames(original.Text); !reflect.DeepEqual(names, []string{"Me", "dave"}) {
+		t.Fatalf("Address must not be a mention: %v", names)
+	}
+
+	testcase := []struct {
+		status, expected string
+	}{
+		{"@bob @carol @bob hi", "notify @bob @carol?[Enter/C-g]"},
+		{"@me hi", confirmText},
+		{"@a @b @c @d @e @f @g", "notify @a @b @c @d @e +2?[Enter/C-g]"},
+	}
+	for _, c := range testcase {
+		if s := replyConfirmText(c.status); s != c.expected {
+			t.Fatalf("%q: Expected %q, but %q", c.status, c.expected, s)
+		}
+	}
+}
+
+func TestFormatExtendedTweet(t *testing.T) {
+	tweet := newFakeTweet(9, "alice", "")
+	tweet.FullText = "@bob Q&amp;A https://t.co/x1 #golang https://t.co/m1"
+	entities := &tweet.Entities
+	entities.User_mentions = append(entities.User_mentions, struct {
+		Name        string
+		Indices     []int
+		Screen_name string
+		Id          int64
+		Id_str      string
+	}{Indices: []int{0, 4}, Screen_name: "bob"})
+	entities.Urls = append(entities.Urls, struct {
+		Indices      []int
+		Url          string
+		Display_url  string
+		Expanded_url string
+	}{Indices: []int{9, 24}, Url: "https://t.co/x1", Display_url: "example.com/long/path…"})
+	entities.Hashtags = append(entities.Hashtags, struct {
+		Indices []int
+		Text    string
+	}{Indices: []int{25, 32}, Text: "golang"})
+	media := anaconda.EntityMedia{Indices: []int{33, 48}, Url: "https://t.co/m1", Display_url: "pic.twitter.com/abc"}
+	entities.Media = append(entities.Media, media)
+	tweet.ExtendedEntities.Media = append(tweet.ExtendedEntities.Media, media, media)
+
+	wrapTweet(&tweet)
+	expected := "@bob Q&A example.com/long/path… #golang pic.twitter.com/abc"
+	if tweet.Text != expected || tweet.FullText != "" {
+		t.Fatalf("Expected %q, but %q", expected, tweet.Text)
+	}
+	runes := []rune(tweet.Text)
+	entity := func(idx []int) string { return string(runes[idx[0]:idx[1]]) }
+	if s := entity(entities.User_mentions[0].Indices); s != "@bob" {
+		t.Fatalf("Mention must not move: %q", s)
+	}
+	if s := entity(entities.Urls[0].Indices); s != "example.com/long/path…" {
+		t.Fatalf("URL must point the display URL: %q", s)
+	}
+	if s := entity(entities.Hashtags[0].Indices); s != "#golang" {
+		t.Fatalf("Hashtag must be moved: %q", s)
+	}
+	for _, m := range tweet.ExtendedEntities.Media {
+		if s := entity(m.Indices); s != "pic.twitter.com/abc" {
+			t.Fatalf("Media must be moved: %q", s)
+		}
+	}
+
+	// Tweets over 140 characters in compatibility mode
+	compat := newFakeTweet(10, "bob", "truncated… https://t.co/more")
+	compat.ExtendedTweet = &ExtendedTweet{FullText: strings.Repeat("long ", 50) + "#end"}
+	compat.ExtendedTweet.Entities.Hashtags = entities.Hashtags[:1]
+	compat.ExtendedTweet.Entities.Hashtags[0].Indices = []int{250, 254}
+	wrapTweet(&compat)
+	if tweet := []rune(compat.Text); len(tweet) != 254 || string(tweet[250:]) != "#end" || compat.ExtendedTweet != nil {
+		t.Fatalf("Full text must be used: %q", compat.Text)
+	}
+}
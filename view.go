@@ -18,10 +18,17 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
 	"net/url"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -32,24 +39,55 @@ type view struct {
 	usertimelineview *usertimelineview
 	favoriteview     *favoriteview
 	listview         *listview
+	searchview       *searchview
+	dmview           *dmview
+	pagerview        *pagerview
 	buffer           *buffer
+	client           TwitterClient
+	store            *tweetStore
+	scheduler        *scheduler
+	keymap           *keymap
+	keys             keySequence
+	keyTimeoutCh     chan int
+	// filterCh is notified when the server mute list is changed
+	filterCh chan struct{}
+	// updateCh runs changes of tweets requested by goroutines in Loop
+	updateCh    chan func()
+	vi          viState
+	searchQuery string
+	// thread is the rest of the thread which failed to be posted
+	thread *threadDraft
+	// picker is the URL picker drawn over the view, nil if it is closed
+	picker *urlPicker
+	// preview shows images over the timeline, nil if it is closed
+	preview *imagePreview
 
 	modeHistory []viewmode
 	quit        bool
 }
 
-func newView() *view {
+func newView(client TwitterClient, store *tweetStore) *view {
 	view := &view{
+		client:      client,
+		store:       store,
 		modeHistory: []viewmode{home},
 		quit:        false,
 	}
-	view.timelineview = newTimelineview()
-	view.mentionview = newMentionview()
-	view.conversationview = newConversationview()
-	view.usertimelineview = newUsertimelineview()
-	view.favoriteview = newFavoriteview()
-	view.listview = newListview()
+	view.timelineview = newTimelineview(client)
+	view.mentionview = newMentionview(client)
+	view.conversationview = newConversationview(client)
+	view.usertimelineview = newUsertimelineview(client)
+	view.favoriteview = newFavoriteview(client)
+	view.listview = newListview(client)
+	view.searchview = newSearchview(client)
+	view.dmview = newDMview(client)
+	view.pagerview = newPagerview()
 	view.buffer = newBuffer()
+	view.scheduler = newScheduler()
+	view.keymap = newDefaultKeymap()
+	view.keyTimeoutCh = make(chan int)
+	view.filterCh = make(chan struct{})
+	view.updateCh = make(chan func())
 	return view
 }
 
@@ -64,19 +102,154 @@ const (
 	conversation
 	list
 	favorite
+	search
+	directmessage
+	pager
 )
 
 func (view *view) Init() {
-	view.initHomeTimeline()
-	view.initMention()
+	tweetmap.setPinned(view.pinnedTweetIDs)
+	if view.store != nil {
+		if ss, err := view.store.loadSearches(); err == nil {
+			view.searchview.searches = ss
+		} else {
+			notifyError("Loading saved searches", err)
+		}
+		if fs, err := view.store.loadFilters(); err == nil {
+			filters = fs
+		} else {
+			notifyError("Loading filters", err)
+		}
+	}
+	go view.syncMutes()
+	if view.restoreCache() {
+		// Show the cached timeline right away, and fill in only what is newer
+		go view.timelineview.loadTweet(view.timelineview.newestID())
+		go view.mentionview.loadTweet(view.mentionview.newestID())
+	} else {
+		view.initHomeTimeline()
+		view.initMention()
+	}
+	go view.dmview.loadMessages(0, 0)
 	view.turnHomeTimelineMode()
 	view.refreshAll()
 }
 
+func (view *view) restoreCache() bool {
+	if view.store == nil {
+		return false
+	}
+	timelines, profiles, lists, err := view.store.load()
+	if err != nil {
+		return false
+	}
+	for i := range profiles {
+		profilemap.registerProfile(&profiles[i])
+	}
+	for _, l := range lists {
+		view.listview.lists[l.Id] = l
+	}
+	register := func(tss []tweetstatus) {
+		for _, ts := range tss {
+			if ts.Content != nil {
+				tweetmap.registerTweet(ts.Content)
+			}
+		}
+	}
+	// Other timelines are cached, and filtered when they are shown
+	keys := make([]string, 0, len(timelines))
+	for key := range timelines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var cache *timelineCache
+		switch {
+		case strings.HasPrefix(key, cacheKeyList):
+			cache = view.listview.cache
+		case strings.HasPrefix(key, cacheKeySearch):
+			cache = view.searchview.cache
+		case strings.HasPrefix(key, cacheKeyUser):
+			cache = view.usertimelineview.cache
+		case strings.HasPrefix(key, cacheKeyFavorite):
+			cache = view.favoriteview.cache
+		default:
+			continue
+		}
+		register(timelines[key])
+		cache.store(key[strings.Index(key, ":")+1:], timelines[key])
+	}
+	restore := func(tv *tweetview, tss []tweetstatus) bool {
+		register(tss)
+		tv.tweets = tss
+		tv.applyFilter()
+		if tv.isEmpty() {
+			tv.tweets = []tweetstatus{tweetstatus{ReloadMark: true}}
+			return false
+		}
+		return true
+	}
+	restored := restore(view.timelineview.tweetview, timelines["home"])
+	restore(view.mentionview.tweetview, timelines["mention"])
+	return restored
+}
+
+// Prefixes of keys of saved timelines, followed by a list ID, a query or a screen name
+const (
+	cacheKeyList     = "list:"
+	cacheKeySearch   = "search:"
+	cacheKeyUser     = "user:"
+	cacheKeyFavorite = "favorite:"
+)
+
+// cacheSaveInterval is how often timelines are saved while running,
+// so that they are not lost if ringot is killed
+const cacheSaveInterval = time.Minute
+
+func (view *view) saveCache() {
+	if view.store == nil {
+		return
+	}
+	timelines := map[string][]tweetstatus{
+		"home":    view.timelineview.tweets,
+		"mention": view.mentionview.tweets,
+	}
+	// add saves cached timelines and the one shown now
+	add := func(prefix string, cache *timelineCache, current string, tv *tweetview) {
+		for key, tss := range cache.timelines {
+			timelines[prefix+key] = tss
+		}
+		if current != "" && !tv.isEmpty() {
+			timelines[prefix+current] = tv.tweets
+		}
+	}
+	lv := view.listview
+	var current string
+	if lv.list.Id != 0 {
+		current = strconv.FormatInt(lv.list.Id, 10)
+	}
+	add(cacheKeyList, lv.cache, current, lv.tweetview)
+	lists := make([]anaconda.List, 0, lv.cache.len()+1)
+	for key := range lv.cache.timelines {
+		id, _ := strconv.ParseInt(key, 10, 64)
+		lists = append(lists, lv.lists[id])
+	}
+	if current != "" {
+		lists = append(lists, lv.list)
+	}
+	add(cacheKeySearch, view.searchview.cache, view.searchview.query, view.searchview.tweetview)
+	uv, fv := view.usertimelineview, view.favoriteview
+	add(cacheKeyUser, uv.cache, uv.screenName, uv.tweetview)
+	add(cacheKeyFavorite, fv.cache, fv.screenName, fv.tweetview)
+	if err := view.store.save(timelines, profilemap.profiles(), lists); err != nil {
+		notifyError("Saving cache", err)
+	}
+}
+
 func (view *view) initHomeTimeline() {
-	ht, err := api.GetHomeTimeline(nil)
+	ht, err := view.client.GetHomeTimeline(tweetValues())
 	if err != nil {
-		changeBufferState("Can't initialize Home Timeline")
+		notifyError("Initialize Home Timeline", err)
 		return
 	}
 	tweetmap.registerTweets(ht)
@@ -85,7 +258,7 @@ func (view *view) initHomeTimeline() {
 }
 
 func (view *view) initMention() {
-	mt, err := api.GetMentionsTimeline(nil)
+	mt, err := view.client.GetMentionsTimeline(tweetValues())
 	if err != nil {
 		return
 	}
@@ -94,6 +267,63 @@ func (view *view) initMention() {
 	view.mentionview.addNewTweet(t)
 }
 
+// currentTweetview returns tweetview of the current view, nil if it has none
+func (view *view) currentTweetview() *tweetview {
+	switch view.getCurrentViewMode() {
+	case home:
+		return view.timelineview.tweetview
+	case mention:
+		return view.mentionview.tweetview
+	case conversation:
+		return view.conversationview.tweetview
+	case usertimeline:
+		return view.usertimelineview.tweetview
+	case favorite:
+		return view.favoriteview.tweetview
+	case list:
+		return view.listview.tweetview
+	case search:
+		return view.searchview.tweetview
+	}
+	return nil
+}
+
+func (view *view) tweetviews() []*tweetview {
+	return []*tweetview{
+		view.timelineview.tweetview,
+		view.mentionview.tweetview,
+		view.conversationview.tweetview,
+		view.usertimelineview.tweetview,
+		view.favoriteview.tweetview,
+		view.listview.tweetview,
+		view.searchview.tweetview,
+	}
+}
+
+// timelineCaches returns caches of timelines not shown now
+func (view *view) timelineCaches() []*timelineCache {
+	return []*timelineCache{
+		view.usertimelineview.cache,
+		view.favoriteview.cache,
+		view.listview.cache,
+		view.searchview.cache,
+	}
+}
+
+// pinnedTweetIDs returns IDs of tweets which views still reference,
+// TweetMap never evicts them
+func (view *view) pinnedTweetIDs() map[int64]bool {
+	pinned := make(map[int64]bool, 1000)
+	for _, tv := range view.tweetviews() {
+		for _, ts := range tv.tweets {
+			for t := ts.Content; t != nil; t = t.RetweetedStatus {
+				pinned[t.Id] = true
+			}
+		}
+	}
+	return pinned
+}
+
 func (view *view) Loop() {
 	evCh := make(chan termbox.Event)
 	go func() {
@@ -101,6 +331,13 @@ func (view *view) Loop() {
 			evCh <- termbox.PollEvent()
 		}
 	}()
+	view.scheduler.start()
+	saveTicker := time.NewTicker(cacheSaveInterval)
+	defer saveTicker.Stop()
+	// quit gracefully to save the cache when the terminal is closed or ringot is killed
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signalCh)
 	go func() {
 		ticker := time.NewTicker(time.Second)
 		counter := 0
@@ -110,7 +347,7 @@ func (view *view) Loop() {
 				if counter > 0 {
 					counter--
 					if counter == 0 {
-						stateCh <- ""
+						stateCh <- notification{}
 					}
 				}
 			case v := <-stateClearCh:
@@ -131,19 +368,27 @@ func (view *view) Loop() {
 			}
 		case tw := <-view.timelineview.loadNewTweetCh:
 			tweetmap.registerTweets(tw)
-			view.timelineview.addNewTweet(wrapTweets(tw))
+			if len(tw) >= settings.FetchCounts.Home.New {
+				view.timelineview.addNewTweetWithGap(wrapTweets(tw))
+			} else {
+				view.timelineview.addNewTweet(wrapTweets(tw))
+			}
 			view.refreshAll()
 		case tw := <-view.timelineview.loadIntervalTweetCh:
 			tweetmap.registerTweets(tw)
-			view.timelineview.addIntervalTweet(wrapTweets(tw))
+			view.timelineview.addIntervalTweet(tw)
 			view.refreshAll()
 		case tw := <-view.mentionview.loadNewTweetCh:
 			tweetmap.registerTweets(tw)
-			view.mentionview.addNewTweet(wrapTweets(tw))
+			if len(tw) >= settings.FetchCounts.Mention.New {
+				view.mentionview.addNewTweetWithGap(wrapTweets(tw))
+			} else {
+				view.mentionview.addNewTweet(wrapTweets(tw))
+			}
 			view.refreshAll()
 		case tw := <-view.mentionview.loadIntervalTweetCh:
 			tweetmap.registerTweets(tw)
-			view.mentionview.addIntervalTweet(wrapTweets(tw))
+			view.mentionview.addIntervalTweet(tw)
 			view.refreshAll()
 		case tw := <-view.conversationview.loadPreviousTweetCh:
 			tweetmap.registerTweet(tw)
@@ -156,7 +401,7 @@ func (view *view) Loop() {
 			view.refreshAll()
 		case tw := <-view.usertimelineview.loadIntervalTweetCh:
 			tweetmap.registerTweets(tw)
-			view.usertimelineview.addIntervalTweet(wrapTweets(tw))
+			view.usertimelineview.addIntervalTweet(tw)
 			view.refreshAll()
 		case tw := <-view.favoriteview.loadNewTweetCh:
 			tweetmap.registerTweets(tw)
@@ -165,7 +410,7 @@ func (view *view) Loop() {
 			view.refreshAll()
 		case tw := <-view.favoriteview.loadIntervalTweetCh:
 			tweetmap.registerTweets(tw)
-			view.favoriteview.addIntervalTweet(wrapTweets(tw))
+			view.favoriteview.addIntervalTweet(tw)
 			view.refreshAll()
 		case tw := <-view.listview.loadNewTweetCh:
 			tweetmap.registerTweets(tw)
@@ -174,19 +419,80 @@ func (view *view) Loop() {
 			view.refreshAll()
 		case tw := <-view.listview.loadIntervalTweetCh:
 			tweetmap.registerTweets(tw)
-			view.listview.addIntervalTweet(wrapTweets(tw))
+			view.listview.addIntervalTweet(tw)
+			view.refreshAll()
+		case tw := <-view.searchview.loadNewTweetCh:
+			tweetmap.registerTweets(tw)
+			if len(tw) >= settings.FetchCounts.Search.New {
+				view.searchview.addNewTweetWithGap(wrapTweets(tw))
+			} else {
+				view.searchview.addNewTweet(wrapTweets(tw))
+			}
+			view.refreshAll()
+		case tw := <-view.searchview.loadIntervalTweetCh:
+			tweetmap.registerTweets(tw)
+			view.searchview.addIntervalTweet(tw)
+			view.refreshAll()
+		case l := <-view.dmview.loadCh:
+			if senders := view.dmview.addMessages(l); len(senders) > 0 {
+				notify(notification{Action: "New Direct Messages", Logged: true,
+					Message: "from @" + strings.Join(senders, ", @")})
+			}
 			view.refreshAll()
+		case update := <-view.updateCh:
+			update()
+			view.refreshAll()
+		case <-view.filterCh:
+			view.applyFilters()
+			view.refreshAll()
+		case req := <-view.scheduler.requestCh:
+			view.poll(req)
+		case generation := <-view.keyTimeoutCh:
+			if generation == view.keys.generation && len(view.keys.pending) > 0 {
+				view.keys.clear()
+				view.showPendingKeys()
+			}
 		case state := <-stateCh:
 			if !view.buffer.inputing {
 				view.buffer.setState(state)
 				view.refreshBuffer()
 			}
+		case <-saveTicker.C:
+			view.saveCache()
+		case <-signalCh:
+			view.quit = true
 		}
 		if view.quit {
 			break
 		}
 	}
+	view.saveCache()
+}
 
+// poll loads tweets newer than the newest one of the target timeline
+func (view *view) poll(req pollRequest) {
+	switch req.target {
+	case pollHome:
+		sinceID := view.timelineview.newestID()
+		go func() { req.result <- view.timelineview.loadTweet(sinceID) }()
+	case pollMention:
+		sinceID := view.mentionview.newestID()
+		go func() { req.result <- view.mentionview.loadTweet(sinceID) }()
+	case pollList:
+		list := view.listview.list
+		if list.Id == 0 && list.Slug == "" {
+			// No list is opened
+			req.result <- nil
+			return
+		}
+		sinceID := view.listview.newestID()
+		go func() { req.result <- view.listview.loadTweet(sinceID) }()
+	case pollDM:
+		receivedSinceID, sentSinceID := view.dmview.newestReceivedID, view.dmview.newestSentID
+		go func() { req.result <- view.dmview.loadMessages(receivedSinceID, sentSinceID) }()
+	default:
+		req.result <- nil
+	}
 }
 
 func (view *view) refreshAll() {
@@ -195,27 +501,78 @@ func (view *view) refreshAll() {
 	switch view.getCurrentViewMode() {
 	case home:
 		view.buffer.linePosInfo = view.timelineview.cursorPosition + 1
+		view.buffer.unreadInfo = view.timelineview.unread
 		view.timelineview.draw()
 	case mention:
 		view.buffer.linePosInfo = view.mentionview.cursorPosition + 1
+		view.buffer.unreadInfo = view.mentionview.unread
 		view.mentionview.draw()
 	case conversation:
 		view.buffer.linePosInfo = view.conversationview.cursorPosition + 1
+		view.buffer.unreadInfo = 0
 		view.conversationview.draw()
 	case usertimeline:
 		view.buffer.linePosInfo = view.usertimelineview.cursorPosition + 1
+		view.buffer.unreadInfo = 0
 		view.usertimelineview.draw()
 	case favorite:
 		view.buffer.linePosInfo = view.favoriteview.cursorPosition + 1
+		view.buffer.unreadInfo = 0
 		view.favoriteview.draw()
 	case list:
 		view.buffer.linePosInfo = view.listview.cursorPosition + 1
+		view.buffer.unreadInfo = view.listview.unread
 		view.listview.draw()
+	case search:
+		view.buffer.linePosInfo = view.searchview.cursorPosition + 1
+		view.buffer.unreadInfo = view.searchview.unread
+		view.searchview.draw()
+	case directmessage:
+		view.buffer.linePosInfo = view.dmview.cursorPosition + 1
+		view.buffer.unreadInfo = 0
+		view.dmview.draw()
+	case pager:
+		view.buffer.linePosInfo = view.pagerview.scroll + 1
+		view.buffer.unreadInfo = 0
+		view.pagerview.draw()
+	}
+	if view.preview != nil {
+		view.preview.draw()
+	}
+	view.buffer.rateInfo = ""
+	if limit, ok := view.client.RateLimit(view.rateLimitFamily()); ok {
+		view.buffer.rateInfo = formatRateLimit(limit)
 	}
 	view.buffer.draw()
+	if view.picker != nil {
+		view.picker.draw()
+	}
 	termbox.Flush()
 }
 
+// rateLimitFamily returns the endpoint family used to load the current view
+func (view *view) rateLimitFamily() string {
+	switch view.getCurrentViewMode() {
+	case home:
+		return familyHomeTimeline
+	case mention:
+		return familyMentionsTimeline
+	case conversation:
+		return familyShowTweet
+	case usertimeline:
+		return familyUserTimeline
+	case favorite:
+		return familyFavorites
+	case list:
+		return familyListStatuses
+	case search:
+		return familySearch
+	case directmessage:
+		return familyDirectMessages
+	}
+	return ""
+}
+
 func (view *view) refreshBuffer() {
 	view.buffer.draw()
 	termbox.Flush()
@@ -228,9 +585,24 @@ func (view *view) resetScrollAll() {
 	view.usertimelineview.resetScroll()
 	view.favoriteview.resetScroll()
 	view.listview.resetScroll()
+	view.searchview.resetScroll()
+	view.dmview.resetScroll()
+	view.pagerview.resetScroll()
 }
 
 func (view *view) handleEvent(ev termbox.Event) {
+	vi := view.keymap.preset == viPreset && !view.buffer.inputing &&
+		view.getCurrentViewMode() != pager && view.picker == nil && view.preview == nil
+	if vi && len(view.keys.pending) == 0 && view.vi.feed(ev) {
+		view.showPendingKeys()
+		return
+	}
+	if !view.feedKey(ev) {
+		return
+	}
+	if vi {
+		defer view.vi.reset()
+	}
 	if view.buffer.inputing {
 		if view.buffer.confirm {
 			view.handleConfirmMode(ev)
@@ -239,6 +611,14 @@ func (view *view) handleEvent(ev termbox.Event) {
 		}
 		return
 	}
+	if view.picker != nil {
+		view.handlePickerMode(ev)
+		return
+	}
+	if view.preview != nil {
+		view.handlePreviewMode(ev)
+		return
+	}
 	switch view.getCurrentViewMode() {
 	case home:
 		view.handleHometimelineMode(ev)
@@ -252,37 +632,169 @@ func (view *view) handleEvent(ev termbox.Event) {
 		view.handleFavoriteMode(ev)
 	case list:
 		view.handleListMode(ev)
+	case search:
+		view.handleSearchMode(ev)
+	case directmessage:
+		view.handleDMMode(ev)
+	case pager:
+		view.handlePagerMode(ev)
 	}
 }
 
+// activeKeybindModes returns modes whose bindings are used now, in order of priority
+func (view *view) activeKeybindModes() []KeybindMode {
+	if view.buffer.inputing {
+		if view.buffer.confirm {
+			return []KeybindMode{KEYBIND_MODE_CONFIRM}
+		} else if view.buffer.commanding {
+			return []KeybindMode{KEYBIND_MODE_COMMAND}
+		}
+		return []KeybindMode{KEYBIND_MODE_INPUT}
+	}
+	if view.picker != nil {
+		return []KeybindMode{KEYBIND_MODE_PICKER}
+	}
+	if view.preview != nil {
+		return []KeybindMode{KEYBIND_MODE_PREVIEW}
+	}
+	var mode KeybindMode
+	switch view.getCurrentViewMode() {
+	case home:
+		mode = KEYBIND_MODE_HOME_TIMELINE
+	case mention:
+		mode = KEYBIND_MODE_MENTION_VIEW
+	case conversation:
+		mode = KEYBIND_MODE_CONVERSATION
+	case usertimeline:
+		mode = KEYBIND_MODE_USER_TIMELINE
+	case favorite:
+		mode = KEYBIND_MODE_USER_FAVORITE
+	case list:
+		mode = KEYBIND_MODE_LIST_VIEW
+	case search:
+		mode = KEYBIND_MODE_SEARCH_VIEW
+	case directmessage:
+		mode = KEYBIND_MODE_DM_VIEW
+	case pager:
+		mode = KEYBIND_MODE_PAGER
+	}
+	return []KeybindMode{mode, KEYBIND_MODE_COMMON}
+}
+
+// feedKey adds ev to the key sequence, and returns true when the sequence is
+// completed and handlers should process it. A single key bound to nothing is
+// also completed, so handlers can insert the character
+func (view *view) feedKey(ev termbox.Event) bool {
+	ks := &view.keys
+	key := eventKey(ev)
+	if len(ks.pending) > 0 && sameKey(key, cancelKey) {
+		ks.clear()
+		changeBufferState("Quit")
+		view.showPendingKeys()
+		return false
+	}
+	seq := append(append([]keybind{}, ks.pending...), key)
+	prefix := false
+	for _, mode := range view.activeKeybindModes() {
+		action, isPrefix := view.keymap.lookup(mode, seq)
+		if action != NO_ACTION {
+			ks.clear()
+			ks.current = seq
+			view.buffer.pendingKeys = ""
+			return true
+		}
+		prefix = prefix || isPrefix
+	}
+	if prefix {
+		ks.pending = seq
+		ks.generation++
+		generation := ks.generation
+		time.AfterFunc(KeySequenceTimeout, func() {
+			view.keyTimeoutCh <- generation
+		})
+		view.showPendingKeys()
+		return false
+	}
+	ks.clear()
+	if len(seq) > 1 {
+		notifyWarning("Key", formatKeys(seq)+" is undefined")
+		view.showPendingKeys()
+		return false
+	}
+	ks.current = seq
+	view.buffer.pendingKeys = ""
+	return true
+}
+
+// showPendingKeys shows keys and a count typed so far in the state line
+func (view *view) showPendingKeys() {
+	s := formatKeys(view.keys.pending)
+	if view.vi.count > 0 {
+		s = strings.TrimSpace(strconv.Itoa(view.vi.count) + " " + s)
+	}
+	if s != "" && len(view.keys.pending) > 0 {
+		s += " -"
+	}
+	view.buffer.pendingKeys = s
+	view.refreshBuffer()
+}
+
 func (view *view) handleCommonEvent(ev termbox.Event, tv *tweetview) {
 	cursorPositionTweet := tv.tweets[tv.cursorPosition]
-	switch view.handleAction(ev, KEYBIND_MODE_COMMON) {
+	switch view.handleAction(KEYBIND_MODE_COMMON) {
 	case ACTION_PREVIOUS_TWEET:
-		tv.cursorDown()
+		for i := 0; i < view.vi.repeat(); i++ {
+			tv.cursorDown()
+		}
 	case ACTION_NEXT_TWEET:
-		tv.cursorUp()
+		for i := 0; i < view.vi.repeat(); i++ {
+			tv.cursorUp()
+		}
+	case ACTION_HALF_PAGE_DOWN:
+		_, h := getTermSize()
+		tv.cursorDownLines((h - 2) / 2 * view.vi.repeat())
+	case ACTION_HALF_PAGE_UP:
+		_, h := getTermSize()
+		tv.cursorUpLines((h - 2) / 2 * view.vi.repeat())
+	case ACTION_SEARCH:
+		view.turnSearchMode()
+	case ACTION_SEARCH_NEXT:
+		view.searchTweet(tv, true)
+	case ACTION_SEARCH_PREVIOUS:
+		view.searchTweet(tv, false)
 	case ACTION_TURN_INPUT_MODE:
 		view.turnInputMode()
+	case ACTION_TURN_THREAD_MODE:
+		view.turnThreadMode()
+	case ACTION_SWITCH_TIME_FORMAT:
+		settings.TimeFormat.Style = nextTimeStyle(settings.TimeFormat.Style)
+		changeBufferState("Time format: " + settings.TimeFormat.Style)
 	case ACTION_LIKE_TWEET:
 		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
 			return
 		}
-		if !cursorPositionTweet.isFavorited() {
-			cursorPositionTweet.setFavorited(true)
-			go favoriteTweet(cursorPositionTweet.Content.Id)
-		} else {
-			cursorPositionTweet.setFavorited(false)
-			go unfavoriteTweet(cursorPositionTweet.Content.Id)
-		}
+		view.toggleFavorite(cursorPositionTweet)
 	case ACTION_RETWEET:
 		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
 			return
 		}
-		if !cursorPositionTweet.isRetweeted() {
-			cursorPositionTweet.setRetweeted(true)
-			go retweet(cursorPositionTweet.Content.Id)
+		view.toggleRetweet(cursorPositionTweet)
+	case ACTION_QUOTE_TWEET:
+		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
+			return
+		}
+		view.turnQuoteMode(cursorPositionTweet)
+	case ACTION_DELETE_TWEET:
+		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
+			return
 		}
+		view.confirmDeleteTweet(cursorPositionTweet)
+	case ACTION_BLOCK_USER:
+		view.confirmAuthorAction("block", cursorPositionTweet)
+	case ACTION_MUTE_USER:
+		view.confirmAuthorAction("mute", cursorPositionTweet)
+	case ACTION_REPORT_USER:
+		view.confirmAuthorAction("report", cursorPositionTweet)
 	case ACTION_TURN_COMMAND_MODE:
 		view.turnCommandMode()
 	case ACTION_QUIT:
@@ -306,6 +818,11 @@ func (view *view) handleCommonEvent(ev termbox.Event, tv *tweetview) {
 			return
 		}
 		view.turnReplyMode(cursorPositionTweet)
+	case ACTION_REPLY_ALL:
+		if cursorPositionTweet.Empty || cursorPositionTweet.ReloadMark {
+			return
+		}
+		view.turnReplyAllMode(cursorPositionTweet)
 	case ACTION_TURN_USER_TIMELINE_MODE:
 		if cursorPositionTweet.Empty || cursorPositionTweet.ReloadMark {
 			return
@@ -325,24 +842,23 @@ func (view *view) handleCommonEvent(ev termbox.Event, tv *tweetview) {
 		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
 			return
 		}
-		for _, url := range cursorPositionTweet.Content.Entities.Urls {
-			go openCommand(url.Expanded_url)
-		}
+		view.picker = newURLPicker(cursorPositionTweet.Content)
 	case ACTION_OPEN_IMAGES:
 		if cursorPositionTweet.ReloadMark || cursorPositionTweet.Empty {
 			return
 		}
-		urls := make([]string, 0)
-		for _, media := range cursorPositionTweet.Content.ExtendedEntities.Media {
-			urls = append(urls, media.Media_url_https)
-		}
-		if len(urls) > 0 {
-			go openMedia(urls)
+		if urls := previewURLs(cursorPositionTweet.Content); len(urls) > 0 {
+			view.openPreview(urls)
 		}
 	case ACTION_MOVE_TO_TOP_TWEET:
 		tv.cursorMoveToTop()
 	case ACTION_MOVE_TO_BOTTOM_TWEET:
-		tv.cursorMoveToBottom()
+		if view.vi.count > 0 {
+			// 5G moves to 5th tweet like vi
+			tv.moveCursorTo(view.vi.count - 1)
+		} else {
+			tv.cursorMoveToBottom()
+		}
 	case ACTION_PAGE_UP:
 		psc := tv.scroll
 		_, h := getTermSize()
@@ -381,22 +897,17 @@ func (view *view) handleHometimelineMode(ev termbox.Event) {
 	cursorPositionTweet := view.timelineview.
 		tweets[view.timelineview.cursorPosition]
 
-	switch view.handleAction(ev, KEYBIND_MODE_HOME_TIMELINE) { // go conversation view
+	switch view.handleAction(KEYBIND_MODE_HOME_TIMELINE) { // go conversation view
 	case ACTION_LOAD_PREVIOUSE_TWEETS:
 		if cursorPositionTweet.ReloadMark {
 			if !view.timelineview.isEmpty() {
-				go view.timelineview.loadIntervalTweet(view.timelineview.tweets[view.
-					timelineview.cursorPosition-1].Content.Id)
+				go view.timelineview.loadIntervalTweet(view.timelineview.gapMaxID(view.timelineview.cursorPosition))
 			} else {
 				go view.timelineview.loadTweet(0)
 			}
 		}
 	case ACTION_LOAD_NEW_TWEETS:
-		if !view.timelineview.isEmpty() {
-			go view.timelineview.loadTweet(view.timelineview.tweets[0].Content.Id)
-		} else {
-			go view.timelineview.loadTweet(0)
-		}
+		go view.timelineview.loadTweet(view.timelineview.newestID())
 	default:
 		view.handleCommonEvent(ev, view.timelineview.tweetview)
 	}
@@ -404,7 +915,7 @@ func (view *view) handleHometimelineMode(ev termbox.Event) {
 }
 
 func (view *view) handleInputMode(ev termbox.Event) {
-	switch view.handleAction(ev, KEYBIND_MODE_INPUT) {
+	switch view.handleAction(KEYBIND_MODE_INPUT) {
 	case ACTION_MOVE_LEFT:
 		view.buffer.cursorMoveBackward()
 	case ACTION_MOVE_RIGHT:
@@ -416,7 +927,11 @@ func (view *view) handleInputMode(ev termbox.Event) {
 	case ACTION_INSERT_SPACE:
 		view.buffer.insertRune(' ')
 	case ACTION_EXIT_INPUT_MODE:
-		view.exitInputMode()
+		if view.buffer.resume != nil {
+			view.buffer.resume()
+			break
+		}
+		view.cancelInput()
 		view.refreshAll()
 		return
 	case ACTION_DELETE_RUNE:
@@ -425,17 +940,32 @@ func (view *view) handleInputMode(ev termbox.Event) {
 		view.buffer.cutToClipboard()
 	case ACTION_TEXT_PASTE:
 		view.buffer.pasteFromClipboard()
+	case ACTION_ATTACH_FILE:
+		// Direct messages cannot have media
+		if !view.buffer.commanding && view.buffer.counting {
+			view.turnAttachMode()
+		}
 	case ACTION_MOVE_LINE_TOP:
 		view.buffer.cursorMoveToLineTop()
 	case ACTION_MOVE_LINE_BOTTOM:
 		view.buffer.cursorMoveToLineBottom()
 	case ACTION_TURN_CONFIRM_MODE:
+		if view.buffer.replyAll {
+			view.buffer.confirmPrompt = replyConfirmText(string(view.buffer.content))
+		}
+		if view.buffer.thread {
+			n := len(threadTweets(string(view.buffer.content)))
+			if n == 0 {
+				break
+			}
+			view.buffer.confirmPrompt = fmt.Sprintf("post %d tweets?[Enter/C-g]", n)
+		}
 		if len(view.buffer.content) != 0 {
 			view.turnConfirmMode()
 		}
 	case ACTION_INSERT_NEW_LINE:
 		if view.buffer.commanding {
-			view.executeCommand(string(view.buffer.content))
+			view.buffer.process(string(view.buffer.content))
 			view.buffer.updateCursorPosition()
 			view.refreshAll()
 			return
@@ -455,53 +985,123 @@ func (view *view) handleConfirmMode(ev termbox.Event) {
 	if view.buffer.confirmLock.isLocking() {
 		return
 	}
-	switch view.handleAction(ev, KEYBIND_MODE_CONFIRM) {
+	switch view.handleAction(KEYBIND_MODE_CONFIRM) {
 	case ACTION_CANCEL_SUBMIT:
+		if view.buffer.actionConfirm {
+			view.exitConfirmMode()
+			break
+		}
 		view.buffer.inputing = true
 		view.buffer.confirm = false
 		view.buffer.cursorMoveToLineBottom()
 		view.buffer.updateCursorPosition()
 	case ACTION_SUBMIT_TWEET:
-		go view.buffer.process(string(view.buffer.content))
-		view.exitConfirmMode()
+		if part, over := view.buffer.overLimit(); over > 0 {
+			message := fmt.Sprintf("too long to tweet, remove %d characters", over)
+			if view.buffer.thread {
+				message = fmt.Sprintf("part %d is %s", part+1, message)
+			}
+			notifyWarning("Tweet", message)
+			view.buffer.inputing = true
+			view.buffer.confirm = false
+			view.buffer.cursorMoveToLineBottom()
+			view.buffer.updateCursorPosition()
+			break
+		}
+		view.submit()
 	}
 	view.refreshAll()
 }
 
+// submit runs process of the confirmed input, media are detached from
+// the buffer here, and given back by postTweet when posting fails
+func (view *view) submit() {
+	text := string(view.buffer.content)
+	if view.buffer.actionConfirm {
+		// Actions start goroutines for requests by themselves
+		view.buffer.process(text)
+	} else if post := view.buffer.post; post != nil {
+		media := view.buffer.attachments
+		view.buffer.attachments = nil
+		go post(text, media)
+	} else {
+		go view.buffer.process(text)
+	}
+	view.exitConfirmMode()
+}
+
 func (view *view) handleMentionviewMode(ev termbox.Event) {
-	switch view.handleAction(ev, KEYBIND_MODE_MENTION_VIEW) {
+	switch view.handleAction(KEYBIND_MODE_MENTION_VIEW) {
 	case ACTION_LOAD_PREVIOUSE_MENTIONS:
 		if !view.mentionview.isEmpty() {
-			go view.mentionview.loadIntervalTweet(view.mentionview.
-				tweets[view.mentionview.cursorPosition-1].Content.Id)
+			go view.mentionview.loadIntervalTweet(view.mentionview.gapMaxID(view.mentionview.cursorPosition))
 		} else {
 			go view.mentionview.loadTweet(0)
 		}
 	case ACTION_LOAD_NEW_MENTIONS:
-		if !view.mentionview.isEmpty() {
-			go view.mentionview.loadTweet(view.mentionview.tweets[0].Content.Id)
-		} else {
-			go view.mentionview.loadTweet(0)
-		}
+		go view.mentionview.loadTweet(view.mentionview.newestID())
 	default:
 		view.handleCommonEvent(ev, view.mentionview.tweetview)
 	}
 	view.refreshAll()
 }
 
-func (view *view) sendNewTweet(status string) {
+func (view *view) sendNewTweet(status string, media attachments) {
 	if view.timelineview.loading.isLocking() || len(status) == 0 {
+		view.restoreAttachments(media)
 		return
 	}
 	view.timelineview.loading.lock()
 	defer view.timelineview.loading.unlock()
+	view.postTweet("Tweet", status, url.Values{}, media)
+}
+
+// postTweet uploads media before posting, media are given back to the buffer when it fails
+func (view *view) postTweet(action, status string, val url.Values, media attachments) (Tweet, error) {
+	if len(media) > 0 {
+		ids, err := media.upload(view.client)
+		if err != nil {
+			notifyError("Uploading media", err)
+			view.restoreAttachments(media)
+			return Tweet{}, err
+		}
+		val.Set("media_ids", ids)
+	}
 	changeBufferState("Posting Tweet...")
-	_, err := api.PostTweet(status, nil)
+	t, err := view.client.PostTweet(status, val)
 	if err != nil {
-		changeBufferState("Err! Failed to tweet")
-		return
+		notifyError(action, err)
+		view.restoreAttachments(media)
+		return t, err
 	}
 	changeBufferState("Tweet!")
+	return t, nil
+}
+
+// restoreAttachments queues media again for the next tweet, unless
+// other media have been attached meanwhile
+func (view *view) restoreAttachments(media attachments) {
+	if len(media) == 0 {
+		return
+	}
+	view.updateCh <- func() {
+		if len(view.buffer.attachments) == 0 {
+			view.buffer.attachments = media
+		}
+	}
+}
+
+// attach queues the file at path for the next tweet
+func (view *view) attach(path string) {
+	a, err := newAttachment(path)
+	if err == nil {
+		view.buffer.attachments, err = view.buffer.attachments.add(a)
+	}
+	if err != nil {
+		notifyWarning("attach", err.Error())
+		return
+	}
+	changeBufferState(fmt.Sprintf("Attached %s (%d/%d)", a.name(), len(view.buffer.attachments), AttachImagesMax))
 }
 
 func (view *view) executeCommand(input string) {
@@ -525,17 +1125,17 @@ func (view *view) executeCommand(input string) {
 	switch cmd {
 	case "user":
 		if noArg {
-			changeBufferState("Err! user command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		if !isScreenNameUsableStr(args) {
-			changeBufferState("Err! invalid screen name")
+			notifyWarning(cmd, "invalid screen name")
 			return
 		}
 		view.turnUserTimelineMode(args)
 	case "list":
 		if noArg {
-			changeBufferState("Err! list command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		resplited := strings.Split(args, "/")
@@ -553,55 +1153,228 @@ func (view *view) executeCommand(input string) {
 		view.turnListModeWithName(un, ln)
 	case "favorite", "fav":
 		if noArg {
-			changeBufferState("Err! fav command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		if !isScreenNameUsableStr(args) {
-			changeBufferState("Err! invalid screen name")
+			notifyWarning(cmd, "invalid screen name")
 			return
 		}
 		view.turnFavoriteviewMode(args)
 	case "follow":
 		if noArg {
-			changeBufferState("Err! follow command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		go func() {
-			u, err := api.FollowUser(args)
+			u, err := view.client.FollowUser(args)
 			if err != nil {
-				changeBufferState("Err: Couldn't follow specified user")
+				notifyError("Follow @"+args, err)
 				return
 			}
 			changeBufferState("Succeed! following @" + u.ScreenName)
 		}()
 	case "unfollow":
 		if noArg {
-			changeBufferState("Err! unfollow command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		go func() {
-			u, err := api.UnfollowUser(args)
+			u, err := view.client.UnfollowUser(args)
 			if err != nil {
-				changeBufferState("Err: Couldn't unfollow specified user")
+				notifyError("Unfollow @"+args, err)
 				return
 			}
 			changeBufferState("Succeed! unfollowing @" + u.ScreenName)
 		}()
+	case "block", "unblock", "report":
+		sn := strings.TrimPrefix(args, "@")
+		if noArg {
+			notifyWarning(cmd, "command needs argument")
+			return
+		}
+		if !isScreenNameUsableStr(sn) {
+			notifyWarning(cmd, "invalid screen name")
+			return
+		}
+		view.confirmUserAction(cmd, sn)
 	case "set_footer":
 		if noArg {
-			changeBufferState("Err! set_footer command needs argument")
+			notifyWarning(cmd, "command needs argument")
 			return
 		}
 		view.buffer.footer = args
 	case "unset_footer":
 		view.buffer.footer = ""
+	case "poll":
+		resplited := strings.Fields(args)
+		if len(resplited) != 2 {
+			notifyWarning(cmd, "usage: poll home|mention|list|dm seconds|off")
+			return
+		}
+		target, ok := pollTargetNames[resplited[0]]
+		if !ok {
+			notifyWarning(cmd, "unknown timeline: "+resplited[0])
+			return
+		}
+		if resplited[1] == "off" {
+			view.scheduler.setInterval(target, 0)
+			changeBufferState("Polling " + resplited[0] + " is disabled")
+			return
+		}
+		sec, err := strconv.Atoi(resplited[1])
+		if err != nil || sec <= 0 {
+			notifyWarning(cmd, "invalid interval: "+resplited[1])
+			return
+		}
+		d := view.scheduler.setInterval(target, time.Duration(sec)*time.Second)
+		changeBufferState(fmt.Sprintf("Polling %s every %v", resplited[0], d))
+	case "cache":
+		if args != "stats" {
+			notifyWarning(cmd, "usage: cache stats")
+			return
+		}
+		count, size := tweetmap.stats()
+		changeBufferState(fmt.Sprintf("Cache: %d tweets (~%s, %d pinned), %d profiles, %d user timelines",
+			count, formatBytes(size), len(view.pinnedTweetIDs()),
+			len(profilemap.profiles()), view.usertimelineview.cache.len()))
+	case "search":
+		if noArg {
+			args = view.searchview.searches.lastQuery()
+			if args == "" {
+				notifyWarning(cmd, "command needs argument")
+				return
+			}
+		}
+		view.turnSearchviewMode(args)
+	case "searches":
+		view.turnPagerMode("Searches", view.searchview.searches.lines)
+	case "recent":
+		ss := view.searchview.searches
+		n, err := strconv.Atoi(args)
+		if err != nil || n < 1 || n > len(ss.History) {
+			notifyWarning(cmd, "usage: recent number (listed by :searches)")
+			return
+		}
+		view.turnSearchviewMode(ss.History[n-1])
+	case "saved":
+		query, ok := view.searchview.searches.Saved[args]
+		if !ok {
+			notifyWarning(cmd, "no saved search: "+args)
+			return
+		}
+		view.turnSearchviewMode(query)
+	case "save_search":
+		resplited := strings.SplitN(args, " ", 2)
+		name := resplited[0]
+		query := view.searchview.query
+		if len(resplited) == 2 {
+			query = strings.TrimSpace(resplited[1])
+		}
+		if name == "" || query == "" {
+			notifyWarning(cmd, "usage: save_search name [query]")
+			return
+		}
+		view.searchview.searches.Saved[name] = query
+		view.saveSearches()
+		changeBufferState("Saved search " + name + ": " + query)
+	case "unsave_search":
+		if _, ok := view.searchview.searches.Saved[args]; !ok {
+			notifyWarning(cmd, "no saved search: "+args)
+			return
+		}
+		delete(view.searchview.searches.Saved, args)
+		view.saveSearches()
+		changeBufferState("Removed saved search " + args)
+	case "attach":
+		if noArg {
+			notifyWarning(cmd, "command needs argument")
+			return
+		}
+		view.attach(args)
+	case "thread":
+		view.turnThreadMode()
+	case "discard_thread":
+		view.thread = nil
+		changeBufferState("Discarded the rest of the thread")
+	case "detach":
+		view.buffer.attachments = nil
+		changeBufferState("Removed attachments")
+	case "mute", "unmute":
+		resplited := strings.SplitN(args, " ", 2)
+		value := ""
+		if len(resplited) == 2 {
+			value = resplited[1]
+		}
+		rule, err := newFilterRule(resplited[0], value)
+		if err != nil {
+			notifyWarning(cmd, err.Error()+" (kinds: "+strings.Join(filterKinds, ", ")+")")
+			return
+		}
+		if cmd == "mute" && rule.Kind == filterUser {
+			view.confirmUserAction(cmd, rule.Value)
+		} else if cmd == "mute" {
+			view.mute(rule)
+		} else {
+			view.unmute(rule)
+		}
+	case "filters":
+		view.turnPagerMode("Filters", filters.lines)
+	case "filter_mode":
+		if args != filterModeHide && args != filterModeCollapse {
+			notifyWarning(cmd, "usage: filter_mode hide|collapse")
+			return
+		}
+		filters.setMode(args)
+		view.applyFilters()
+		view.saveFilters()
+		changeBufferState("Filtered tweets are " + map[string]string{
+			filterModeHide: "hidden", filterModeCollapse: "collapsed"}[args])
+	case "timefmt":
+		tf := &settings.TimeFormat
+		resplited := strings.Fields(args)
+		if len(resplited) == 0 {
+			tf.Style = nextTimeStyle(tf.Style)
+		} else if !isTimeStyle(resplited[0]) || len(resplited) > 2 ||
+			len(resplited) == 2 && resplited[0] != timeStyleZone {
+			notifyWarning(cmd, "usage: timefmt [relative|iso|zone [timezone]]")
+			return
+		} else {
+			if len(resplited) == 2 {
+				if err := tf.setTimezone(resplited[1]); err != nil {
+					notifyWarning(cmd, "unknown timezone: "+resplited[1])
+					return
+				}
+			}
+			tf.Style = resplited[0]
+		}
+		if tf.Style == timeStyleZone && tf.Timezone != "" {
+			changeBufferState("Time format: " + tf.Style + " (" + tf.Timezone + ")")
+		} else {
+			changeBufferState("Time format: " + tf.Style)
+		}
+	case "dm":
+		if noArg {
+			view.turnDMviewMode()
+			return
+		}
+		sn := strings.TrimPrefix(args, "@")
+		if !isScreenNameUsableStr(sn) {
+			notifyWarning(cmd, "invalid screen name")
+			return
+		}
+		view.turnDMInputMode(sn)
+	case "messages":
+		view.turnPagerMode("Messages", messages.lines)
+	case "keys":
+		view.turnPagerMode("Keybindings", view.keymap.lines)
 	default:
-		changeBufferState("Commnad Err")
+		notifyWarning(cmd, "unknown command")
 	}
 }
 
 func (view *view) handleConversationMode(ev termbox.Event) {
-	switch view.handleAction(ev, KEYBIND_MODE_CONVERSATION) {
+	switch view.handleAction(KEYBIND_MODE_CONVERSATION) {
 	case ACTION_EXIT_CONVERSATION_MODE:
 		view.exitConversationviewMode()
 	default:
@@ -613,25 +1386,18 @@ func (view *view) handleConversationMode(ev termbox.Event) {
 func (view *view) handleUserTimelineMode(ev termbox.Event) {
 	cursorPositionTweet := view.usertimelineview.
 		tweets[view.usertimelineview.cursorPosition]
-	switch view.handleAction(ev, KEYBIND_MODE_USER_TIMELINE) {
+	switch view.handleAction(KEYBIND_MODE_USER_TIMELINE) {
 	case ACTION_LOAD_PREVIOUSE_USER_TWEETS:
 		if cursorPositionTweet.ReloadMark && view.usertimelineview.cursorPosition >= 1 {
-			go view.usertimelineview.loadIntervalTweet(view.usertimelineview.
-				tweets[view.usertimelineview.cursorPosition-1].Content.Id)
+			go view.usertimelineview.loadIntervalTweet(view.usertimelineview.gapMaxID(view.usertimelineview.cursorPosition))
 		}
 	case ACTION_LOAD_NEW_USER_TWEETS:
 		if !view.usertimelineview.loading.isLocking() {
-			if !view.usertimelineview.isEmpty() {
-				go view.usertimelineview.loadTweet(view.
-					usertimelineview.tweets[0].Content.Id)
-			} else {
-				go view.usertimelineview.loadTweet(0)
-			}
+			go view.usertimelineview.loadTweet(view.usertimelineview.newestID())
 		}
 	case ACTION_OPEN_USER_PROFILE_IMAGE:
 		if view.usertimelineview.userProfile.ProfileImageURL != "" {
-			urls := []string{view.usertimelineview.userProfile.ProfileImageURL}
-			go openMedia(urls)
+			view.openPreview([]string{view.usertimelineview.userProfile.ProfileImageURL})
 		}
 	default:
 		view.handleCommonEvent(ev, view.usertimelineview.tweetview)
@@ -643,20 +1409,14 @@ func (view *view) handleUserTimelineMode(ev termbox.Event) {
 func (view *view) handleFavoriteMode(ev termbox.Event) {
 	cursorPositionTweet := view.favoriteview.
 		tweets[view.favoriteview.cursorPosition]
-	switch view.handleAction(ev, KEYBIND_MODE_USER_FAVORITE) {
+	switch view.handleAction(KEYBIND_MODE_USER_FAVORITE) {
 	case ACTION_LOAD_PREVIOUSE_USER_TWEETS:
 		if cursorPositionTweet.ReloadMark && view.favoriteview.cursorPosition >= 1 {
-			go view.favoriteview.loadIntervalTweet(view.favoriteview.
-				tweets[view.favoriteview.cursorPosition-1].Content.Id)
+			go view.favoriteview.loadIntervalTweet(view.favoriteview.gapMaxID(view.favoriteview.cursorPosition))
 		}
 	case ACTION_LOAD_NEW_USER_TWEETS:
 		if !view.favoriteview.loading.isLocking() {
-			if !view.favoriteview.isEmpty() {
-				go view.favoriteview.loadTweet(view.
-					favoriteview.tweets[0].Content.Id)
-			} else {
-				go view.favoriteview.loadTweet(0)
-			}
+			go view.favoriteview.loadTweet(view.favoriteview.newestID())
 		}
 	default:
 		view.handleCommonEvent(ev, view.favoriteview.tweetview)
@@ -668,15 +1428,13 @@ func (view *view) handleFavoriteMode(ev termbox.Event) {
 func (view *view) handleListMode(ev termbox.Event) {
 	cursorPositionTweet := view.listview.
 		tweets[view.listview.cursorPosition]
-	switch view.handleAction(ev, KEYBIND_MODE_LIST_VIEW) {
+	switch view.handleAction(KEYBIND_MODE_LIST_VIEW) {
 	case ACTION_LOAD_PREVIOUSE_LIST:
 		if cursorPositionTweet.ReloadMark && view.listview.cursorPosition >= 1 {
-			go view.listview.loadIntervalTweet(view.listview.
-				tweets[view.listview.cursorPosition-1].Content.Id)
+			go view.listview.loadIntervalTweet(view.listview.gapMaxID(view.listview.cursorPosition))
 		}
 	case ACTION_LOAD_NEW_LIST:
-		go view.listview.loadTweet(view.
-			listview.tweets[0].Content.Id)
+		go view.listview.loadTweet(view.listview.newestID())
 	default:
 		view.handleCommonEvent(ev, view.listview.tweetview)
 	}
@@ -684,6 +1442,145 @@ func (view *view) handleListMode(ev termbox.Event) {
 	view.refreshAll()
 }
 
+func (view *view) handleSearchMode(ev termbox.Event) {
+	cursorPositionTweet := view.searchview.
+		tweets[view.searchview.cursorPosition]
+	switch view.handleAction(KEYBIND_MODE_SEARCH_VIEW) {
+	case ACTION_LOAD_PREVIOUS_SEARCH:
+		if cursorPositionTweet.ReloadMark {
+			if !view.searchview.isEmpty() && view.searchview.cursorPosition >= 1 {
+				go view.searchview.loadIntervalTweet(view.searchview.gapMaxID(view.searchview.cursorPosition))
+			} else {
+				go view.searchview.loadTweet(0)
+			}
+		}
+	case ACTION_LOAD_NEW_SEARCH:
+		go view.searchview.loadTweet(view.searchview.newestID())
+	default:
+		view.handleCommonEvent(ev, view.searchview.tweetview)
+	}
+
+	view.refreshAll()
+}
+
+func (view *view) handleDMMode(ev termbox.Event) {
+	dv := view.dmview
+	switch view.handleAction(KEYBIND_MODE_DM_VIEW) {
+	case ACTION_DM_CURSOR_UP:
+		for i := 0; i < view.vi.repeat(); i++ {
+			dv.cursorUp()
+		}
+	case ACTION_DM_CURSOR_DOWN:
+		for i := 0; i < view.vi.repeat(); i++ {
+			dv.cursorDown()
+		}
+	case ACTION_OPEN_DM_CONVERSATION:
+		dv.open()
+	case ACTION_CLOSE_DM_CONVERSATION:
+		if !dv.close() {
+			view.exitToPreviousViewMode()
+		}
+	case ACTION_LOAD_NEW_DMS:
+		go dv.loadMessages(dv.newestReceivedID, dv.newestSentID)
+	case ACTION_COMPOSE_DM:
+		if c := dv.selected(); c != nil {
+			view.turnDMInputMode(c.partner.ScreenName)
+		}
+	default:
+		switch view.handleAction(KEYBIND_MODE_COMMON) {
+		case ACTION_TURN_COMMAND_MODE:
+			view.turnCommandMode()
+		case ACTION_TURN_HOME_TIMELINE_MODE:
+			view.turnHomeTimelineMode()
+		case ACTION_TURN_MENTION_VIEW_MODE:
+			view.turnMentionviewMode()
+		case ACTION_QUIT:
+			view.quit = true
+		}
+	}
+	view.refreshAll()
+}
+
+// handlePickerMode opens the link of a number key, or the selected one
+func (view *view) handlePickerMode(ev termbox.Event) {
+	up := view.picker
+	ch := rune(-1)
+	switch view.handleAction(KEYBIND_MODE_PICKER) {
+	case ACTION_PICKER_UP:
+		up.cursorUp()
+	case ACTION_PICKER_DOWN:
+		up.cursorDown()
+	case ACTION_PICKER_OPEN:
+		ch = 0
+	case ACTION_PICKER_CLOSE:
+		view.picker = nil
+	default:
+		if ev.Ch >= '1' && ev.Ch <= '9' {
+			ch = ev.Ch
+		}
+	}
+	if ch >= 0 {
+		if item, ok := up.pick(ch); ok {
+			view.picker = nil
+			changeBufferState("Opening " + item.url)
+			go openCommand(item.url)
+		}
+	}
+	view.refreshAll()
+}
+
+func (view *view) openPreview(urls []string) {
+	view.preview = newImagePreview(urls)
+	view.loadPreviewImages(view.preview)
+}
+
+// handlePreviewMode cycles images, Enter opens the image with the opener
+func (view *view) handlePreviewMode(ev termbox.Event) {
+	switch view.handleAction(KEYBIND_MODE_PREVIEW) {
+	case ACTION_PREVIEW_PREVIOUS:
+		view.preview.previous()
+	case ACTION_PREVIEW_NEXT:
+		view.preview.next()
+	case ACTION_PREVIEW_OPEN:
+		go openCommand(view.preview.current().url)
+	case ACTION_PREVIEW_CLOSE:
+		view.preview = nil
+	}
+	view.refreshAll()
+}
+
+func (view *view) handlePagerMode(ev termbox.Event) {
+	pv := view.pagerview
+	switch view.handleAction(KEYBIND_MODE_PAGER) {
+	case ACTION_SCROLL_UP:
+		pv.scrollBy(-1)
+	case ACTION_SCROLL_DOWN:
+		pv.scrollBy(1)
+	case ACTION_SCROLL_PAGE_UP:
+		pv.scrollBy(-pv.pageHeight())
+	case ACTION_SCROLL_PAGE_DOWN:
+		pv.scrollBy(pv.pageHeight())
+	case ACTION_SCROLL_TO_TOP:
+		pv.scrollToTop()
+	case ACTION_SCROLL_TO_BOTTOM:
+		pv.scrollToBottom()
+	case ACTION_EXIT_PAGER_MODE:
+		view.exitToPreviousViewMode()
+	default:
+		switch view.handleAction(KEYBIND_MODE_COMMON) {
+		case ACTION_TURN_COMMAND_MODE:
+			view.turnCommandMode()
+		case ACTION_TURN_HOME_TIMELINE_MODE:
+			view.turnHomeTimelineMode()
+		case ACTION_TURN_MENTION_VIEW_MODE:
+			view.turnMentionviewMode()
+		case ACTION_QUIT:
+			view.quit = true
+		}
+	}
+	view.refreshAll()
+}
+
 func (view *view) setViewMode(mode viewmode) {
 	view.modeHistory = append(view.modeHistory, mode)
 	if len(view.modeHistory) > 5 {
@@ -725,47 +1622,339 @@ func (view *view) turnUserTimelineMode(screenName string) {
 	view.usertimelineview.setUserScreenName(screenName)
 	view.setViewMode(usertimeline)
 	view.buffer.setModeStr(usertimeline)
-	view.usertimelineview.cursorPosition = 0
-	view.usertimelineview.scroll = 0
-	if view.usertimelineview.isEmpty() {
-		go view.usertimelineview.loadTweet(0)
-	} else if view.usertimelineview.tweets[0].Content != nil {
-		go view.
-			usertimelineview.loadTweet(view.
-			usertimelineview.tweets[0].Content.Id)
-	}
+	view.usertimelineview.cursorMoveToTop()
+	go view.usertimelineview.loadTweet(view.usertimelineview.newestID())
 
 }
 func (view *view) turnFavoriteviewMode(screenName string) {
 	view.favoriteview.setUserScreenName(screenName)
 	view.setViewMode(favorite)
 	view.buffer.setModeStr(favorite)
-	view.favoriteview.cursorPosition = 0
-	view.favoriteview.scroll = 0
-	if view.favoriteview.isEmpty() {
-		go view.favoriteview.loadTweet(0)
-	} else if view.favoriteview.tweets[0].Content != nil {
-		go view.
-			favoriteview.loadTweet(view.
-			favoriteview.tweets[0].Content.Id)
-	}
+	view.favoriteview.cursorMoveToTop()
+	go view.favoriteview.loadTweet(view.favoriteview.newestID())
 
 }
 func (view *view) turnListModeWithName(owner, name string) {
 	view.listview.setListName(owner, name)
 	view.setViewMode(list)
 	view.buffer.setModeStr(list)
-	view.listview.cursorPosition = 0
-	view.listview.scroll = 0
-	if view.listview.isEmpty() {
-		go view.listview.loadTweet(0)
+	view.listview.cursorMoveToTop()
+	go view.listview.loadTweet(view.listview.newestID())
+
+}
+
+func (view *view) turnSearchviewMode(query string) {
+	if view.searchview.loading.isLocking() {
+		notifyWarning("search", "previous search is still loading")
+		return
+	}
+	view.searchview.setQuery(query)
+	view.saveSearches()
+	if view.getCurrentViewMode() != search {
+		view.setViewMode(search)
+	}
+	view.buffer.setModeStr(search)
+	view.searchview.cursorMoveToTop()
+	go view.searchview.loadTweet(view.searchview.newestID())
+}
+
+func (view *view) saveSearches() {
+	if view.store == nil {
+		return
+	}
+	if err := view.store.saveSearches(view.searchview.searches); err != nil {
+		notifyError("Saving searches", err)
+	}
+}
+
+// toggleFavorite shows the star at once, and takes it back when the request fails
+func (view *view) toggleFavorite(ts tweetstatus) {
+	favorited := !ts.isFavorited()
+	ts.setFavorited(favorited)
+	id := ts.Content.Id
+	go func() {
+		request := favoriteTweet
+		if !favorited {
+			request = unfavoriteTweet
+		}
+		if request(view.client, id) != nil {
+			view.updateCh <- func() { ts.setFavorited(!favorited) }
+		}
+	}()
+}
+
+// toggleRetweet retweets or undoes the retweet, and rolls back like toggleFavorite
+func (view *view) toggleRetweet(ts tweetstatus) {
+	retweeted := !ts.isRetweeted()
+	ts.setRetweeted(retweeted)
+	id := ts.Content.Id
+	if ts.Content.RetweetedStatus != nil {
+		// Retweets are undone by the ID of the original tweet
+		id = ts.Content.RetweetedStatus.Id
+	}
+	go func() {
+		request := retweet
+		if !retweeted {
+			request = unretweet
+		}
+		if request(view.client, id) != nil {
+			view.updateCh <- func() { ts.setRetweeted(!retweeted) }
+		}
+	}()
+}
+
+// confirmDeleteTweet deletes a tweet or a retweet of the user after confirmation
+func (view *view) confirmDeleteTweet(ts tweetstatus) {
+	t := ts.Content
+	if t.User.Id != user.ID {
+		notifyWarning("Delete", "it is not your tweet")
+		return
+	}
+	description := "Delete " + strings.Replace(t.Text, "\n", " ", -1)
+	if t.RetweetedStatus != nil {
+		description = "Undo Retweet of @" + t.RetweetedStatus.User.ScreenName
+	}
+	width, _ := getTermSize()
+	description = runewidth.Truncate(description, width-len(confirmText)-2, "…")
+	view.turnActionConfirmMode(description, func() {
+		go view.deleteTweet(t.Id)
+	})
+}
+
+func (view *view) deleteTweet(id int64) {
+	changeBufferState("Deleting Tweet...")
+	if _, err := view.client.DeleteTweet(id, true); err != nil {
+		notifyError("Delete", err)
+		return
+	}
+	changeBufferState("Deleted!")
+	view.updateCh <- func() { view.removeTweet(id) }
+}
+
+// removeTweet removes a deleted tweet and its retweets from every view,
+// cached timelines and TweetMap
+func (view *view) removeTweet(id int64) {
+	match := func(t *Tweet) bool {
+		return t.Id == id || t.RetweetedStatus != nil && t.RetweetedStatus.Id == id
+	}
+	f := func(ts tweetstatus) bool { return match(ts.Content) }
+	for _, tv := range view.tweetviews() {
+		tv.removeTweets(f)
+	}
+	for _, c := range view.timelineCaches() {
+		c.removeTweets(f)
+	}
+	tweetmap.remove(match)
+}
+
+// confirmAuthorAction is confirmUserAction to the author of ts, not the retweeter
+func (view *view) confirmAuthorAction(cmd string, ts tweetstatus) {
+	if ts.Empty || ts.ReloadMark {
+		return
+	}
+	t := ts.Content
+	if t.RetweetedStatus != nil {
+		t = t.RetweetedStatus
+	}
+	view.confirmUserAction(cmd, t.User.ScreenName)
+}
+
+// confirmUserAction blocks, unblocks, mutes or reports a user after confirmation
+func (view *view) confirmUserAction(cmd, screenName string) {
+	if strings.EqualFold(screenName, user.ScreenName) {
+		notifyWarning(cmd, "you cannot "+cmd+" yourself")
+		return
+	}
+	var description string
+	var action func()
+	switch cmd {
+	case "block":
+		description = "Block @" + screenName
+		action = func() {
+			go func() {
+				u, err := view.client.BlockUser(screenName)
+				if err != nil {
+					notifyError("Block @"+screenName, err)
+					return
+				}
+				changeBufferState("Succeed! blocking @" + u.ScreenName)
+			}()
+		}
+	case "unblock":
+		description = "Unblock @" + screenName
+		action = func() {
+			go func() {
+				u, err := view.client.UnblockUser(screenName)
+				if err != nil {
+					notifyError("Unblock @"+screenName, err)
+					return
+				}
+				changeBufferState("Succeed! unblocking @" + u.ScreenName)
+			}()
+		}
+	case "mute":
+		rule, err := newFilterRule(filterUser, screenName)
+		if err != nil {
+			notifyWarning(cmd, err.Error())
+			return
+		}
+		description = "Mute @" + screenName
+		action = func() {
+			view.mute(rule)
+		}
+	case "report":
+		description = "Report @" + screenName + " as spam and block"
+		action = func() {
+			go func() {
+				u, err := view.client.ReportSpam(screenName)
+				if err != nil {
+					notifyError("Report @"+screenName, err)
+					return
+				}
+				changeBufferState("Succeed! reporting @" + u.ScreenName)
+			}()
+		}
+	default:
+		return
+	}
+	view.turnActionConfirmMode(description, action)
+}
+
+// mute adds rule, and mutes the user on the server too for rules of users
+func (view *view) mute(rule *filterRule) {
+	if !filters.add(rule) {
+		notifyWarning("mute", "already muted: "+rule.String())
+		return
+	}
+	view.applyFilters()
+	view.saveFilters()
+	changeBufferState("Muted " + rule.String())
+	if rule.Kind != filterUser {
+		return
+	}
+	go func() {
+		u, err := view.client.MuteUser(rule.Value)
+		if err != nil {
+			notifyError("Mute @"+rule.Value, err)
+			return
+		}
+		filters.setUserMuted(u.Id, true)
+	}()
+}
+
+// unmute removes rule, users may be muted only on the server
+func (view *view) unmute(rule *filterRule) {
+	removed := filters.remove(rule)
+	if !removed && rule.Kind != filterUser {
+		notifyWarning("unmute", "no such rule: "+rule.String())
+		return
+	}
+	if removed {
+		view.applyFilters()
+		view.saveFilters()
+		changeBufferState("Unmuted " + rule.String())
 	}
+	if rule.Kind != filterUser {
+		return
+	}
+	go func() {
+		u, err := view.client.UnmuteUser(rule.Value)
+		if err != nil {
+			notifyError("Unmute @"+rule.Value, err)
+			return
+		}
+		filters.setUserMuted(u.Id, false)
+		changeBufferState("Unmuted @" + u.ScreenName)
+		view.filterCh <- struct{}{}
+	}()
+}
+
+// syncMutes fetches all users muted on the server
+func (view *view) syncMutes() error {
+	ids := make([]int64, 0)
+	cursor := int64(-1)
+	for cursor != 0 {
+		val := url.Values{}
+		val.Add("cursor", strconv.FormatInt(cursor, 10))
+		c, err := view.client.GetMutedUsersIds(val)
+		if err != nil {
+			notifyError("Loading muted users", err)
+			return err
+		}
+		ids = append(ids, c.Ids...)
+		cursor = c.Next_cursor
+	}
+	filters.setMuted(ids)
+	view.filterCh <- struct{}{}
+	return nil
+}
 
+// applyFilters filters tweets already shown, tweets of conversations are not filtered
+func (view *view) applyFilters() {
+	for _, tv := range view.tweetviews() {
+		if tv != view.conversationview.tweetview {
+			tv.applyFilter()
+		}
+	}
+}
+
+func (view *view) saveFilters() {
+	if view.store == nil {
+		return
+	}
+	if err := view.store.saveFilters(filters); err != nil {
+		notifyError("Saving filters", err)
+	}
+}
+
+func (view *view) turnDMviewMode() {
+	if view.getCurrentViewMode() != directmessage {
+		view.setViewMode(directmessage)
+	}
+	view.buffer.setModeStr(directmessage)
+	if !view.dmview.loaded {
+		go view.dmview.loadMessages(0, 0)
+	}
+}
+
+// turnDMInputMode opens the input area to write a direct message to screenName
+func (view *view) turnDMInputMode(screenName string) {
+	view.buffer.inputing = true
+	view.buffer.clear()
+	view.buffer.inputTitle = "*Direct Message to @" + screenName + "*"
+	view.buffer.confirmPrompt = "send to @" + screenName + "?[Enter/C-g]"
+	view.buffer.updateCursorPosition()
+	view.buffer.process = func(text string) {
+		view.sendDirectMessage(screenName, text)
+	}
+}
+
+func (view *view) sendDirectMessage(screenName, text string) {
+	if len(text) == 0 {
+		return
+	}
+	changeBufferState("Sending Direct Message...")
+	dm, err := view.client.PostDMToScreenName(text, screenName)
+	if err != nil {
+		notifyError("Direct Message to @"+screenName, err)
+		return
+	}
+	changeBufferState("Sent to @" + screenName + "!")
+	view.dmview.loadCh <- dmLoad{sent: []anaconda.DirectMessage{dm}}
+}
+
+func (view *view) turnPagerMode(title string, source func() []pagerLine) {
+	view.pagerview.open(title, source)
+	if view.getCurrentViewMode() != pager {
+		view.setViewMode(pager)
+	}
+	view.buffer.setModeStr(pager)
 }
 
 func (view *view) turnInputMode() {
 	view.buffer.inputing = true
 	view.buffer.clear()
+	view.buffer.counting = true
 	// Set footer
 	if view.buffer.footer != "" {
 		// insert a SPACE before footer
@@ -773,27 +1962,76 @@ func (view *view) turnInputMode() {
 	}
 	view.buffer.cursorMoveToLineTop()
 	view.buffer.updateCursorPosition()
-	view.buffer.process = view.sendNewTweet
+	view.buffer.post = view.sendNewTweet
 }
 
 func (view *view) turnReplyMode(ts tweetstatus) {
 	view.buffer.inputing = true
+	view.buffer.counting = true
 	view.buffer.setContent("@" + ts.Content.User.ScreenName + " ")
 	view.buffer.cursorMoveToLineBottom()
 	view.buffer.updateCursorPosition()
-	view.buffer.process = func(status string) {
+	view.buffer.post = func(status string, media attachments) {
 		if view.timelineview.loading.isLocking() || len(status) == 0 {
+			view.restoreAttachments(media)
 			return
 		}
-		changeBufferState("Posting Tweet...")
 		val := url.Values{}
 		val.Add("in_reply_to_status_id", strconv.FormatInt(ts.Content.Id, 10))
-		_, err := api.PostTweet(status, val)
-		if err != nil {
-			changeBufferState("Err! Failed to tweet")
-			return
+		view.postTweet("Reply", status, val, media)
+	}
+}
+
+// turnReplyAllMode replies to everyone in the tweet, the confirm text lists
+// users who will be notified
+func (view *view) turnReplyAllMode(ts tweetstatus) {
+	view.turnReplyMode(ts)
+	view.buffer.inputTitle = "*Reply All*"
+	view.buffer.replyAll = true
+	view.buffer.clear()
+	if names := replyAllScreenNames(ts.Content); len(names) > 0 {
+		view.buffer.setContent("@" + strings.Join(names, " @") + " ")
+	}
+	view.buffer.updateCursorPosition()
+}
+
+// turnQuoteMode opens the input area with the URL of the tweet to quote it
+func (view *view) turnQuoteMode(ts tweetstatus) {
+	t := ts.Content
+	if t.RetweetedStatus != nil {
+		t = t.RetweetedStatus
+	}
+	view.buffer.inputing = true
+	view.buffer.inputTitle = "*Quote Tweet*"
+	view.buffer.counting = true
+	view.buffer.quote = t
+	view.buffer.setContent(" " + permalink(t))
+	view.buffer.cursorMoveToLineTop()
+	view.buffer.updateCursorPosition()
+	view.buffer.post = view.sendNewTweet
+}
+
+// turnAttachMode asks a path of a file to attach while editing a tweet,
+// the edited text comes back after the path is entered or canceled
+func (view *view) turnAttachMode() {
+	content := string(view.buffer.content)
+	cursorX := view.buffer.cursorX
+	process := view.buffer.process
+	view.buffer.commanding = true
+	view.buffer.prompt = attachPrompt
+	view.buffer.setContent("")
+	view.buffer.resume = func() {
+		view.buffer.commanding = false
+		view.buffer.resume = nil
+		view.buffer.setContent(content)
+		view.buffer.cursorX = cursorX
+		view.buffer.process = process
+	}
+	view.buffer.process = func(path string) {
+		view.buffer.resume()
+		if path = strings.TrimSpace(path); path != "" {
+			view.attach(path)
 		}
-		changeBufferState("Tweet!")
 	}
 }
 
@@ -808,17 +2046,75 @@ func (view *view) turnConfirmMode() {
 	}()
 }
 
+// turnActionConfirmMode asks "ok?" before action, description is shown in the input area
+func (view *view) turnActionConfirmMode(description string, action func()) {
+	view.buffer.inputing = true
+	view.buffer.actionConfirm = true
+	view.buffer.inputTitle = "*Confirm*"
+	view.buffer.setContent(description)
+	view.buffer.process = func(string) {
+		action()
+	}
+	view.turnConfirmMode()
+}
+
 func (view *view) turnCommandMode() {
 	view.buffer.inputing = true
 	view.buffer.commanding = true
+	view.buffer.prompt = commandPrompt
 	view.buffer.clear()
 	view.buffer.cursorMoveToLineBottom()
+	view.buffer.process = view.executeCommand
+}
+
+func (view *view) turnSearchMode() {
+	view.turnCommandMode()
+	view.buffer.prompt = searchPrompt
+	view.buffer.process = func(query string) {
+		view.exitInputMode()
+		if query != "" {
+			view.searchQuery = query
+		}
+		if tv := view.currentTweetview(); tv != nil {
+			view.searchTweet(tv, true)
+		}
+	}
+}
+
+// searchTweet moves the cursor to the next tweet matching the last query
+func (view *view) searchTweet(tv *tweetview, forward bool) {
+	if view.searchQuery == "" {
+		return
+	}
+	index := tv.search(view.searchQuery, forward)
+	if index < 0 {
+		notifyWarning("Search", "pattern not found: "+view.searchQuery)
+		return
+	}
+	tv.moveCursorTo(index)
+}
+
+// cancelInput closes the input area, media attached to a canceled tweet are dropped
+func (view *view) cancelInput() {
+	if view.buffer.counting {
+		view.buffer.attachments = nil
+	}
+	view.exitInputMode()
 }
 
 func (view *view) exitInputMode() {
 	view.buffer.inputing = false
+	view.buffer.inputTitle = ""
+	view.buffer.confirmPrompt = ""
+	view.buffer.actionConfirm = false
+	view.buffer.quote = nil
+	view.buffer.counting = false
+	view.buffer.thread = false
+	view.buffer.replyAll = false
+	view.buffer.resume = nil
 	view.buffer.commanding = false
 	view.buffer.process = nil
+	view.buffer.post = nil
 	view.buffer.clear()
 	view.buffer.setModeStr(view.getCurrentViewMode())
 	view.buffer.cursorX = 0
@@ -827,8 +2123,16 @@ func (view *view) exitInputMode() {
 
 func (view *view) exitConfirmMode() {
 	view.buffer.inputing = false
+	view.buffer.inputTitle = ""
+	view.buffer.confirmPrompt = ""
+	view.buffer.actionConfirm = false
+	view.buffer.quote = nil
+	view.buffer.counting = false
+	view.buffer.thread = false
+	view.buffer.replyAll = false
 	view.buffer.confirm = false
 	view.buffer.process = nil
+	view.buffer.post = nil
 	view.buffer.clear()
 	view.buffer.setModeStr(view.getCurrentViewMode())
 	view.buffer.cursorX = 0
@@ -853,3 +2157,12 @@ func (view *view) exitConversationviewMode() {
 		view.buffer.setModeStr(favorite)
 	}
 }
+
+// exitToPreviousViewMode returns from a view opened over another, like the pager and DMs,
+// the current mode is popped from the history, so views opened over each other close in turn
+func (view *view) exitToPreviousViewMode() {
+	if len(view.modeHistory) > 1 {
+		view.modeHistory = view.modeHistory[:len(view.modeHistory)-1]
+	}
+	view.buffer.setModeStr(view.getCurrentViewMode())
+}
@@ -0,0 +1,201 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"github.com/nsf/termbox-go"
+	"testing"
+	"time"
+)
+
+func receiveUpdate(t *testing.T, ch chan func()) {
+	select {
+	case update := <-ch:
+		update()
+	case <-time.After(time.Second):
+		t.Fatalf("update was not requested")
+	}
+}
+
+func TestConfirmUserAction(t *testing.T) {
+	defer resetFilters()
+	initialize()
+	initializeState()
+	user = UserConfig{ID: 1, ScreenName: "me"}
+	client := newFakeClient()
+	view := newView(client, nil)
+
+	testcase := []struct {
+		cmd, description, state string
+	}{
+		{"block", "Block @alice", "Succeed! blocking @alice"},
+		{"unblock", "Unblock @alice", "Succeed! unblocking @alice"},
+		{"report", "Report @alice as spam and block", "Succeed! reporting @alice"},
+		{"mute", "Mute @alice", "Muted user @alice"},
+	}
+	for _, c := range testcase {
+		client.calls = nil
+		view.confirmUserAction(c.cmd, "alice")
+		if !view.buffer.confirm || !view.buffer.actionConfirm ||
+			string(view.buffer.content) != c.description || view.buffer.confirmText() != confirmText {
+			t.Fatalf("%s: confirmation was not asked: %q", c.cmd, view.buffer.content)
+		}
+		if len(client.calls) != 0 {
+			t.Fatalf("%s: nothing must be requested until it is confirmed", c.cmd)
+		}
+		view.buffer.process(string(view.buffer.content))
+		view.exitConfirmMode()
+		waitState(t, c.state)
+		if view.buffer.actionConfirm || view.buffer.inputing {
+			t.Fatalf("%s: confirmation must be finished", c.cmd)
+		}
+	}
+	if len(filters.Rules) != 1 {
+		t.Fatalf("Mute must add a filter rule")
+	}
+	client.waitCall(t, "MuteUser")
+
+	client.calls = nil
+	view.confirmUserAction("block", "me")
+	if view.buffer.confirm || len(client.calls) != 0 {
+		t.Fatalf("Actions to yourself must be refused")
+	}
+}
+
+func TestToggleRollback(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	client.err = errors.New("fake: failure")
+	view := newView(client, nil)
+	tweet := newFakeTweet(1, "alice", "hello")
+	ts := wrapTweet(&tweet)
+
+	view.toggleFavorite(ts)
+	if !ts.isFavorited() {
+		t.Fatalf("Star must be shown before the request finishes")
+	}
+	receiveUpdate(t, view.updateCh)
+	if ts.isFavorited() {
+		t.Fatalf("Star must be taken back when the request fails")
+	}
+
+	original := newFakeTweet(2, "bob", "original")
+	rt := newFakeTweet(3, "alice", "RT @bob: original")
+	rt.RetweetedStatus = &original
+	rt.Retweeted = true
+	ts = wrapTweet(&rt)
+	view.toggleRetweet(ts)
+	if ts.isRetweeted() {
+		t.Fatalf("Retweet must be undone at once")
+	}
+	receiveUpdate(t, view.updateCh)
+	if !ts.isRetweeted() {
+		t.Fatalf("Retweet must be rolled back when the request fails")
+	}
+	if calls := client.calls; calls[len(calls)-1] != "UnRetweet" {
+		t.Fatalf("Retweet must be undone by UnRetweet: %v", calls)
+	}
+}
+
+func TestDeleteTweet(t *testing.T) {
+	initialize()
+	initializeState()
+	user = UserConfig{ID: 1, ScreenName: "me"}
+	client := newFakeClient()
+	view := newView(client, nil)
+	mine := newFakeTweet(10, "me", "my tweet")
+	mine.User.Id = user.ID
+	rt := newFakeTweet(11, "alice", "RT @me: my tweet")
+	rt.RetweetedStatus = &mine
+	view.timelineview.addNewTweet(wrapTweets([]Tweet{rt, newFakeTweet(9, "bob", "other"), mine}))
+	view.mentionview.addNewTweet(wrapTweets([]Tweet{rt}))
+	tweetmap.registerTweets([]Tweet{rt, mine})
+	// Own profile shown before is cached
+	view.usertimelineview.setUserScreenName("me")
+	view.usertimelineview.tweets = wrapTweets([]Tweet{mine, newFakeTweet(8, "me", "older")})
+	view.usertimelineview.setUserScreenName("bob")
+
+	view.confirmDeleteTweet(view.timelineview.tweets[1])
+	if view.buffer.confirm {
+		t.Fatalf("Tweets of others must not be deleted")
+	}
+	view.confirmDeleteTweet(view.timelineview.tweets[2])
+	if !view.buffer.actionConfirm || string(view.buffer.content) != "Delete my tweet" {
+		t.Fatalf("Deleting must be confirmed: %q", view.buffer.content)
+	}
+	view.buffer.process("")
+	view.exitConfirmMode()
+	receiveUpdate(t, view.updateCh)
+	if len(view.timelineview.tweets) != 2 || view.timelineview.tweets[0].Content.Id != 9 {
+		t.Fatalf("Deleted tweet and its retweets must be removed: %d tweets", len(view.timelineview.tweets))
+	}
+	if !view.mentionview.isEmpty() {
+		t.Fatalf("Deleted tweet must be removed from every view")
+	}
+	if cached := view.usertimelineview.cache.timelines["me"]; len(cached) != 1 || cached[0].Content.Id != 8 {
+		t.Fatalf("Deleted tweet must be removed from cached timelines")
+	}
+	for _, id := range []int64{10, 11} {
+		if _, ok := tweetmap.get(id); ok {
+			t.Fatalf("Tweet %d must be removed from TweetMap", id)
+		}
+	}
+}
+
+func TestExitToPreviousViewMode(t *testing.T) {
+	initialize()
+	initializeState()
+	view := newView(newFakeClient(), nil)
+	view.turnMentionviewMode()
+	view.dmview.loaded = true
+	view.turnDMviewMode()
+	view.turnPagerMode("Messages", messages.lines)
+
+	view.handleEvent(termbox.Event{Ch: 'q'})
+	if mode := view.getCurrentViewMode(); mode != directmessage {
+		t.Fatalf("Closing the pager must return to DMs, but %v", mode)
+	}
+	view.handleEvent(termbox.Event{Ch: 'q'})
+	if mode := view.getCurrentViewMode(); mode != mention {
+		t.Fatalf("Closing DMs must return to the timeline, but %v", mode)
+	}
+}
+
+func TestLoadNewListBehindReloadMark(t *testing.T) {
+	initialize()
+	initializeState()
+	client := newFakeClient()
+	view := newView(client, nil)
+	view.turnListModeWithName("alice", "friends")
+	select {
+	case <-view.listview.loadNewTweetCh:
+	case <-time.After(time.Second):
+		t.Fatalf("list was not loaded")
+	}
+	if !view.listview.tweets[0].ReloadMark {
+		t.Fatalf("A list not loaded yet must begin with a ReloadMark")
+	}
+
+	client.mutex.Lock()
+	client.calls = nil
+	client.mutex.Unlock()
+	view.handleEvent(termbox.Event{Key: termbox.KeyCtrlR})
+	client.waitCall(t, "GetListTweets")
+}
@@ -0,0 +1,130 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"strings"
+)
+
+// viMaxCount limits count prefixes, ex) 99999j
+const viMaxCount = 9999
+
+// viState reads count prefixes of vi-style keys, ex) 5j
+// Sequences like "gg" are handled by keymap
+type viState struct {
+	count int
+}
+
+// feed returns true when ev is consumed as a part of a count
+func (vs *viState) feed(ev termbox.Event) bool {
+	if ev.Mod != NO_MOD || ev.Key != NO_KEY {
+		return false
+	}
+	if ev.Ch >= '1' && ev.Ch <= '9' || ev.Ch == '0' && vs.count > 0 {
+		vs.count = vs.count*10 + int(ev.Ch-'0')
+		if vs.count > viMaxCount {
+			vs.count = viMaxCount
+		}
+		return true
+	}
+	return false
+}
+
+// repeat returns how many times a moving action should be done
+func (vs *viState) repeat() int {
+	if vs.count > 0 {
+		return vs.count
+	}
+	return 1
+}
+
+func (vs *viState) reset() {
+	vs.count = 0
+}
+
+// moveCursorTo moves the cursor step by step, so scroll follows it
+func (tv *tweetview) moveCursorTo(index int) {
+	if index >= len(tv.tweets) {
+		index = len(tv.tweets) - 1
+	}
+	for tv.cursorPosition < index {
+		tv.cursorDown()
+	}
+	for tv.cursorPosition > index && index >= 0 {
+		tv.cursorUp()
+	}
+}
+
+// cursorDownLines moves the cursor down until it passes n lines
+func (tv *tweetview) cursorDownLines(n int) {
+	for sum := 0; sum < n; {
+		pcp := tv.cursorPosition
+		sum += tv.tweets[pcp].countLines()
+		tv.cursorDown()
+		if pcp == tv.cursorPosition {
+			break
+		}
+	}
+}
+
+// cursorUpLines moves the cursor up until it passes n lines
+func (tv *tweetview) cursorUpLines(n int) {
+	for sum := 0; sum < n; {
+		pcp := tv.cursorPosition
+		tv.cursorUp()
+		if pcp == tv.cursorPosition {
+			break
+		}
+		sum += tv.tweets[tv.cursorPosition].countLines()
+	}
+}
+
+func tweetMatches(ts tweetstatus, query string) bool {
+	if ts.Content == nil || ts.hidden() {
+		return false
+	}
+	t := ts.Content
+	if t.RetweetedStatus != nil {
+		t = t.RetweetedStatus
+	}
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(t.Text), query) ||
+		strings.Contains(strings.ToLower(t.User.ScreenName), query) ||
+		strings.Contains(strings.ToLower(t.User.Name), query)
+}
+
+// search returns the index of the next tweet matching query from the cursor,
+// forward means older tweets. It returns -1 when nothing matches
+func (tv *tweetview) search(query string, forward bool) int {
+	if query == "" {
+		return -1
+	}
+	n := len(tv.tweets)
+	for i := 1; i < n; i++ {
+		index := tv.cursorPosition + i
+		if !forward {
+			index = tv.cursorPosition - i + n
+		}
+		index %= n
+		if tweetMatches(tv.tweets[index], query) {
+			return index
+		}
+	}
+	return -1
+}
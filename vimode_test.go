@@ -0,0 +1,94 @@
+// This file is part of Ringot.
+/*
+Copyright 2016 tSU-RooT <tsu.root@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/nsf/termbox-go"
+	"testing"
+)
+
+func TestViStateFeed(t *testing.T) {
+	var vs viState
+	for _, ch := range "12" {
+		if !vs.feed(termbox.Event{Ch: ch}) {
+			t.Fatalf("Digits must be consumed as a count")
+		}
+	}
+	if vs.feed(termbox.Event{Ch: 'j'}) {
+		t.Fatalf("j must be passed to keymap")
+	}
+	if vs.repeat() != 12 {
+		t.Fatalf("Expected count 12, but %d", vs.repeat())
+	}
+	vs.reset()
+	if vs.feed(termbox.Event{Ch: '0'}) {
+		t.Fatalf("0 without count must not be consumed")
+	}
+}
+
+func TestViPreset(t *testing.T) {
+	km := newDefaultKeymap()
+	if err := km.apply(keymapConfig{Preset: viPreset}); err != nil {
+		t.Fatal(err)
+	}
+	testcase := map[rune]Action{
+		'j': ACTION_PREVIOUS_TWEET,
+		'k': ACTION_NEXT_TWEET,
+		'G': ACTION_MOVE_TO_BOTTOM_TWEET,
+		'/': ACTION_SEARCH,
+		'n': ACTION_SEARCH_NEXT,
+		'N': ACTION_SEARCH_PREVIOUS,
+	}
+	for ch, expected := range testcase {
+		if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Ch: ch}}); a != expected {
+			t.Fatalf("%c: Expected %d, but %d", ch, expected, a)
+		}
+	}
+	if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Key: termbox.KeyCtrlD}}); a != ACTION_HALF_PAGE_DOWN {
+		t.Fatalf("C-d must be half_page_down, but %d", a)
+	}
+	if a, prefix := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Ch: 'g'}}); a != NO_ACTION || !prefix {
+		t.Fatalf("g must be a prefix of gg")
+	}
+	if a, _ := km.lookup(KEYBIND_MODE_COMMON, []keybind{{Ch: 'g'}, {Ch: 'g'}}); a != ACTION_MOVE_TO_TOP_TWEET {
+		t.Fatalf("gg must move to top, but %d", a)
+	}
+}
+
+func TestTweetviewSearch(t *testing.T) {
+	initialize()
+	tv := newTweetview()
+	tweets := make([]Tweet, 0)
+	for i, text := range []string{"hello", "Gopher", "world", "gophers"} {
+		tweets = append(tweets, newFakeTweet(int64(10-i), "alice", text))
+	}
+	tv.addNewTweet(wrapTweets(tweets))
+	if i := tv.search("gopher", true); i != 1 {
+		t.Fatalf("Expected 1, but %d", i)
+	}
+	tv.moveCursorTo(1)
+	if i := tv.search("gopher", true); i != 3 {
+		t.Fatalf("Expected 3, but %d", i)
+	}
+	if i := tv.search("gopher", false); i != 3 {
+		t.Fatalf("Search must wrap around, but %d", i)
+	}
+	if i := tv.search("rust", true); i != -1 {
+		t.Fatalf("Expected -1, but %d", i)
+	}
+}